@@ -12,9 +12,11 @@ import (
 	"github.com/boot2podman/machine/drivers/generic"
 	"github.com/boot2podman/machine/drivers/qemu"
 	"github.com/boot2podman/machine/drivers/virtualbox"
+	"github.com/boot2podman/machine/libmachine/drivers"
 	"github.com/boot2podman/machine/libmachine/drivers/plugin"
 	"github.com/boot2podman/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/persist"
 	"github.com/boot2podman/machine/version"
 	"github.com/codegangsta/cli"
 )
@@ -105,6 +107,12 @@ func main() {
 			Value:  mcndirs.GetBaseDir(),
 			Usage:  "Configures storage path",
 		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_STORAGE_BACKEND",
+			Name:   "storage-backend",
+			Value:  persist.FileBackend,
+			Usage:  "Backend keeping the machine configs: file, or kv for a single database file",
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_TLS_CA_CERT",
 			Name:   "tls-ca-cert",
@@ -140,6 +148,11 @@ func main() {
 			Name:   "native-ssh",
 			Usage:  "Use the native (Go-based) SSH implementation.",
 		},
+		cli.BoolFlag{
+			EnvVar: "MACHINE_WAIT",
+			Name:   "wait",
+			Usage:  "Wait for the machines used by other invocations, instead of failing.",
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -150,11 +163,11 @@ func main() {
 func runDriver(driverName string) {
 	switch driverName {
 	case "generic":
-		plugin.RegisterDriver(generic.NewDriver("", ""))
+		plugin.RegisterDriverFactory(func() drivers.Driver { return generic.NewDriver("", "") })
 	case "virtualbox":
-		plugin.RegisterDriver(virtualbox.NewDriver("", ""))
+		plugin.RegisterDriverFactory(func() drivers.Driver { return virtualbox.NewDriver("", "") })
 	case "qemu":
-		plugin.RegisterDriver(qemu.NewDriver("", ""))
+		plugin.RegisterDriverFactory(func() drivers.Driver { return qemu.NewDriver("", "") })
 	default:
 		fmt.Fprintf(os.Stderr, "Unsupported driver: %s\n", driverName)
 		os.Exit(1)
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/boot2podman/machine/libmachine"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/ssh"
+)
+
+func cmdBridge(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	socket := c.String("socket")
+	if socket == "" {
+		socket = varlinkBridgeSocket(api, target)
+	}
+
+	if err := removeStaleSocket(socket); err != nil {
+		return err
+	}
+
+	// The SSH host and port are asked to the driver again on every
+	// connection, as they may change when the machine is restarted.
+	forwarder := ssh.NewReconnectingForwarder(func() (*ssh.NativeClient, error) {
+		client, err := h.CreateNativeSSHClient()
+		if err != nil {
+			return nil, err
+		}
+		client.Config.User = "root"
+
+		return client, nil
+	})
+	defer forwarder.Close()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	local := ssh.Endpoint{Network: "unix", Address: socket}
+	remote := ssh.Endpoint{Network: "unix", Address: varlinkSocket}
+	if _, err := forwarder.Tunnel(local, remote); err != nil {
+		return err
+	}
+
+	log.Infof("Bridging %s to the podman varlink socket of %q, press Ctrl-C to stop...", socket, target)
+	<-signals
+
+	return nil
+}
+
+// varlinkBridgeSocket returns the default socket of the varlink bridge of a
+// machine.
+func varlinkBridgeSocket(api libmachine.API, name string) string {
+	return filepath.Join(api.GetMachinesDir(), name, "varlink.sock")
+}
+
+// socketIsLive reports whether something listens on the unix socket.
+func socketIsLive(path string) bool {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// removeStaleSocket removes a socket left behind by a bridge that did not
+// exit cleanly, and refuses to replace a live one.
+func removeStaleSocket(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if socketIsLive(path) {
+		return fmt.Errorf("Something is already listening on %s", path)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveStaleSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bridge-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "varlink.sock")
+	assert.NoError(t, removeStaleSocket(path))
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, removeStaleSocket(path))
+
+	// Leave the socket file behind, as a crashed bridge would.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	assert.NoError(t, removeStaleSocket(path))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/boot2podman/machine/libmachine"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/mcnerror"
+)
+
+func cmdClone(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 2 {
+		return errWrongNumberArguments
+	}
+	srcName, name := c.Args()[0], c.Args()[1]
+
+	if !host.ValidateHostName(name) {
+		return fmt.Errorf("Error cloning machine: %s", mcnerror.ErrInvalidHostname)
+	}
+
+	src, err := api.Load(srcName)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Clone(src, name)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("%q was cloned from %q", h.Name, srcName)
+	log.Infof("To see how to connect your Podman client to Podman server running on this virtual machine, run: %s env %s", os.Args[0], h.Name)
+	return nil
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/libmachinetest"
+	"github.com/boot2podman/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdCloneWrongNumberOfArguments(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine"},
+	}
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdClone(commandLine, api)
+
+	assert.Equal(t, errWrongNumberArguments, err)
+}
+
+func TestCmdCloneInvalidName(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine", "not valid"},
+	}
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdClone(commandLine, api)
+
+	assert.EqualError(t, err, "Error cloning machine: Invalid hostname specified. Allowed hostname chars are: 0-9a-zA-Z . -")
+}
+
+func TestCmdClone(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine", "clone"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "machine",
+				DriverName: "fakedriver",
+				Driver: &fakedriver.Driver{
+					MockState: state.Stopped,
+				},
+			},
+		},
+	}
+
+	err := cmdClone(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.True(t, libmachinetest.Exists(api, "clone"))
+}
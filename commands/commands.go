@@ -1,13 +1,19 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
 
 	"github.com/boot2podman/machine/commands/mcndirs"
 	"github.com/boot2podman/machine/libmachine"
+	"github.com/boot2podman/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/boot2podman/machine/libmachine/host"
 	"github.com/boot2podman/machine/libmachine/log"
 	"github.com/boot2podman/machine/libmachine/mcnutils"
@@ -92,6 +98,36 @@ func targetHost(c CommandLine, api libmachine.API) (string, error) {
 	return c.Args()[0], nil
 }
 
+// targetHostAndArg returns the machine and the argument given as
+// [machine-name] argument, defaulting to the 'default' machine.
+func targetHostAndArg(c CommandLine, api libmachine.API) (*host.Host, string, error) {
+	var target, arg string
+
+	switch len(c.Args()) {
+	case 1:
+		defaultExists, err := api.Exists(defaultMachineName)
+		if err != nil {
+			return nil, "", fmt.Errorf("Error checking if host %q exists: %s", defaultMachineName, err)
+		}
+		if !defaultExists {
+			return nil, "", ErrNoDefault
+		}
+		target, arg = defaultMachineName, c.Args()[0]
+	case 2:
+		target, arg = c.Args()[0], c.Args()[1]
+	default:
+		c.ShowHelp()
+		return nil, "", errWrongNumberArguments
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return h, arg, nil
+}
+
 func runAction(actionName string, c CommandLine, api libmachine.API) error {
 	var (
 		hostsToLoad []string
@@ -108,9 +144,13 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 
 		hostsToLoad = []string{target}
 	} else {
-		hostsToLoad = c.Args()
+		hostsToLoad = append([]string{}, c.Args()...)
 	}
 
+	// The machines are locked as they are loaded, always in the same order
+	// so that invocations waiting for each other don't deadlock.
+	sort.Strings(hostsToLoad)
+
 	hosts, hostsInError := persist.LoadHosts(api, hostsToLoad)
 
 	if len(hostsInError) > 0 {
@@ -138,26 +178,68 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 	return nil
 }
 
+// lockMode tells which locks a command takes, to keep other invocations
+// from changing what it changes.
+type lockMode int
+
+const (
+	// lockNone is for the commands that don't change machines
+	lockNone lockMode = iota
+	// lockMachines is for the commands that change the machines they load
+	lockMachines
+	// lockStore is for the commands that add or remove machines, and take
+	// the lock of the store too
+	lockStore
+)
+
 func runCommand(command func(commandLine CommandLine, api libmachine.API) error) func(context *cli.Context) {
+	return runLockedCommand(lockNone, command)
+}
+
+// runLockedCommand runs command holding the locks of mode. The lock of the
+// store is taken first, and the machines are locked as they are loaded.
+// With --wait, busy locks are waited for instead of failing the command.
+func runLockedCommand(mode lockMode, command func(commandLine CommandLine, api libmachine.API) error) func(context *cli.Context) {
 	return func(context *cli.Context) {
-		api := libmachine.NewClient(mcndirs.GetBaseDir(), mcndirs.GetMachineCertDir())
+		storePath := context.GlobalString("storage-path")
+		store, err := persist.NewStore(context.GlobalString("storage-backend"), storePath)
+		if err != nil {
+			log.Error(err)
+
+			osExit(1)
+			return
+		}
+
+		api := libmachine.NewClientWithStore(storePath, mcndirs.GetMachineCertDir(), store)
 		defer api.Close()
 
 		if context.GlobalBool("native-ssh") {
 			api.SSHClientType = ssh.Native
 		}
 		api.GithubAPIToken = context.GlobalString("github-api-token")
-		api.Filestore.Path = context.GlobalString("storage-path")
 
 		// TODO (nathanleclaire): These should ultimately be accessed
 		// through the libmachine client by the rest of the code and
 		// not through their respective modules.  For now, however,
 		// they are also being set the way that they originally were
 		// set to preserve backwards compatibility.
-		mcndirs.BaseDir = api.Filestore.Path
+		mcndirs.BaseDir = api.StorePath()
+		localbinary.PluginsDir = filepath.Join(api.StorePath(), "plugins")
 		mcnutils.GithubAPIToken = api.GithubAPIToken
 		ssh.SetDefaultClient(api.SSHClientType)
 
+		if mode != lockNone {
+			api.EnableLocking(commandDescription(), context.GlobalBool("wait"))
+		}
+		if mode == lockStore {
+			if err := api.LockStore(); err != nil {
+				log.Error(err)
+
+				osExit(1)
+				return
+			}
+		}
+
 		if err := command(&contextCommandLine{context}, api); err != nil {
 			log.Error(err)
 
@@ -167,6 +249,12 @@ func runCommand(command func(commandLine CommandLine, api libmachine.API) error)
 	}
 }
 
+// commandDescription returns the command line of this process, to tell
+// other invocations what holds the locks.
+func commandDescription() string {
+	return strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " ")
+}
+
 func confirmInput(msg string) (bool, error) {
 	fmt.Printf("%s (y/n): ", msg)
 
@@ -193,6 +281,24 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "bridge",
+		Usage:       "Bridge a local unix socket to the podman varlink socket of a machine",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdBridge),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "socket",
+				Usage: "Path of the local socket, defaults to varlink.sock in the machine directory",
+			},
+		},
+	},
+	{
+		Name:        "clone",
+		Usage:       "Create a machine from the disk of a stopped machine",
+		Description: "Arguments are the name of the stopped machine and the name of the new machine.",
+		Action:      runLockedCommand(lockStore, cmdClone),
+	},
 	{
 		Name:        "config",
 		Usage:       "Print the connection config for machine",
@@ -204,9 +310,27 @@ var Commands = []cli.Command{
 		Name:            "create",
 		Usage:           "Create a machine",
 		Description:     fmt.Sprintf("Run '%s create --driver name --help' to include the create flags for that driver in the help text.", os.Args[0]),
-		Action:          runCommand(cmdCreateOuter),
+		Action:          runLockedCommand(lockStore, cmdCreateOuter),
 		SkipFlagParsing: true,
 	},
+	{
+		Name:  "drivers",
+		Usage: "List the drivers and their plugins",
+		Subcommands: []cli.Command{
+			{
+				Name:    "ls",
+				Aliases: []string{"list"},
+				Usage:   "List the core drivers and the plugins found in the plugins directory and the PATH",
+				Action:  runCommand(cmdDriversLs),
+			},
+			{
+				Name:        "info",
+				Usage:       "Show the version and the create flags of a driver",
+				Description: "Argument is a driver name.",
+				Action:      runCommand(cmdDriversInfo),
+			},
+		},
+	},
 	{
 		Name:        "env",
 		Usage:       "Display the commands to set up the environment for the Podman client",
@@ -215,7 +339,7 @@ var Commands = []cli.Command{
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "varlink",
-				Usage: "Set varlink bridge, instead of podman variables",
+				Usage: "Set varlink bridge or address, instead of podman variables",
 			},
 			cli.StringFlag{
 				Name:  "shell",
@@ -231,6 +355,30 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "export",
+		Usage:       "Export a stopped machine as an archive",
+		Description: "Argument is a machine name.",
+		Action:      runLockedCommand(lockMachines, cmdExport),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "output, o",
+				Usage: "Path of the archive, defaults to the machine name with the .tar.gz extension",
+			},
+		},
+	},
+	{
+		Name:        "import",
+		Usage:       "Import a machine from an archive",
+		Description: "Argument is the path of an archive written by export.",
+		Action:      runLockedCommand(lockStore, cmdImport),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "name",
+				Usage: "Name of the machine, defaults to its name in the archive",
+			},
+		},
+	},
 	{
 		Name:        "inspect",
 		Usage:       "Inspect information about a machine",
@@ -242,6 +390,10 @@ var Commands = []cli.Command{
 				Usage: "Format the output using the given go template.",
 				Value: "",
 			},
+			cli.BoolFlag{
+				Name:  "machine-file",
+				Usage: "Describe the machine as a machine file for create --file.",
+			},
 		},
 	},
 	{
@@ -254,7 +406,7 @@ var Commands = []cli.Command{
 		Name:        "kill",
 		Usage:       "Kill a machine",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      runCommand(cmdKill),
+		Action:      runLockedCommand(lockMachines, cmdKill),
 	},
 	{
 		Name:   "ls",
@@ -281,16 +433,66 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "pause",
+		Usage:       "Pause a machine",
+		Description: "Argument(s) are one or more machine names.",
+		Action:      runLockedCommand(lockMachines, cmdPause),
+	},
+	{
+		Name:  "port",
+		Usage: "Manage port forwarding from the host to a machine",
+		Subcommands: []cli.Command{
+			{
+				Name:        "add",
+				Usage:       "Forward a host port to a machine",
+				Description: "Arguments are [machine-name] [hostport:]guestport[/udp].",
+				Action:      runLockedCommand(lockMachines, cmdPortAdd),
+			},
+			{
+				Name:        "rm",
+				Usage:       "Remove the forward of a host port",
+				Description: "Arguments are [machine-name] hostport[/udp].",
+				Action:      runLockedCommand(lockMachines, cmdPortRm),
+			},
+			{
+				Name:        "ls",
+				Aliases:     []string{"list"},
+				Usage:       "List the ports forwarded to a machine",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdPortLs),
+			},
+			{
+				Name:        "watch",
+				Usage:       "Forward the ports published by containers until interrupted",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdPortWatch),
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "interval, i",
+						Usage: fmt.Sprintf("Seconds between container listings, default to %ds", portWatchDefaultInterval),
+						Value: portWatchDefaultInterval,
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:   "provision",
 		Usage:  "Re-provision existing machines",
-		Action: runCommand(cmdProvision),
+		Action: runLockedCommand(lockMachines, cmdProvision),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print the commands and file uploads of the provisioning instead of running them",
+			},
+		},
 	},
 	{
 		Name:        "regenerate-certs",
 		Usage:       "Regenerate TLS Certificates for a machine",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      runCommand(cmdRegenerateCerts),
+		Action:      runLockedCommand(lockMachines, cmdRegenerateCerts),
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "force, f",
@@ -306,7 +508,13 @@ var Commands = []cli.Command{
 		Name:        "restart",
 		Usage:       "Restart a machine",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      runCommand(cmdRestart),
+		Action:      runLockedCommand(lockMachines, cmdRestart),
+	},
+	{
+		Name:        "resume",
+		Usage:       "Resume a paused or saved machine",
+		Description: "Argument(s) are one or more machine names.",
+		Action:      runLockedCommand(lockMachines, cmdResume),
 	},
 	{
 		Flags: []cli.Flag{
@@ -322,7 +530,37 @@ var Commands = []cli.Command{
 		Name:        "rm",
 		Usage:       "Remove a machine",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      runCommand(cmdRm),
+		Action:      runLockedCommand(lockStore, cmdRm),
+	},
+	{
+		Name:        "save",
+		Usage:       "Save the state of a machine to disk and stop it",
+		Description: "Argument(s) are one or more machine names.",
+		Action:      runLockedCommand(lockMachines, cmdSave),
+	},
+	{
+		Name:        "set",
+		Usage:       "Change the CPUs, memory or disk size of a machine",
+		Description: "Argument is a machine name.",
+		Action:      runLockedCommand(lockMachines, cmdSet),
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "cpus",
+				Usage: "Number of CPUs",
+			},
+			cli.IntFlag{
+				Name:  "memory",
+				Usage: "Size of memory in MB",
+			},
+			cli.IntFlag{
+				Name:  "disk-size",
+				Usage: "Size of disk in MB, the disk can only grow",
+			},
+			cli.BoolFlag{
+				Name:  "restart",
+				Usage: "Stop the machine if needed, and start it again after the change",
+			},
+		},
 	},
 	{
 		Name:            "ssh",
@@ -354,20 +592,55 @@ var Commands = []cli.Command{
 	{
 		Name:        "mount",
 		Usage:       "Mount or unmount a directory from a machine with SSHFS.",
-		Description: "Arguments are [machine:][path] [mountpoint]",
+		Description: "Arguments are [machine:][path] [mountpoint], or hostdir [machine:]mountpoint with --reverse",
 		Action:      runCommand(cmdMount),
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "unmount, u",
 				Usage: "Unmount instead of mount",
 			},
+			cli.BoolFlag{
+				Name:  "reverse",
+				Usage: "Mount a host directory in the machine, with the sshfs of the machine",
+			},
+		},
+	},
+	{
+		Name:  "snapshot",
+		Usage: "Manage snapshots of a machine",
+		Subcommands: []cli.Command{
+			{
+				Name:        "save",
+				Usage:       "Save the current state of a machine as a snapshot",
+				Description: "Arguments are [machine-name] snapshot-name.",
+				Action:      runLockedCommand(lockMachines, cmdSnapshotSave),
+			},
+			{
+				Name:        "restore",
+				Usage:       "Restore a machine to a snapshot",
+				Description: "Arguments are [machine-name] snapshot-name.",
+				Action:      runLockedCommand(lockMachines, cmdSnapshotRestore),
+			},
+			{
+				Name:        "ls",
+				Aliases:     []string{"list"},
+				Usage:       "List the snapshots of a machine",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdSnapshotLs),
+			},
+			{
+				Name:        "rm",
+				Usage:       "Remove a snapshot of a machine",
+				Description: "Arguments are [machine-name] snapshot-name.",
+				Action:      runLockedCommand(lockMachines, cmdSnapshotRm),
+			},
 		},
 	},
 	{
 		Name:        "start",
 		Usage:       "Start a machine",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      runCommand(cmdStart),
+		Action:      runLockedCommand(lockMachines, cmdStart),
 	},
 	{
 		Name:        "status",
@@ -379,13 +652,25 @@ var Commands = []cli.Command{
 		Name:        "stop",
 		Usage:       "Stop a machine",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      runCommand(cmdStop),
+		Action:      runLockedCommand(lockMachines, cmdStop),
+	},
+	{
+		Name:        "tunnel",
+		Usage:       "Forward a local port or socket to a machine over SSH",
+		Description: "Arguments are [machine-name] [bind_address:]port|socket:[host:]hostport|socket.",
+		Action:      runCommand(cmdTunnel),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "varlink",
+				Usage: "Forward the local port or socket to the podman varlink socket",
+			},
+		},
 	},
 	{
 		Name:        "upgrade",
 		Usage:       "Upgrade a machine to the latest version of Podman",
 		Description: "Argument(s) are one or more machine names.",
-		Action:      runCommand(cmdUpgrade),
+		Action:      runLockedCommand(lockMachines, cmdUpgrade),
 	},
 	{
 		Name:        "url",
@@ -413,6 +698,39 @@ func printIP(h *host.Host) func() error {
 	}
 }
 
+// interruptContext returns a context which is cancelled on Ctrl-C or SIGTERM,
+// so that drivers supporting it leave the machine stopped instead of being
+// killed half way. The returned func must be called when done.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			log.Info("Interrupted, stopping...")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// startMachine starts h until it is up or interrupted.
+func startMachine(h *host.Host) func() error {
+	return func() error {
+		ctx, stop := interruptContext()
+		defer stop()
+
+		return h.StartContext(ctx)
+	}
+}
+
 // machineCommand maps the command name to the corresponding machine command.
 // We run commands concurrently and communicate back an error if there was one.
 func machineCommand(actionName string, host *host.Host, errorChan chan<- error) {
@@ -420,13 +738,17 @@ func machineCommand(actionName string, host *host.Host, errorChan chan<- error)
 	commands := map[string](func() error){
 		"configureAuth":    host.ConfigureAuth,
 		"configureAllAuth": host.ConfigureAllAuth,
-		"start":            host.Start,
+		"start":            startMachine(host),
 		"stop":             host.Stop,
 		"restart":          host.Restart,
 		"kill":             host.Kill,
+		"pause":            host.Pause,
+		"resume":           host.Resume,
+		"save":             host.Save,
 		"upgrade":          host.Upgrade,
 		"ip":               printIP(host),
 		"provision":        host.Provision,
+		"dryRunProvision":  host.DryRunProvision,
 	}
 
 	log.Debugf("command=%s machine=%s", actionName, host.Name)
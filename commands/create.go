@@ -26,6 +26,7 @@ import (
 
 var (
 	errNoMachineName = errors.New("Error: No machine name specified")
+	errDryRunCreate  = errors.New("Error: --dry-run is only supported with the generic driver, as other drivers create the machine; create it, then run provision --dry-run")
 )
 
 var (
@@ -77,6 +78,24 @@ var (
 			Usage: "Support extra SANs for TLS certs",
 			Value: &cli.StringSlice{},
 		},
+		cli.StringSliceFlag{
+			Name:  "provision-script",
+			Usage: "Local script to run on the machine after it is provisioned",
+			Value: &cli.StringSlice{},
+		},
+		cli.StringSliceFlag{
+			Name:  "start-script",
+			Usage: "Local script to run on the machine after it is started",
+			Value: &cli.StringSlice{},
+		},
+		cli.StringFlag{
+			Name:  "file, f",
+			Usage: "Read the machine configuration from a machine file, overridden by flags",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Add the existing machine of the generic driver, and print its provisioning instead of running it",
+		},
 	}
 )
 
@@ -85,7 +104,15 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 		return fmt.Errorf("Invalid command line. Found extra arguments %v", c.Args()[1:])
 	}
 
+	mf, err := readMachineFile(c.String("file"))
+	if err != nil {
+		return err
+	}
+
 	name := c.Args().First()
+	if name == "" && mf != nil {
+		name = mf.Name
+	}
 	if name == "" {
 		c.ShowHelp()
 		return errNoMachineName
@@ -106,11 +133,42 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 	}
 
 	driverName := c.String("driver")
+	if mf != nil && mf.Driver != "" && !c.IsSet("driver") {
+		driverName = mf.Driver
+	}
+
+	// The machines of other drivers would be created and booted for real,
+	// which is not what a dry run promises
+	if c.Bool("dry-run") && driverName != "generic" {
+		return errDryRunCreate
+	}
+
 	h, err := api.NewHost(driverName, rawDriver)
 	if err != nil {
 		return fmt.Errorf("Error getting new host: %s", err)
 	}
 
+	// mcnFlags is the data we get back over the wire (type mcnflag.Flag)
+	// to indicate which parameters are available.
+	mcnFlags := h.Driver.GetCreateFlags()
+
+	if mf != nil {
+		values, err := mf.Flags(mcnFlags)
+		if err != nil {
+			return fmt.Errorf("Error in machine file: %s", err)
+		}
+		c = &machineFileCommandLine{CommandLine: c, values: values}
+	}
+
+	provisionScripts, err := scriptPaths(c.StringSlice("provision-script"))
+	if err != nil {
+		return err
+	}
+	startScripts, err := scriptPaths(c.StringSlice("start-script"))
+	if err != nil {
+		return err
+	}
+
 	h.HostOptions = &host.Options{
 		AuthOptions: &auth.Options{
 			CertDir:          mcndirs.GetMachineCertDir(),
@@ -133,6 +191,10 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 			TLSVerify:        true,
 			InstallURL:       c.String("engine-install-url"),
 		},
+		DriverOptions:    explicitDriverOpts(c, mcnFlags),
+		ProvisionScripts: provisionScripts,
+		StartScripts:     startScripts,
+		DryRun:           c.Bool("dry-run"),
 	}
 
 	exists, err := api.Exists(h.Name)
@@ -148,14 +210,16 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 	// driverOpts is the actual data we send over the wire to set the
 	// driver parameters (an interface fulfilling drivers.DriverOptions,
 	// concrete type rpcdriver.RpcFlags).
-	mcnFlags := h.Driver.GetCreateFlags()
 	driverOpts := getDriverOpts(c, mcnFlags)
 
 	if err := h.Driver.SetConfigFromFlags(driverOpts); err != nil {
 		return fmt.Errorf("Error setting machine configuration from flags provided: %s", err)
 	}
 
-	if err := api.Create(h); err != nil {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if err := api.CreateContext(ctx, h); err != nil {
 		return fmt.Errorf("Error performing create: %s", err)
 	}
 
@@ -163,6 +227,11 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 		return fmt.Errorf("Error attempting to save store: %s", err)
 	}
 
+	if c.Bool("dry-run") {
+		log.Infof("The machine %s was added to the store, but not provisioned. To provision it, run: %s provision %s", name, os.Args[0], name)
+		return nil
+	}
+
 	log.Infof("To see how to connect your Podman client to Podman server running on this virtual machine, run: %s env %s", os.Args[0], name)
 
 	return nil
@@ -211,6 +280,17 @@ func cmdCreateOuter(c CommandLine, api libmachine.API) error {
 
 	// We didn't recognize the driver name.
 	driverName := flagHackLookup("--driver")
+	if driverName == "" {
+		// The flags of the command are not parsed yet, so the
+		// machine file is read twice.
+		mf, err := readMachineFile(flagHackLookup("--file"))
+		if err != nil {
+			return err
+		}
+		if mf != nil {
+			driverName = mf.Driver
+		}
+	}
 	if driverName == "" {
 		//TODO: Check Environment have to include flagHackLookup function.
 		driverName = os.Getenv("MACHINE_DRIVER")
@@ -351,6 +431,23 @@ func addDriverFlagsToCommand(cliFlags []cli.Flag, cmd *cli.Command) *cli.Command
 	return cmd
 }
 
+// scriptPaths returns the absolute paths of the scripts, which are run from
+// other directories later on. They must exist.
+func scriptPaths(scripts []string) ([]string, error) {
+	var paths []string
+	for _, script := range scripts {
+		path, err := filepath.Abs(script)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("Error reading script %s: %s", script, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
 func tlsPath(c CommandLine, flag string, defaultName string) string {
 	path := c.GlobalString(flag)
 	if path != "" {
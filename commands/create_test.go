@@ -1,10 +1,14 @@
 package commands
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"flag"
 	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/libmachine/libmachinetest"
 	"github.com/boot2podman/machine/libmachine/mcnflag"
 	"github.com/stretchr/testify/assert"
 )
@@ -102,3 +106,48 @@ func TestGetDriverOpts(t *testing.T) {
 		assert.Equal(t, tt.expected["stringslice_defaulted"], driverOpts.StringSlice("stringslice_defaulted"))
 	}
 }
+
+func TestScriptPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "login.sh")
+	if err := ioutil.WriteFile(script, []byte("podman login\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := scriptPaths([]string{script})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{script}, paths)
+
+	paths, err = scriptPaths(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, paths)
+
+	_, err = scriptPaths([]string{filepath.Join(dir, "missing.sh")})
+	assert.Error(t, err)
+}
+
+func TestCmdCreateDryRunNeedsGeneric(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"box"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"driver":  "virtualbox",
+				"dry-run": true,
+			},
+		},
+		GlobalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{},
+		},
+	}
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdCreateInner(commandLine, api)
+
+	assert.Equal(t, errDryRunCreate, err)
+	assert.Empty(t, api.Hosts)
+}
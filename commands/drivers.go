@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/boot2podman/machine/libmachine"
+	"github.com/boot2podman/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/boot2podman/machine/libmachine/drivers/rpc"
+	"github.com/boot2podman/machine/libmachine/mcnflag"
+	"github.com/boot2podman/machine/libmachine/version"
+)
+
+var (
+	findDrivers = localbinary.FindDrivers
+	queryPlugin = rpcdriver.QueryPlugin
+)
+
+func cmdDriversLs(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 0 {
+		return ErrTooManyArguments
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "NAME\tTYPE\tAPI VERSION\tCOMPATIBLE\tPATH\tERRORS")
+	for _, d := range findDrivers() {
+		info, err := queryPlugin(d.Name)
+		writeDriverLine(w, d, info, err)
+	}
+
+	return nil
+}
+
+func writeDriverLine(w io.Writer, d localbinary.DriverBinary, info *rpcdriver.PluginInfo, err error) {
+	apiVersion, compatible, errors := "Unknown", "Unknown", ""
+	if err != nil {
+		errors = err.Error()
+	} else {
+		apiVersion, compatible = fmt.Sprint(info.APIVersion), "no"
+		if info.Compatible {
+			compatible = "yes"
+		}
+	}
+
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", d.Name, driverType(d), apiVersion, compatible, d.Path, errors)
+}
+
+func cmdDriversInfo(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		return errWrongNumberArguments
+	}
+	name := c.Args().First()
+
+	d := localbinary.DriverBinary{Name: name}
+	for _, found := range findDrivers() {
+		if found.Name == name {
+			d = found
+			break
+		}
+	}
+
+	info, err := queryPlugin(name)
+	if err != nil {
+		return err
+	}
+
+	writeDriverInfo(os.Stdout, d, info)
+	return nil
+}
+
+func writeDriverInfo(out io.Writer, d localbinary.DriverBinary, info *rpcdriver.PluginInfo) {
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "Name:\t%s\n", info.DriverName)
+	fmt.Fprintf(w, "Type:\t%s\n", driverType(d))
+	fmt.Fprintf(w, "Path:\t%s\n", d.Path)
+	if !info.Compatible {
+		fmt.Fprintf(w, "API version:\t%d (incompatible, expected %d to %d)\n", info.APIVersion, version.MinAPIVersion, version.APIVersion)
+		return
+	}
+	fmt.Fprintf(w, "API version:\t%d (compatible)\n", info.APIVersion)
+
+	flags := info.CreateFlags
+	sort.Slice(flags, func(i, j int) bool { return flags[i].String() < flags[j].String() })
+
+	fmt.Fprintln(w, "\nFLAG\tDEFAULT\tENV\tUSAGE")
+	for _, f := range flags {
+		usage, envVar := flagUsage(f)
+		fmt.Fprintf(w, "--%s\t%s\t%s\t%s\n", f.String(), flagDefault(f), envVar, usage)
+	}
+}
+
+func driverType(d localbinary.DriverBinary) string {
+	if d.Core {
+		return "core"
+	}
+	return "plugin"
+}
+
+// flagUsage returns the usage and the environment variable of a create flag,
+// as received from a plugin.
+func flagUsage(f mcnflag.Flag) (string, string) {
+	switch f := f.(type) {
+	case *mcnflag.BoolFlag:
+		return f.Usage, f.EnvVar
+	case *mcnflag.IntFlag:
+		return f.Usage, f.EnvVar
+	case *mcnflag.StringFlag:
+		return f.Usage, f.EnvVar
+	case *mcnflag.StringSliceFlag:
+		return f.Usage, f.EnvVar
+	}
+	return "", ""
+}
+
+func flagDefault(f mcnflag.Flag) string {
+	switch value := f.Default().(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(value, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/boot2podman/machine/libmachine/drivers/rpc"
+	"github.com/boot2podman/machine/libmachine/libmachinetest"
+	"github.com/boot2podman/machine/libmachine/mcnflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDriverLine(t *testing.T) {
+	var buf bytes.Buffer
+
+	writeDriverLine(&buf, localbinary.DriverBinary{Name: "qemu", Path: "/usr/bin/podman-machine", Core: true}, &rpcdriver.PluginInfo{APIVersion: 1, Compatible: true}, nil)
+	writeDriverLine(&buf, localbinary.DriverBinary{Name: "old", Path: "/usr/bin/podman-machine-driver-old"}, &rpcdriver.PluginInfo{APIVersion: 0}, nil)
+	writeDriverLine(&buf, localbinary.DriverBinary{Name: "broken", Path: "/usr/bin/podman-machine-driver-broken"}, nil, errors.New("Failed to dial the plugin server in 10s"))
+
+	assert.Equal(t, "qemu\tcore\t1\tyes\t/usr/bin/podman-machine\t\n"+
+		"old\tplugin\t0\tno\t/usr/bin/podman-machine-driver-old\t\n"+
+		"broken\tplugin\tUnknown\tUnknown\t/usr/bin/podman-machine-driver-broken\tFailed to dial the plugin server in 10s\n", buf.String())
+}
+
+func TestWriteDriverInfo(t *testing.T) {
+	var buf bytes.Buffer
+
+	writeDriverInfo(&buf, localbinary.DriverBinary{Name: "kvm", Path: "/plugins/podman-machine-driver-kvm"}, &rpcdriver.PluginInfo{
+		DriverName: "kvm",
+		APIVersion: 1,
+		Compatible: true,
+		CreateFlags: []mcnflag.Flag{
+			&mcnflag.StringFlag{Name: "kvm-network", Usage: "Name of the network", EnvVar: "KVM_NETWORK", Value: "default"},
+			&mcnflag.BoolFlag{Name: "kvm-nested", Usage: "Enable nested virtualization"},
+			&mcnflag.IntFlag{Name: "kvm-cpu-count", Usage: "Number of CPUs", EnvVar: "KVM_CPU_COUNT", Value: 1},
+		},
+	})
+
+	assert.Equal(t, `Name:          kvm
+Type:          plugin
+Path:          /plugins/podman-machine-driver-kvm
+API version:   1 (compatible)
+
+FLAG              DEFAULT   ENV             USAGE
+--kvm-cpu-count   1         KVM_CPU_COUNT   Number of CPUs
+--kvm-nested                                Enable nested virtualization
+--kvm-network     default   KVM_NETWORK     Name of the network
+`, buf.String())
+}
+
+func TestWriteDriverInfoIncompatible(t *testing.T) {
+	var buf bytes.Buffer
+
+	writeDriverInfo(&buf, localbinary.DriverBinary{Name: "old", Path: "/usr/bin/podman-machine-driver-old"}, &rpcdriver.PluginInfo{
+		DriverName: "old",
+		APIVersion: 0,
+	})
+
+	assert.Contains(t, buf.String(), "API version:   0 (incompatible, expected 1 to 3)\n")
+	assert.NotContains(t, buf.String(), "FLAG")
+}
+
+func TestCmdDriversInfoQueriesPlugin(t *testing.T) {
+	defer func(find func() []localbinary.DriverBinary, query func(string) (*rpcdriver.PluginInfo, error)) {
+		findDrivers, queryPlugin = find, query
+	}(findDrivers, queryPlugin)
+
+	queried := ""
+	findDrivers = func() []localbinary.DriverBinary { return nil }
+	queryPlugin = func(name string) (*rpcdriver.PluginInfo, error) {
+		queried = name
+		return nil, localbinary.ErrPluginBinaryNotFound{}
+	}
+
+	err := cmdDriversInfo(&commandstest.FakeCommandLine{CliArgs: []string{"kvm"}}, &libmachinetest.FakeAPI{})
+
+	assert.Equal(t, localbinary.ErrPluginBinaryNotFound{}, err)
+	assert.Equal(t, "kvm", queried)
+	assert.Equal(t, errWrongNumberArguments, cmdDriversInfo(&commandstest.FakeCommandLine{}, &libmachinetest.FakeAPI{}))
+}
@@ -15,7 +15,7 @@ import (
 
 const (
 	envTmpl    = `{{ .Prefix }}PODMAN_USER{{ .Delimiter }}{{ .PodmanUser }}{{ .Suffix }}{{ .Prefix }}PODMAN_HOST{{ .Delimiter }}{{ .PodmanHost }}{{ .Suffix }}{{ .Prefix }}PODMAN_PORT{{ .Delimiter }}{{ .PodmanPort }}{{ .Suffix }}{{ .Prefix }}PODMAN_IDENTITY_FILE{{ .Delimiter }}{{ .IdentityFile }}{{ .Suffix }}{{ if .KnownHosts }}{{ .Prefix }}PODMAN_KNOWN_HOSTS{{ .Delimiter }}{{ .KnownHosts }}{{ .Suffix }}{{else}}{{ .Prefix }}PODMAN_IGNORE_HOSTS{{ .Delimiter }}true{{ .Suffix }}{{end}}{{ .Prefix }}PODMAN_MACHINE_NAME{{ .Delimiter }}{{ .MachineName }}{{ .Suffix }}{{ if .ComposePathsVar }}{{ .Prefix }}COMPOSE_CONVERT_WINDOWS_PATHS{{ .Delimiter }}true{{ .Suffix }}{{end}}{{ if .NoProxyVar }}{{ .Prefix }}{{ .NoProxyVar }}{{ .Delimiter }}{{ .NoProxyValue }}{{ .Suffix }}{{end}}{{ .UsageHint }}`
-	bridgeTmpl = `{{ .Prefix }}PODMAN_VARLINK_BRIDGE{{ .Delimiter }}{{ .VarlinkBridge }}{{ .Suffix }}{{ .Prefix }}PODMAN_MACHINE_NAME{{ .Delimiter }}{{ .MachineName }}{{ .Suffix }}{{ .UsageHint }}`
+	bridgeTmpl = `{{ if not .VarlinkAddress }}{{ .Prefix }}PODMAN_VARLINK_BRIDGE{{ .Delimiter }}{{ .VarlinkBridge }}{{ .Suffix }}{{end}}{{ if not .VarlinkBridge }}{{ .Prefix }}PODMAN_VARLINK_ADDRESS{{ .Delimiter }}{{ .VarlinkAddress }}{{ .Suffix }}{{end}}{{ .Prefix }}PODMAN_MACHINE_NAME{{ .Delimiter }}{{ .MachineName }}{{ .Suffix }}{{ .UsageHint }}`
 )
 
 var (
@@ -38,6 +38,7 @@ type ShellConfig struct {
 	IdentityFile    string
 	KnownHosts      string
 	VarlinkBridge   string
+	VarlinkAddress  string
 	UsageHint       string
 	MachineName     string
 	NoProxyVar      string
@@ -130,6 +131,15 @@ func shellCfgSet(c CommandLine, api libmachine.API) (*ShellConfig, error) {
 			MachineName:  host.Name,
 		}
 
+	} else if socket := varlinkBridgeSocket(api, host.Name); host.Driver != nil && socketIsLive(socket) {
+
+		// A bridge started with `podman-machine bridge` is running
+		shellCfg = &ShellConfig{
+			VarlinkAddress: "unix:" + socket,
+			UsageHint:      hint,
+			MachineName:    host.Name,
+		}
+
 	} else if host.Driver != nil {
 
 		client, err := host.CreateExternalSSHClient()
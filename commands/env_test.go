@@ -1,7 +1,10 @@
 package commands
 
 import (
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -576,6 +579,10 @@ func (fsc *FakeRootSSHClientCreator) CreateExternalRootSSHClient(d drivers.Drive
 	return fsc.rootclient, nil
 }
 
+func (fsc *FakeRootSSHClientCreator) CreateNativeSSHClient(d drivers.Driver) (*ssh.NativeClient, error) {
+	return nil, nil
+}
+
 func TestVarlink(t *testing.T) {
 	const (
 		usageHint = "This is the varlink usage hint"
@@ -645,3 +652,54 @@ func TestVarlink(t *testing.T) {
 		assert.Equal(t, test.expectedErr, err)
 	}
 }
+
+func TestVarlinkBridgeRunning(t *testing.T) {
+	defer revertUsageHinter(defaultUsageHinter)
+	defaultUsageHinter = &SimpleUsageHintGenerator{"hint"}
+
+	machinesDir, err := ioutil.TempDir("", "env-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(machinesDir)
+
+	if err := os.Mkdir(filepath.Join(machinesDir, defaultMachineName), 0700); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(machinesDir, defaultMachineName, "varlink.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"shell":   "bash",
+				"varlink": true,
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		MachinesDir: machinesDir,
+		Hosts: []*host.Host{
+			{
+				Name:   defaultMachineName,
+				Driver: &fakedriver.Driver{MockState: state.Running},
+			},
+		},
+	}
+
+	shellCfg, err := shellCfgSet(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &ShellConfig{
+		Prefix:         "export ",
+		Delimiter:      "=\"",
+		Suffix:         "\"\n",
+		UsageHint:      "hint",
+		MachineName:    defaultMachineName,
+		VarlinkAddress: "unix:" + socket,
+	}, shellCfg)
+}
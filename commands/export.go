@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/boot2podman/machine/libmachine"
+	"github.com/boot2podman/machine/libmachine/log"
+)
+
+func cmdExport(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		return ErrExpectedOneMachine
+	}
+	name := c.Args().First()
+
+	h, err := api.Load(name)
+	if err != nil {
+		return err
+	}
+
+	output := c.String("output")
+	if output == "" {
+		output = name + ".tar.gz"
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+
+	if err := api.Export(h, f); err != nil {
+		f.Close()
+		os.Remove(output)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(output)
+		return err
+	}
+
+	log.Infof("%q was exported to %s", name, output)
+	return nil
+}
+
+func cmdImport(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		return errWrongNumberArguments
+	}
+
+	f, err := os.Open(c.Args().First())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h, err := api.Import(f, c.String("name"))
+	if err != nil {
+		return err
+	}
+
+	log.Infof("%q was imported from %s", h.Name, c.Args().First())
+	return nil
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/libmachinetest"
+	"github.com/boot2podman/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdExportAndImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "machine.tar.gz")
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name: "machine",
+				Driver: &fakedriver.Driver{
+					MockState: state.Stopped,
+				},
+			},
+		},
+	}
+
+	err = cmdExport(&commandstest.FakeCommandLine{
+		CliArgs: []string{"machine"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"output": archive},
+		},
+	}, api)
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadFile(archive)
+	assert.NoError(t, err)
+	assert.Equal(t, "machine", string(data))
+
+	err = cmdImport(&commandstest.FakeCommandLine{
+		CliArgs: []string{archive},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{"name": "copy"},
+		},
+	}, api)
+	assert.NoError(t, err)
+
+	assert.True(t, libmachinetest.Exists(api, "copy"))
+}
+
+func TestCmdExportMissingMachineName(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{}
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdExport(commandLine, api)
+
+	assert.Equal(t, ErrExpectedOneMachine, err)
+}
+
+func TestCmdImportMissingArchive(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"/does/not/exist.tar.gz"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{},
+		},
+	}
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdImport(commandLine, api)
+
+	assert.Error(t, err)
+	assert.Empty(t, api.Hosts)
+}
@@ -36,6 +36,11 @@ func cmdInspect(c CommandLine, api libmachine.API) error {
 		return err
 	}
 
+	if c.Bool("machine-file") {
+		os.Stdout.Write(machineFileFromHost(host).Marshal())
+		return nil
+	}
+
 	tmplString := c.String("format")
 	if tmplString != "" {
 		var tmpl *template.Template
@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/drivers/rpc"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/machinefile"
+	"github.com/boot2podman/machine/libmachine/mcnflag"
+)
+
+// readMachineFile loads the machine file given with --file, if any.
+func readMachineFile(path string) (*machinefile.MachineFile, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return machinefile.Load(path)
+}
+
+// machineFileCommandLine gives the values of a machine file to the flags that
+// were not set on the command line.
+type machineFileCommandLine struct {
+	CommandLine
+	values map[string]interface{}
+}
+
+func (c *machineFileCommandLine) fileValue(name string) (interface{}, bool) {
+	if c.CommandLine.IsSet(name) {
+		return nil, false
+	}
+	value, ok := c.values[name]
+	return value, ok
+}
+
+func (c *machineFileCommandLine) IsSet(name string) bool {
+	_, ok := c.values[name]
+	return ok || c.CommandLine.IsSet(name)
+}
+
+func (c *machineFileCommandLine) String(name string) string {
+	if value, ok := c.fileValue(name); ok {
+		if s, ok := value.(string); ok {
+			return s
+		}
+	}
+	return c.CommandLine.String(name)
+}
+
+func (c *machineFileCommandLine) StringSlice(name string) []string {
+	if value, ok := c.fileValue(name); ok {
+		if s, ok := value.([]string); ok {
+			return s
+		}
+	}
+	return c.CommandLine.StringSlice(name)
+}
+
+func (c *machineFileCommandLine) Int(name string) int {
+	if value, ok := c.fileValue(name); ok {
+		if i, ok := value.(int); ok {
+			return i
+		}
+	}
+	return c.CommandLine.Int(name)
+}
+
+func (c *machineFileCommandLine) Bool(name string) bool {
+	if value, ok := c.fileValue(name); ok {
+		if b, ok := value.(bool); ok {
+			return b
+		}
+	}
+	return c.CommandLine.Bool(name)
+}
+
+func (c *machineFileCommandLine) FlagNames() []string {
+	names := c.CommandLine.FlagNames()
+	for name := range c.values {
+		if !c.CommandLine.IsSet(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Generic returns a flag.Getter for scalar values of the file, as
+// getDriverOpts expects. Lists are read with StringSlice.
+func (c *machineFileCommandLine) Generic(name string) interface{} {
+	if value, ok := c.fileValue(name); ok {
+		if _, isList := value.([]string); !isList {
+			return fileValue{value}
+		}
+	}
+	return c.CommandLine.Generic(name)
+}
+
+// fileValue is a flag.Getter for a value read from a machine file.
+type fileValue struct {
+	value interface{}
+}
+
+func (v fileValue) String() string {
+	return fmt.Sprint(v.value)
+}
+
+func (v fileValue) Set(string) error {
+	return errors.New("values of the machine file cannot be changed")
+}
+
+func (v fileValue) Get() interface{} {
+	return v.value
+}
+
+// explicitDriverOpts returns the driver create flags that were set on the
+// command line or in a machine file.
+func explicitDriverOpts(c CommandLine, mcnFlags []mcnflag.Flag) map[string]interface{} {
+	values := getDriverOpts(c, mcnFlags).(rpcdriver.RPCFlags).Values
+
+	opts := map[string]interface{}{}
+	for _, f := range mcnFlags {
+		if c.IsSet(f.String()) {
+			opts[f.String()] = values[f.String()]
+		}
+	}
+
+	if len(opts) == 0 {
+		return nil
+	}
+	return opts
+}
+
+// machineFileFromHost describes an existing machine as a machine file.
+func machineFileFromHost(h *host.Host) *machinefile.MachineFile {
+	m := &machinefile.MachineFile{
+		Name:   h.Name,
+		Driver: h.DriverName,
+	}
+
+	if h.HostOptions == nil {
+		return m
+	}
+
+	m.DriverOptions = h.HostOptions.DriverOptions
+
+	if e := h.HostOptions.EngineOptions; e != nil {
+		m.Engine = machinefile.Engine{
+			Options:            e.ArbitraryFlags,
+			Env:                e.Env,
+			InsecureRegistries: e.InsecureRegistry,
+			Labels:             e.Labels,
+			RegistryMirrors:    e.RegistryMirror,
+			StorageDriver:      e.StorageDriver,
+		}
+		if e.InstallURL != drivers.DefaultEngineInstallURL {
+			m.Engine.InstallURL = e.InstallURL
+		}
+	}
+
+	if a := h.HostOptions.AuthOptions; a != nil {
+		m.TLSSANs = a.ServerCertSANs
+	}
+
+	m.ProvisionScripts = h.HostOptions.ProvisionScripts
+	m.StartScripts = h.HostOptions.StartScripts
+
+	return m
+}
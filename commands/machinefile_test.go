@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/libmachine/auth"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/engine"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/machinefile"
+	"github.com/boot2podman/machine/libmachine/mcnflag"
+	"github.com/stretchr/testify/assert"
+)
+
+var machineFileDriverFlags = []mcnflag.Flag{
+	&mcnflag.IntFlag{Name: "qemu-memory", Value: 1024},
+	&mcnflag.IntFlag{Name: "qemu-cpu-count", Value: 1},
+	&mcnflag.BoolFlag{Name: "qemu-display"},
+	&mcnflag.StringSliceFlag{Name: "qemu-share-folder"},
+}
+
+func TestMachineFileCommandLineOverrides(t *testing.T) {
+	c := &machineFileCommandLine{
+		CommandLine: &commandstest.FakeCommandLine{
+			LocalFlags: &commandstest.FakeFlagger{
+				Data: map[string]interface{}{
+					"engine-label":   []string{"env=prod"},
+					"qemu-cpu-count": fakeFlagGetter{value: 4},
+				},
+			},
+		},
+		values: map[string]interface{}{
+			"engine-label":      []string{"env=dev"},
+			"engine-env":        []string{"DEBUG=1"},
+			"qemu-memory":       2048,
+			"qemu-cpu-count":    2,
+			"qemu-share-folder": []string{"/home:/hosthome"},
+		},
+	}
+
+	assert.Equal(t, []string{"env=prod"}, c.StringSlice("engine-label"))
+	assert.Equal(t, []string{"DEBUG=1"}, c.StringSlice("engine-env"))
+	assert.True(t, c.IsSet("qemu-memory"))
+	assert.False(t, c.IsSet("qemu-display"))
+
+	opts := getDriverOpts(c, machineFileDriverFlags)
+
+	assert.Equal(t, 2048, opts.Int("qemu-memory"))
+	assert.Equal(t, 4, opts.Int("qemu-cpu-count"))
+	assert.False(t, opts.Bool("qemu-display"))
+	assert.Equal(t, []string{"/home:/hosthome"}, opts.StringSlice("qemu-share-folder"))
+
+	assert.Equal(t, map[string]interface{}{
+		"qemu-memory":       2048,
+		"qemu-cpu-count":    4,
+		"qemu-share-folder": []string{"/home:/hosthome"},
+	}, explicitDriverOpts(c, machineFileDriverFlags))
+}
+
+func TestMachineFileFromHost(t *testing.T) {
+	h := &host.Host{
+		Name:       "box",
+		DriverName: "qemu",
+		HostOptions: &host.Options{
+			DriverOptions: map[string]interface{}{"qemu-memory": float64(2048)},
+			EngineOptions: &engine.Options{
+				Labels:     []string{"env=dev"},
+				InstallURL: drivers.DefaultEngineInstallURL,
+			},
+			AuthOptions: &auth.Options{
+				ServerCertSANs: []string{"box.local"},
+			},
+			ProvisionScripts: []string{"/opt/login.sh"},
+		},
+	}
+
+	m := machineFileFromHost(h)
+
+	assert.Equal(t, &machinefile.MachineFile{
+		Name:          "box",
+		Driver:        "qemu",
+		DriverOptions: map[string]interface{}{"qemu-memory": float64(2048)},
+		Engine: machinefile.Engine{
+			Labels: []string{"env=dev"},
+		},
+		TLSSANs:          []string{"box.local"},
+		ProvisionScripts: []string{"/opt/login.sh"},
+	}, m)
+}
@@ -21,6 +21,10 @@ var (
 )
 
 func cmdMount(c CommandLine, api libmachine.API) error {
+	if c.Bool("reverse") {
+		return cmdMountReverse(c, api)
+	}
+
 	args := c.Args()
 	if len(args) < 1 || len(args) > 2 {
 		c.ShowHelp()
@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/boot2podman/machine/libmachine"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/sftp"
+	"github.com/boot2podman/machine/libmachine/ssh"
+)
+
+// cmdMountReverse mounts a host directory in a machine. The directory is
+// served by an embedded SFTP server, which the sshfs of the machine reaches
+// through a port forwarded back over SSH. It runs until the directory is
+// unmounted, or until interrupted.
+func cmdMountReverse(c CommandLine, api libmachine.API) error {
+	args := c.Args()
+	unmount := c.Bool("unmount")
+	if (unmount && len(args) != 1) || (!unmount && len(args) != 2) {
+		c.ShowHelp()
+		return errWrongNumberArguments
+	}
+
+	name, guestDir := splitMachinePath(args[len(args)-1])
+	if !path.IsAbs(guestDir) {
+		return fmt.Errorf("The mountpoint in the machine must be an absolute path: %q", guestDir)
+	}
+
+	h, err := api.Load(name)
+	if err != nil {
+		return err
+	}
+
+	client, err := h.CreateNativeSSHClient()
+	if err != nil {
+		return err
+	}
+	forwarder := ssh.NewForwarder(client)
+	defer forwarder.Close()
+
+	if unmount {
+		log.Infof("Unmounting %s from %q...", guestDir, name)
+		if output, err := forwarder.Output(reverseUnmountCommand(guestDir)); err != nil {
+			return fmt.Errorf("Error unmounting %s: %s\n%s", guestDir, err, output)
+		}
+		return nil
+	}
+
+	server, err := sftp.NewServer(args[0])
+	if err != nil {
+		return err
+	}
+
+	l, err := forwarder.Listen(ssh.Endpoint{Network: "tcp", Address: "127.0.0.1:0"})
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	done := serveSFTP(server, l)
+
+	port := l.Addr().(*net.TCPAddr).Port
+	if output, err := forwarder.Output(reverseMountCommand(port, guestDir)); err != nil {
+		return fmt.Errorf("Error mounting %s: %s\n%s", guestDir, err, output)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	log.Infof("Mounted %s at %s in %q, press Ctrl-C or run `podman-machine mount -u --reverse %s:%s` to stop...",
+		server.Root(), guestDir, name, name, guestDir)
+
+	select {
+	case <-done:
+	case <-signals:
+		log.Infof("Unmounting %s from %q...", guestDir, name)
+		if output, err := forwarder.Output(reverseUnmountCommand(guestDir)); err != nil {
+			return fmt.Errorf("Error unmounting %s: %s\n%s", guestDir, err, output)
+		}
+		<-done
+	}
+
+	return nil
+}
+
+// serveSFTP serves the first connection accepted on l, the one of sshfs,
+// and closes l right after, so that no other process of the machine can
+// reach the directory through the forwarded port. The returned channel is
+// closed once that connection is closed, which happens when sshfs exits.
+func serveSFTP(server *sftp.Server, l net.Listener) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		conn, err := l.Accept()
+		l.Close()
+		if err != nil {
+			// The SSH connection is gone, and so is the mount.
+			return
+		}
+		defer conn.Close()
+
+		if err := server.Serve(conn); err != nil {
+			log.Debugf("SFTP session ended: %s", err)
+		}
+	}()
+
+	return done
+}
+
+// splitMachinePath splits a [machine:]path argument.
+func splitMachinePath(arg string) (string, string) {
+	if parts := strings.SplitN(arg, ":", 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return defaultMachineName, arg
+}
+
+// reverseMountCommand mounts the SFTP server listening on port of the
+// machine loopback at guestDir, talking SFTP directly instead of over SSH.
+// The mount belongs to root, without allow_other, so that only root in the
+// machine can use it.
+func reverseMountCommand(port int, guestDir string) string {
+	dir := ssh.QuoteArg(guestDir)
+	return fmt.Sprintf("{ command -v sshfs >/dev/null || { echo 'sshfs is not installed in the machine'; exit 1; }; } && "+
+		"sudo mkdir -p %s && sudo sshfs -o directport=%d 127.0.0.1:/ %s 2>&1", dir, port, dir)
+}
+
+func reverseUnmountCommand(guestDir string) string {
+	dir := ssh.QuoteArg(guestDir)
+	return fmt.Sprintf("sudo fusermount -u %s 2>/dev/null || sudo umount %s 2>&1", dir, dir)
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/libmachine/libmachinetest"
+	"github.com/boot2podman/machine/libmachine/sftp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdMountReverseArguments(t *testing.T) {
+	var tests = []struct {
+		args    []string
+		flags   map[string]interface{}
+		message string
+	}{
+		{[]string{"/home/user/src"}, map[string]interface{}{"reverse": true}, errWrongNumberArguments.Error()},
+		{[]string{"/home/user/src", "box:/src"}, map[string]interface{}{"reverse": true, "unmount": true}, errWrongNumberArguments.Error()},
+		{[]string{"/home/user/src", "box:src"}, map[string]interface{}{"reverse": true}, `The mountpoint in the machine must be an absolute path: "src"`},
+	}
+
+	for _, test := range tests {
+		commandLine := &commandstest.FakeCommandLine{
+			CliArgs:    test.args,
+			LocalFlags: &commandstest.FakeFlagger{Data: test.flags},
+		}
+
+		err := cmdMount(commandLine, &libmachinetest.FakeAPI{})
+
+		assert.EqualError(t, err, test.message)
+	}
+}
+
+func TestSplitMachinePath(t *testing.T) {
+	name, dir := splitMachinePath("box:/src")
+	assert.Equal(t, "box", name)
+	assert.Equal(t, "/src", dir)
+
+	name, dir = splitMachinePath("/src")
+	assert.Equal(t, defaultMachineName, name)
+	assert.Equal(t, "/src", dir)
+}
+
+func TestReverseMountCommands(t *testing.T) {
+	assert.Equal(t, "{ command -v sshfs >/dev/null || { echo 'sshfs is not installed in the machine'; exit 1; }; } && "+
+		"sudo mkdir -p '/src' && sudo sshfs -o directport=40000 127.0.0.1:/ '/src' 2>&1",
+		reverseMountCommand(40000, "/src"))
+	assert.Equal(t, `sudo fusermount -u '/it'\''s' 2>/dev/null || sudo umount '/it'\''s' 2>&1`,
+		reverseUnmountCommand("/it's"))
+}
+
+func TestServeSFTPDoneWhenClientLeaves(t *testing.T) {
+	root, err := ioutil.TempDir("", "mount-reverse-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	server, err := sftp.NewServer(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	done := serveSFTP(server, l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the first client, sshfs, is served
+	deadline := time.Now().Add(time.Second)
+	for {
+		other, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			break
+		}
+		other.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("listener still open after the first client")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-done:
+		t.Fatal("done before the client left")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	conn.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("not done after the client left")
+	}
+}
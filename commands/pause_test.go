@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/libmachinetest"
+	"github.com/boot2podman/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePausableDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *fakePausableDriver) Pause() error {
+	d.MockState = state.Paused
+	return nil
+}
+
+func (d *fakePausableDriver) Resume() error {
+	d.MockState = state.Running
+	return nil
+}
+
+func TestCmdPause(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machineToPause"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machineToPause",
+				Driver: &fakePausableDriver{&fakedriver.Driver{MockState: state.Running}},
+			},
+			{
+				Name:   "machine",
+				Driver: &fakePausableDriver{&fakedriver.Driver{MockState: state.Running}},
+			},
+		},
+	}
+
+	err := cmdPause(commandLine, api)
+	assert.NoError(t, err)
+
+	assert.Equal(t, state.Paused, libmachinetest.State(api, "machineToPause"))
+	assert.Equal(t, state.Running, libmachinetest.State(api, "machine"))
+
+	err = cmdResume(commandLine, api)
+	assert.NoError(t, err)
+
+	assert.Equal(t, state.Running, libmachinetest.State(api, "machineToPause"))
+}
+
+func TestCmdPauseNotSupported(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "machine",
+				DriverName: "fake",
+				Driver:     &fakedriver.Driver{MockState: state.Running},
+			},
+		},
+	}
+
+	err := cmdPause(commandLine, api)
+
+	assert.EqualError(t, err, `Driver "fake" does not support pause`)
+}
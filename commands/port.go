@@ -0,0 +1,315 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/boot2podman/machine/libmachine"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/mcnerror"
+	"github.com/boot2podman/machine/libmachine/ssh"
+	"github.com/boot2podman/machine/libmachine/state"
+)
+
+const (
+	portWatchDefaultInterval = 2
+
+	// podmanPsCommand lists the containers of both rootless and root podman.
+	podmanPsCommand = "podman ps --format json 2>/dev/null; sudo podman ps --format json 2>/dev/null"
+)
+
+func cmdPortAdd(c CommandLine, api libmachine.API) error {
+	h, spec, err := targetHostAndArg(c, api)
+	if err != nil {
+		return err
+	}
+
+	f, err := drivers.ParsePortForward(spec)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Forwarding host port %d/%s to port %d of %q...", f.HostPort, f.Protocol, f.GuestPort, h.Name)
+	err = portForwardDo(h, func(p drivers.PortForwarder) error {
+		return p.AddPortForward(f)
+	})
+	if err != nil {
+		return err
+	}
+
+	return api.Save(h)
+}
+
+func cmdPortRm(c CommandLine, api libmachine.API) error {
+	h, spec, err := targetHostAndArg(c, api)
+	if err != nil {
+		return err
+	}
+
+	f, err := drivers.ParsePortForward(spec)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Removing the forward of host port %d/%s to %q...", f.HostPort, f.Protocol, h.Name)
+	err = portForwardDo(h, func(p drivers.PortForwarder) error {
+		return p.RemovePortForward(f)
+	})
+	if err != nil {
+		return err
+	}
+
+	return api.Save(h)
+}
+
+func cmdPortLs(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	var forwards []drivers.PortForward
+	err = portForwardDo(h, func(p drivers.PortForwarder) error {
+		forwards, err = p.ListPortForwards()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "HOST PORT\tGUEST PORT\tPROTOCOL")
+	for _, f := range forwards {
+		fmt.Fprintf(w, "%d\t%d\t%s\n", f.HostPort, f.GuestPort, f.Protocol)
+	}
+
+	return nil
+}
+
+func portForwardDo(h *host.Host, action func(drivers.PortForwarder) error) error {
+	notSupported := mcnerror.ErrOperationNotSupported{
+		DriverName: h.DriverName,
+		Operation:  "port forwarding",
+	}
+
+	p, ok := h.Driver.(drivers.PortForwarder)
+	if !ok {
+		return notSupported
+	}
+
+	err := action(p)
+	if err == drivers.ErrNotSupported {
+		return notSupported
+	}
+	return err
+}
+
+func cmdPortWatch(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	currentState, err := h.Driver.GetState()
+	if err != nil {
+		return err
+	}
+	if currentState != state.Running {
+		return errStateInvalidForSSH{h.Name}
+	}
+
+	client, err := h.CreateNativeSSHClient()
+	if err != nil {
+		return err
+	}
+
+	forwarder := ssh.NewForwarder(client)
+	defer forwarder.Close()
+
+	interval := time.Duration(c.Int("interval")) * time.Second
+	if interval <= 0 {
+		interval = portWatchDefaultInterval * time.Second
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	log.Infof("Forwarding the ports published by containers of %q, press Ctrl-C to stop...", h.Name)
+
+	w := newPortWatcher(forwarder)
+	for {
+		w.reconcile()
+
+		select {
+		case <-signals:
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// containerForwarder runs commands on a machine and forwards local ports to
+// it.
+type containerForwarder interface {
+	Output(command string) (string, error)
+	Forward(localAddr, remoteAddr string) (string, error)
+	Cancel(addr string) error
+}
+
+// portWatcher forwards every port published by a container of the machine to
+// the same port on the loopback interface of the host.
+type portWatcher struct {
+	forwarder containerForwarder
+
+	// active maps the forwarded local addresses to their remote address.
+	active map[string]string
+	// failed records the local addresses that could not be forwarded, so
+	// that the error is only reported once.
+	failed map[string]bool
+}
+
+func newPortWatcher(forwarder containerForwarder) *portWatcher {
+	return &portWatcher{
+		forwarder: forwarder,
+		active:    map[string]string{},
+		failed:    map[string]bool{},
+	}
+}
+
+// reconcile lists the published ports, then starts and stops forwards so
+// that the active ones match.
+func (w *portWatcher) reconcile() {
+	output, err := w.forwarder.Output(podmanPsCommand)
+	if err != nil && output == "" {
+		log.Debugf("Error listing containers: %s", err)
+		return
+	}
+
+	desired, err := publishedPorts(output)
+	if err != nil {
+		log.Debugf("Error parsing container list: %s", err)
+		return
+	}
+
+	for local, remote := range w.active {
+		if desired[local] == remote {
+			continue
+		}
+		if err := w.forwarder.Cancel(local); err != nil {
+			log.Debugf("Error stopping the forward of %s: %s", local, err)
+		}
+		delete(w.active, local)
+		log.Infof("Stopped forwarding %s", local)
+	}
+
+	for local, remote := range desired {
+		if _, ok := w.active[local]; ok {
+			continue
+		}
+		if _, err := w.forwarder.Forward(local, remote); err != nil {
+			if !w.failed[local] {
+				log.Warnf("Cannot forward %s: %s", local, err)
+				w.failed[local] = true
+			}
+			continue
+		}
+		delete(w.failed, local)
+		w.active[local] = remote
+		log.Infof("Forwarding %s to %s", local, remote)
+	}
+
+	for local := range w.failed {
+		if _, ok := desired[local]; !ok {
+			delete(w.failed, local)
+		}
+	}
+}
+
+type psPort struct {
+	HostIP   string `json:"hostIP"`
+	HostPort int    `json:"hostPort"`
+	Protocol string `json:"protocol"`
+
+	// Field names used since podman 2.0
+	HostIPV2   string `json:"host_ip"`
+	HostPortV2 int    `json:"host_port"`
+}
+
+type psContainer struct {
+	Ports []psPort `json:"Ports"`
+}
+
+// publishedPorts parses the output of one or more `podman ps --format json`
+// and maps a local address to the remote address of every published TCP
+// port. SSH cannot forward UDP, so UDP ports are left out.
+func publishedPorts(output string) (map[string]string, error) {
+	ports := map[string]string{}
+
+	dec := json.NewDecoder(strings.NewReader(output))
+	for {
+		var containers []psContainer
+		err := dec.Decode(&containers)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, container := range containers {
+			for _, p := range container.Ports {
+				if p.Protocol != "" && strings.ToLower(p.Protocol) != "tcp" {
+					continue
+				}
+
+				hostIP, hostPort := p.HostIP, p.HostPort
+				if hostPort == 0 {
+					hostIP, hostPort = p.HostIPV2, p.HostPortV2
+				}
+				if hostPort == 0 {
+					continue
+				}
+				if hostIP == "" || hostIP == "0.0.0.0" {
+					hostIP = "127.0.0.1"
+				}
+
+				port := strconv.Itoa(hostPort)
+				ports[net.JoinHostPort("127.0.0.1", port)] = net.JoinHostPort(hostIP, port)
+			}
+		}
+	}
+
+	return ports, nil
+}
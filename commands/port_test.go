@@ -0,0 +1,185 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePortForwardDriver struct {
+	*fakedriver.Driver
+	forwards []drivers.PortForward
+}
+
+func (d *fakePortForwardDriver) AddPortForward(f drivers.PortForward) error {
+	d.forwards = append(d.forwards, f)
+	return nil
+}
+
+func (d *fakePortForwardDriver) RemovePortForward(f drivers.PortForward) error {
+	for i, existing := range d.forwards {
+		if existing.HostPort == f.HostPort && existing.Protocol == f.Protocol {
+			d.forwards = append(d.forwards[:i], d.forwards[i+1:]...)
+			return nil
+		}
+	}
+	return drivers.ErrNotSupported
+}
+
+func (d *fakePortForwardDriver) ListPortForwards() ([]drivers.PortForward, error) {
+	return d.forwards, nil
+}
+
+func TestCmdPortAdd(t *testing.T) {
+	driver := &fakePortForwardDriver{Driver: &fakedriver.Driver{}}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine", "8080:80"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: driver,
+			},
+		},
+	}
+
+	err := cmdPortAdd(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.PortForward{{Protocol: "tcp", HostPort: 8080, GuestPort: 80}}, driver.forwards)
+}
+
+func TestCmdPortRmDefaultMachine(t *testing.T) {
+	driver := &fakePortForwardDriver{
+		Driver:   &fakedriver.Driver{},
+		forwards: []drivers.PortForward{{Protocol: "udp", HostPort: 5353, GuestPort: 53}},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"5353/udp"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   defaultMachineName,
+				Driver: driver,
+			},
+		},
+	}
+
+	err := cmdPortRm(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Empty(t, driver.forwards)
+}
+
+func TestCmdPortAddInvalidSpec(t *testing.T) {
+	driver := &fakePortForwardDriver{Driver: &fakedriver.Driver{}}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine", "80/sctp"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: driver,
+			},
+		},
+	}
+
+	err := cmdPortAdd(commandLine, api)
+
+	assert.Error(t, err)
+	assert.Empty(t, driver.forwards)
+}
+
+func TestCmdPortNotSupported(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine", "80"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "machine",
+				DriverName: "fake",
+				Driver:     &fakedriver.Driver{},
+			},
+		},
+	}
+
+	err := cmdPortAdd(commandLine, api)
+
+	assert.EqualError(t, err, `Driver "fake" does not support port forwarding`)
+}
+
+func TestPublishedPorts(t *testing.T) {
+	// Rootless podman 1.x followed by root podman 2.x
+	output := `[{"ID":"18fde6761ea5","ports":[{"hostPort":8080,"containerPort":80,"protocol":"tcp","hostIP":""}]}]
+[{"Id":"3a4b","Ports":[{"host_ip":"127.0.0.1","container_port":53,"host_port":5353,"protocol":"udp"},{"host_ip":"127.0.0.1","container_port":443,"host_port":8443,"protocol":"tcp"}]},{"Id":"5c6d","Ports":null}]
+`
+
+	ports, err := publishedPorts(output)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"127.0.0.1:8080": "127.0.0.1:8080",
+		"127.0.0.1:8443": "127.0.0.1:8443",
+	}, ports)
+}
+
+func TestPublishedPortsNoContainers(t *testing.T) {
+	ports, err := publishedPorts("[]\nnull\n")
+
+	assert.NoError(t, err)
+	assert.Empty(t, ports)
+}
+
+type fakeContainerForwarder struct {
+	output   string
+	forwards map[string]string
+	busy     map[string]bool
+}
+
+func (f *fakeContainerForwarder) Output(command string) (string, error) {
+	return f.output, nil
+}
+
+func (f *fakeContainerForwarder) Forward(localAddr, remoteAddr string) (string, error) {
+	if f.busy[localAddr] {
+		return "", errors.New("address already in use")
+	}
+	f.forwards[localAddr] = remoteAddr
+	return localAddr, nil
+}
+
+func (f *fakeContainerForwarder) Cancel(addr string) error {
+	delete(f.forwards, addr)
+	return nil
+}
+
+func TestPortWatcherReconcile(t *testing.T) {
+	forwarder := &fakeContainerForwarder{
+		output:   `[{"ports":[{"hostPort":8080,"protocol":"tcp"},{"hostPort":9090,"protocol":"tcp"}]}]`,
+		forwards: map[string]string{},
+		busy:     map[string]bool{"127.0.0.1:9090": true},
+	}
+	w := newPortWatcher(forwarder)
+
+	w.reconcile()
+
+	assert.Equal(t, map[string]string{"127.0.0.1:8080": "127.0.0.1:8080"}, forwarder.forwards)
+	assert.Equal(t, map[string]bool{"127.0.0.1:9090": true}, w.failed)
+
+	forwarder.output = `[{"ports":[{"hostPort":3000,"protocol":"tcp"}]}]`
+	w.reconcile()
+
+	assert.Equal(t, map[string]string{"127.0.0.1:3000": "127.0.0.1:3000"}, forwarder.forwards)
+	assert.Equal(t, forwarder.forwards, w.active)
+	assert.Empty(t, w.failed)
+}
@@ -3,5 +3,8 @@ package commands
 import "github.com/boot2podman/machine/libmachine"
 
 func cmdProvision(c CommandLine, api libmachine.API) error {
+	if c.Bool("dry-run") {
+		return runAction("dryRunProvision", c, api)
+	}
 	return runAction("provision", c, api)
 }
@@ -62,3 +62,35 @@ func TestCmdProvision(t *testing.T) {
 		assert.Equal(t, tc.expectedErr, cmdProvision(tc.commandLine, tc.api))
 	}
 }
+
+func TestCmdProvisionDryRun(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: provision.NewFakeProvisioner(nil),
+	})
+
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"foo"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"dry-run": true,
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "foo",
+				Driver: &fakedriver.Driver{},
+				HostOptions: &host.Options{
+					EngineOptions: &engine.Options{},
+					AuthOptions:   &auth.Options{},
+				},
+			},
+		},
+	}
+
+	err := cmdProvision(commandLine, api)
+
+	assert.EqualError(t, err, "The fakeprovisioner provisioner doesn't support dry runs")
+}
@@ -0,0 +1,7 @@
+package commands
+
+import "github.com/boot2podman/machine/libmachine"
+
+func cmdResume(c CommandLine, api libmachine.API) error {
+	return runAction("resume", c, api)
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/boot2podman/machine/libmachine"
+	"github.com/boot2podman/machine/libmachine/drivers"
+)
+
+var errNothingToSet = errors.New("Nothing to change, use --cpus, --memory or --disk-size")
+
+func cmdSet(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	r := drivers.Resources{
+		CPUs:     c.Int("cpus"),
+		Memory:   c.Int("memory"),
+		DiskSize: c.Int("disk-size"),
+	}
+	if r == (drivers.Resources{}) {
+		return errNothingToSet
+	}
+	if r.CPUs < 0 || r.Memory < 0 || r.DiskSize < 0 {
+		return fmt.Errorf("Invalid resources: %s", r)
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	err = h.Reconfigure(r, c.Bool("restart"))
+	if err == drivers.ErrRequiresStop {
+		return fmt.Errorf("Machine %q must be stopped to change its resources, stop it first or use --restart", h.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	return api.Save(h)
+}
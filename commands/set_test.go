@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/libmachinetest"
+	"github.com/boot2podman/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeReconfigureDriver struct {
+	*fakedriver.Driver
+	resources drivers.Resources
+}
+
+func (d *fakeReconfigureDriver) Reconfigure(r drivers.Resources) error {
+	if d.MockState != state.Stopped {
+		return drivers.ErrRequiresStop
+	}
+	d.resources = r
+	return nil
+}
+
+func newSetCommandLine(flags map[string]interface{}) *commandstest.FakeCommandLine {
+	return &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: flags,
+		},
+	}
+}
+
+func TestCmdSet(t *testing.T) {
+	driver := &fakeReconfigureDriver{Driver: &fakedriver.Driver{MockState: state.Stopped}}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: driver,
+			},
+		},
+	}
+
+	err := cmdSet(newSetCommandLine(map[string]interface{}{"cpus": 4, "memory": 4096}), api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, drivers.Resources{CPUs: 4, Memory: 4096}, driver.resources)
+}
+
+func TestCmdSetRunningMachine(t *testing.T) {
+	driver := &fakeReconfigureDriver{Driver: &fakedriver.Driver{MockState: state.Running}}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: driver,
+			},
+		},
+	}
+
+	err := cmdSet(newSetCommandLine(map[string]interface{}{"disk-size": 40000}), api)
+
+	assert.EqualError(t, err, `Machine "machine" must be stopped to change its resources, stop it first or use --restart`)
+	assert.Equal(t, drivers.Resources{}, driver.resources)
+}
+
+func TestCmdSetNothingToChange(t *testing.T) {
+	err := cmdSet(newSetCommandLine(map[string]interface{}{}), &libmachinetest.FakeAPI{})
+
+	assert.Equal(t, errNothingToSet, err)
+}
+
+func TestCmdSetNotSupported(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "machine",
+				DriverName: "fake",
+				Driver:     &fakedriver.Driver{MockState: state.Stopped},
+			},
+		},
+	}
+
+	err := cmdSet(newSetCommandLine(map[string]interface{}{"cpus": 2}), api)
+
+	assert.EqualError(t, err, `Driver "fake" does not support changing resources`)
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/boot2podman/machine/libmachine"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/mcnerror"
+)
+
+func cmdSnapshotSave(c CommandLine, api libmachine.API) error {
+	h, name, err := targetHostAndArg(c, api)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Saving snapshot %q of %q...", name, h.Name)
+	return snapshotDo(h, func(s drivers.Snapshotter) error {
+		return s.SaveSnapshot(name)
+	})
+}
+
+func cmdSnapshotRestore(c CommandLine, api libmachine.API) error {
+	h, name, err := targetHostAndArg(c, api)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Restoring %q to snapshot %q...", h.Name, name)
+	return snapshotDo(h, func(s drivers.Snapshotter) error {
+		return s.RestoreSnapshot(name)
+	})
+}
+
+func cmdSnapshotRm(c CommandLine, api libmachine.API) error {
+	h, name, err := targetHostAndArg(c, api)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Removing snapshot %q of %q...", name, h.Name)
+	return snapshotDo(h, func(s drivers.Snapshotter) error {
+		return s.RemoveSnapshot(name)
+	})
+}
+
+func cmdSnapshotLs(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	var snapshots []drivers.Snapshot
+	err = snapshotDo(h, func(s drivers.Snapshotter) error {
+		snapshots, err = s.ListSnapshots()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "NAME\tCREATED")
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(w, "%s\t%s\n", snapshot.Name, snapshot.Created)
+	}
+
+	return nil
+}
+
+func snapshotDo(h *host.Host, action func(drivers.Snapshotter) error) error {
+	notSupported := mcnerror.ErrOperationNotSupported{
+		DriverName: h.DriverName,
+		Operation:  "snapshots",
+	}
+
+	s, ok := h.Driver.(drivers.Snapshotter)
+	if !ok {
+		return notSupported
+	}
+
+	err := action(s)
+	if err == drivers.ErrNotSupported {
+		return notSupported
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSnapshotDriver struct {
+	*fakedriver.Driver
+	snapshots []string
+	restored  string
+}
+
+func (d *fakeSnapshotDriver) SaveSnapshot(name string) error {
+	d.snapshots = append(d.snapshots, name)
+	return nil
+}
+
+func (d *fakeSnapshotDriver) RestoreSnapshot(name string) error {
+	d.restored = name
+	return nil
+}
+
+func (d *fakeSnapshotDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	snapshots := []drivers.Snapshot{}
+	for _, name := range d.snapshots {
+		snapshots = append(snapshots, drivers.Snapshot{Name: name})
+	}
+	return snapshots, nil
+}
+
+func (d *fakeSnapshotDriver) RemoveSnapshot(name string) error {
+	return drivers.ErrNotSupported
+}
+
+func TestCmdSnapshotSave(t *testing.T) {
+	driver := &fakeSnapshotDriver{Driver: &fakedriver.Driver{}}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine", "clean"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: driver,
+			},
+		},
+	}
+
+	err := cmdSnapshotSave(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"clean"}, driver.snapshots)
+}
+
+func TestCmdSnapshotRestoreDefaultMachine(t *testing.T) {
+	driver := &fakeSnapshotDriver{Driver: &fakedriver.Driver{}}
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"clean"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   defaultMachineName,
+				Driver: driver,
+			},
+		},
+	}
+
+	err := cmdSnapshotRestore(commandLine, api)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "clean", driver.restored)
+}
+
+func TestCmdSnapshotMissingName(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{}
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdSnapshotSave(commandLine, api)
+
+	assert.Equal(t, errWrongNumberArguments, err)
+	assert.True(t, commandLine.HelpShown)
+}
+
+func TestCmdSnapshotDriverWithoutSnapshotter(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine", "clean"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "machine",
+				DriverName: "fake",
+				Driver:     &fakedriver.Driver{},
+			},
+		},
+	}
+
+	err := cmdSnapshotSave(commandLine, api)
+
+	assert.EqualError(t, err, `Driver "fake" does not support snapshots`)
+}
+
+func TestCmdSnapshotNotSupportedByPlugin(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine", "clean"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "machine",
+				DriverName: "fake",
+				Driver:     &fakeSnapshotDriver{Driver: &fakedriver.Driver{}},
+			},
+		},
+	}
+
+	err := cmdSnapshotRm(commandLine, api)
+
+	assert.EqualError(t, err, `Driver "fake" does not support snapshots`)
+}
@@ -34,6 +34,10 @@ func (fsc *FakeSSHClientCreator) CreateExternalRootSSHClient(d drivers.Driver) (
 	return nil, nil
 }
 
+func (fsc *FakeSSHClientCreator) CreateNativeSSHClient(d drivers.Driver) (*ssh.NativeClient, error) {
+	return nil, nil
+}
+
 func TestCmdSSH(t *testing.T) {
 	testCases := []struct {
 		commandLine   CommandLine
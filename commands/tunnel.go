@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/boot2podman/machine/libmachine"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/ssh"
+	"github.com/boot2podman/machine/libmachine/state"
+)
+
+// varlinkSocket is the podman varlink socket of the machine, which only root
+// can connect to.
+const varlinkSocket = "/run/podman/io.podman"
+
+func cmdTunnel(c CommandLine, api libmachine.API) error {
+	h, spec, err := targetHostAndArg(c, api)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("varlink") {
+		spec += ":" + varlinkSocket
+	}
+
+	local, remote, err := ssh.ParseTunnel(spec)
+	if err != nil {
+		return err
+	}
+
+	currentState, err := h.Driver.GetState()
+	if err != nil {
+		return err
+	}
+	if currentState != state.Running {
+		return errStateInvalidForSSH{h.Name}
+	}
+
+	client, err := h.CreateNativeSSHClient()
+	if err != nil {
+		return err
+	}
+	if c.Bool("varlink") {
+		client.Config.User = "root"
+	}
+
+	forwarder := ssh.NewForwarder(client)
+	defer forwarder.Close()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	addr, err := forwarder.Tunnel(local, remote)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Forwarding %s to %s on %q, press Ctrl-C to stop...", addr, remote, h.Name)
+	<-signals
+
+	return nil
+}
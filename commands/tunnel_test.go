@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/boot2podman/machine/commands/commandstest"
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/libmachinetest"
+	"github.com/boot2podman/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdTunnelMissingSpec(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{}
+	api := &libmachinetest.FakeAPI{}
+
+	err := cmdTunnel(commandLine, api)
+
+	assert.Equal(t, errWrongNumberArguments, err)
+	assert.True(t, commandLine.HelpShown)
+}
+
+func TestCmdTunnelInvalidSpec(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine", "8080"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: &fakedriver.Driver{MockState: state.Running},
+			},
+		},
+	}
+
+	err := cmdTunnel(commandLine, api)
+
+	assert.EqualError(t, err, `Invalid local address in forward "8080"`)
+}
+
+func TestCmdTunnelVarlinkStopped(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine", "/tmp/podman.sock"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"varlink": true,
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: &fakedriver.Driver{MockState: state.Stopped},
+			},
+		},
+	}
+
+	err := cmdTunnel(commandLine, api)
+
+	assert.Equal(t, errStateInvalidForSSH{"machine"}, err)
+}
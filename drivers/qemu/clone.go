@@ -0,0 +1,234 @@
+package qemu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/mcnutils"
+	"github.com/boot2podman/machine/libmachine/state"
+)
+
+// CreateClone creates the VM with a disk backed by the disk of a stopped
+// VM. A read-only copy of the disk of the source is made in the bases
+// directory of the store, shared by the clones made until the source
+// changes, and removed with the last of them.
+func (d *Driver) CreateClone(source []byte) error {
+	src := &Driver{BaseDriver: &drivers.BaseDriver{}}
+	if err := json.Unmarshal(source, src); err != nil {
+		return err
+	}
+
+	s, err := src.GetState()
+	if err != nil {
+		return err
+	}
+	if s != state.Stopped {
+		return fmt.Errorf("Machine %q must be stopped to be cloned", src.MachineName)
+	}
+
+	if err := d.allocatePorts(); err != nil {
+		return err
+	}
+
+	// The forwards and first boot data of the source are its own.
+	d.PortForwards = nil
+	d.GrowDisk = false
+	d.UserDataFile = ""
+	d.MetaDataFile = ""
+	d.CloudConfigRoot = ""
+	d.CloudInitISO = ""
+	d.DiskPath = d.ResolveStorePath(fmt.Sprintf("%s.img", d.MachineName))
+
+	b2putils := mcnutils.NewB2pUtils(d.StorePath)
+	if err := b2putils.CopyIsoToMachineDir(d.Boot2PodmanURL, d.MachineName); err != nil {
+		return err
+	}
+
+	// The disk of the source only authorizes its key, replaced once the
+	// clone is running.
+	log.Infof("Copying SSH key...")
+	if err := mcnutils.CopyFile(src.sshKeyPath(), d.sshKeyPath()); err != nil {
+		return err
+	}
+	if err := mcnutils.CopyFile(src.publicSSHKeyPath(), d.publicSSHKeyPath()); err != nil {
+		return err
+	}
+
+	log.Infof("Creating Disk image backed by %q...", src.MachineName)
+	if err := d.createCloneDisks(src); err != nil {
+		return err
+	}
+
+	return d.Start()
+}
+
+// createCloneDisks creates the disk of the clone as an overlay of the base
+// of src, leaving the disk of src as it is.
+func (d *Driver) createCloneDisks(src *Driver) error {
+	base, err := src.cloneBase()
+	if err != nil {
+		return err
+	}
+
+	if err := retainBase(base, d.MachineName); err != nil {
+		return err
+	}
+	if err := createOverlay(base, d.diskPath()); err != nil {
+		if err := releaseBase(base, d.MachineName); err != nil {
+			log.Warnf("Error releasing the base %s: %s", base, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// cloneBase returns the base for the clones of the current disk of d, made
+// if there is none yet. It is flattened, so that clones of clones don't
+// stack overlays, and has none of the snapshots of d.
+func (d *Driver) cloneBase() (string, error) {
+	info, err := os.Stat(d.diskPath())
+	if err != nil {
+		return "", err
+	}
+
+	basesDir := filepath.Join(d.StorePath, "bases")
+	if err := os.MkdirAll(basesDir, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Join(basesDir, fmt.Sprintf("%s-%d.qcow2", d.MachineName, info.ModTime().UnixNano()))
+
+	if _, err := os.Stat(base); err == nil {
+		return base, nil
+	}
+
+	tmp := base + ".tmp"
+	if stdout, stderr, err := cmdOutErr("qemu-img", "convert", "-O", "qcow2", d.diskPath(), tmp); err != nil {
+		fmt.Printf("OUTPUT: %s\n", stdout)
+		fmt.Printf("ERROR: %s\n", stderr)
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Chmod(tmp, 0444); err != nil {
+		return "", err
+	}
+	return base, os.Rename(tmp, base)
+}
+
+// baseRefsPath returns the file listing the machines whose disk is backed by
+// base. It is only changed by the commands holding the lock of the store,
+// and is kept apart from the configs of the machines, as they are only saved
+// once a clone succeeded.
+func baseRefsPath(base string) string {
+	return base + ".refs"
+}
+
+func readBaseRefs(base string) ([]string, error) {
+	data, err := ioutil.ReadFile(baseRefsPath(base))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// retainBase records that the disk of the machine name is backed by base.
+func retainBase(base, name string) error {
+	refs, err := readBaseRefs(base)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if ref == name {
+			return nil
+		}
+	}
+	refs = append(refs, name)
+
+	return ioutil.WriteFile(baseRefsPath(base), []byte(strings.Join(refs, "\n")+"\n"), 0644)
+}
+
+// findBase returns the base used by the machine name in the store, if any.
+func findBase(storePath, name string) (string, error) {
+	refsPaths, err := filepath.Glob(baseRefsPath(filepath.Join(storePath, "bases", "*.qcow2")))
+	if err != nil {
+		return "", err
+	}
+
+	for _, refsPath := range refsPaths {
+		base := strings.TrimSuffix(refsPath, ".refs")
+		refs, err := readBaseRefs(base)
+		if err != nil {
+			return "", err
+		}
+		for _, ref := range refs {
+			if ref == name {
+				return base, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// releaseBases releases the bases used by the machine name in the store.
+func releaseBases(storePath, name string) error {
+	refsPaths, err := filepath.Glob(baseRefsPath(filepath.Join(storePath, "bases", "*.qcow2")))
+	if err != nil {
+		return err
+	}
+
+	for _, refsPath := range refsPaths {
+		if err := releaseBase(strings.TrimSuffix(refsPath, ".refs"), name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseBase records that the machine name is gone, and removes base when
+// no other machine uses it.
+func releaseBase(base, name string) error {
+	refs, err := readBaseRefs(base)
+	if err != nil {
+		return err
+	}
+
+	kept := []string{}
+	for _, ref := range refs {
+		if ref != name {
+			kept = append(kept, ref)
+		}
+	}
+	if len(refs) > 0 && len(kept) == len(refs) {
+		return nil
+	}
+	if len(kept) > 0 {
+		return ioutil.WriteFile(baseRefsPath(base), []byte(strings.Join(kept, "\n")+"\n"), 0644)
+	}
+
+	log.Debugf("Removing the unused base %s", base)
+	if err := os.Remove(base); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(baseRefsPath(base)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func createOverlay(base, path string) error {
+	if stdout, stderr, err := cmdOutErr("qemu-img", "create", "-f", "qcow2", "-b", base, "-F", "qcow2", path); err != nil {
+		fmt.Printf("OUTPUT: %s\n", stdout)
+		fmt.Printf("ERROR: %s\n", stderr)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package qemu
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/mcnutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateCloneSavedSource(t *testing.T) {
+	src, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	machineDir := filepath.Join(src.StorePath, "machines", src.MachineName)
+	assert.NoError(t, os.MkdirAll(machineDir, 0755))
+	assert.NoError(t, ioutil.WriteFile(src.savedStatePath(), nil, 0644))
+
+	source, err := json.Marshal(src)
+	assert.NoError(t, err)
+
+	d := NewDriver("clone", src.StorePath).(*Driver)
+	err = d.CreateClone(source)
+
+	assert.EqualError(t, err, `Machine "default" must be stopped to be cloned`)
+}
+
+// fakeQemuImg puts a qemu-img script first in the PATH, which copies the
+// disks it converts, and writes the backing file in the overlays it creates
+// or rebases.
+func fakeQemuImg(t *testing.T) func() {
+	if runtime.GOOS == "windows" {
+		t.Skip("no shell scripts on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "qemu-img")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := `#!/bin/sh
+case "$1" in
+convert) cp "$4" "$5" ;;
+create) echo "$5" > "$8" ;;
+rebase) echo "$4" > "$7" ;;
+esac
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "qemu-img"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	return func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCreateCloneDisks(t *testing.T) {
+	defer fakeQemuImg(t)()
+
+	src, cleanup := newRunningDriver(t)
+	defer cleanup()
+	assert.NoError(t, ioutil.WriteFile(src.diskPath(), []byte("source disk"), 0644))
+
+	var clones []*Driver
+	for _, name := range []string{"box2", "box3"} {
+		d := NewDriver(name, src.StorePath).(*Driver)
+		assert.NoError(t, os.MkdirAll(d.ResolveStorePath("."), 0700))
+		assert.NoError(t, d.createCloneDisks(src))
+		clones = append(clones, d)
+	}
+
+	// The source is left alone, and its clones share a copy of its disk
+	data, err := ioutil.ReadFile(src.diskPath())
+	assert.NoError(t, err)
+	assert.Equal(t, "source disk", string(data))
+
+	bases, err := filepath.Glob(filepath.Join(src.StorePath, "bases", "*.qcow2"))
+	assert.NoError(t, err)
+	assert.Len(t, bases, 1)
+	data, err = ioutil.ReadFile(bases[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "source disk", string(data))
+
+	for _, d := range clones {
+		data, err := ioutil.ReadFile(d.diskPath())
+		assert.NoError(t, err)
+		assert.Equal(t, bases[0]+"\n", string(data))
+	}
+	refs, err := readBaseRefs(bases[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"box2", "box3"}, refs)
+
+	// The base goes with the last clone
+	assert.NoError(t, clones[0].Remove())
+	_, err = os.Stat(bases[0])
+	assert.NoError(t, err)
+
+	assert.NoError(t, clones[1].Remove())
+	_, err = os.Stat(bases[0])
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(baseRefsPath(bases[0]))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExportImportClone(t *testing.T) {
+	defer fakeQemuImg(t)()
+
+	src, cleanup := newRunningDriver(t)
+	defer cleanup()
+	assert.NoError(t, ioutil.WriteFile(src.diskPath(), []byte("source disk"), 0644))
+
+	d := NewDriver("box2", src.StorePath).(*Driver)
+	assert.NoError(t, os.MkdirAll(d.ResolveStorePath("."), 0700))
+	assert.NoError(t, d.createCloneDisks(src))
+
+	files, err := src.ExportFiles()
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	files, err = d.ExportFiles()
+	assert.NoError(t, err)
+	base, err := findBase(d.StorePath, d.MachineName)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{exportedBase: base}, files)
+
+	// The imported clone is backed by the base in its directory
+	assert.NoError(t, mcnutils.CopyFile(base, d.ResolveStorePath(exportedBase)))
+	assert.NoError(t, d.Relocate())
+
+	data, err := ioutil.ReadFile(d.diskPath())
+	assert.NoError(t, err)
+	assert.Equal(t, d.ResolveStorePath(exportedBase)+"\n", string(data))
+}
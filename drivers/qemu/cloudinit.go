@@ -0,0 +1,269 @@
+package qemu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/log"
+)
+
+const (
+	// cloudInitConfigDrive shares an OpenStack config drive layout with
+	// the guest over virtio-9p, using the config-2 mount tag.
+	cloudInitConfigDrive = "config-2"
+
+	// cloudInitNoCloud attaches an ISO image labelled cidata, which is
+	// read by the NoCloud datasource of cloud-init.
+	cloudInitNoCloud = "nocloud"
+
+	cloudConfigDirname   = "cloud-config"
+	cloudInitISOFilename = "cidata.iso"
+)
+
+// isoTools are the programs tried, in order, to build the NoCloud image.
+var isoTools = []struct {
+	program string
+	args    func(output, dir string) []string
+}{
+	{"genisoimage", mkisofsArgs},
+	{"mkisofs", mkisofsArgs},
+	{"xorriso", func(output, dir string) []string {
+		return append([]string{"-as", "mkisofs"}, mkisofsArgs(output, dir)...)
+	}},
+	{"hdiutil", func(output, dir string) []string {
+		return []string{"makehybrid", "-iso", "-joliet", "-default-volume-name", "cidata", "-o", output, dir}
+	}},
+}
+
+func mkisofsArgs(output, dir string) []string {
+	return []string{"-output", output, "-volid", "cidata", "-joliet", "-rock", dir}
+}
+
+// findISOTool returns the program and arguments building an ISO image of
+// dir at output.
+func findISOTool(output, dir string) (string, []string, error) {
+	for _, tool := range isoTools {
+		if path, err := exec.LookPath(tool.program); err == nil {
+			return path, tool.args(output, dir), nil
+		}
+	}
+
+	names := []string{}
+	for _, tool := range isoTools {
+		names = append(names, tool.program)
+	}
+	return "", nil, fmt.Errorf("Creating a NoCloud image requires one of: %s", strings.Join(names, ", "))
+}
+
+// hasCloudInit reports whether the machine gets cloud-init data. Cloud images
+// always do, as the meta-data is what authorizes the SSH key of the machine.
+func (d *Driver) hasCloudInit() bool {
+	return d.UserDataFile != "" || d.MetaDataFile != "" || d.CloudImage != ""
+}
+
+// checkCloudInit validates the cloud-init options before the machine is
+// created.
+func (d *Driver) checkCloudInit() error {
+	if !d.hasCloudInit() {
+		return nil
+	}
+
+	switch d.CloudInitDatasource {
+	case cloudInitConfigDrive, cloudInitNoCloud:
+	default:
+		return fmt.Errorf("Invalid cloud-init datasource %q, must be %s or %s", d.CloudInitDatasource, cloudInitConfigDrive, cloudInitNoCloud)
+	}
+
+	for _, path := range []string{d.UserDataFile, d.MetaDataFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("Cannot read cloud-init data: %s", err)
+		}
+	}
+
+	if d.CloudImage != "" {
+		if d.CloudInitDatasource != cloudInitNoCloud {
+			return fmt.Errorf("Cloud images need the %s cloud-init datasource", cloudInitNoCloud)
+		}
+		if _, err := os.Stat(d.CloudImage); err != nil {
+			return fmt.Errorf("Cannot read cloud image: %s", err)
+		}
+	}
+
+	if d.CloudInitDatasource == cloudInitNoCloud {
+		if _, _, err := findISOTool("", ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generateCloudInit writes the user-data and meta-data into the machine
+// directory, either as a config drive directory or as a NoCloud image.
+func (d *Driver) generateCloudInit() error {
+	if !d.hasCloudInit() {
+		return nil
+	}
+
+	userData := []byte{}
+	if d.UserDataFile != "" {
+		data, err := ioutil.ReadFile(d.UserDataFile)
+		if err != nil {
+			return err
+		}
+		userData = data
+	}
+
+	metaData, err := d.metaData()
+	if err != nil {
+		return err
+	}
+
+	root := d.ResolveStorePath(cloudConfigDirname)
+	if err := os.RemoveAll(root); err != nil {
+		return err
+	}
+
+	if d.CloudInitDatasource == cloudInitConfigDrive {
+		latest := filepath.Join(root, "openstack", "latest")
+		if err := os.MkdirAll(latest, 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(latest, "user_data"), userData, 0644); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(latest, "meta_data.json"), metaData, 0644); err != nil {
+			return err
+		}
+		d.CloudConfigRoot = root
+		return nil
+	}
+
+	// The directory only stages the files of the image.
+	defer os.RemoveAll(root)
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "user-data"), userData, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "meta-data"), metaData, 0644); err != nil {
+		return err
+	}
+
+	isoPath := d.ResolveStorePath(cloudInitISOFilename)
+	program, args, err := findISOTool(isoPath, root)
+	if err != nil {
+		return err
+	}
+	if stdout, stderr, err := cmdOutErr(program, args...); err != nil {
+		fmt.Printf("OUTPUT: %s\n", stdout)
+		fmt.Printf("ERROR: %s\n", stderr)
+		return err
+	}
+	log.Debugf("DONE writing cloud-init image %s", isoPath)
+
+	d.CloudInitISO = isoPath
+	return nil
+}
+
+// metaData returns the meta-data given by the user, or a default one
+// naming the instance and authorizing the machine SSH key. The default is
+// JSON, which is read by both datasources.
+func (d *Driver) metaData() ([]byte, error) {
+	if d.MetaDataFile != "" {
+		return ioutil.ReadFile(d.MetaDataFile)
+	}
+
+	pubKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimSpace(string(pubKey))
+
+	var metaData interface{}
+	if d.CloudInitDatasource == cloudInitConfigDrive {
+		metaData = map[string]interface{}{
+			"uuid":        d.MachineName,
+			"name":        d.MachineName,
+			"hostname":    d.MachineName,
+			"public_keys": map[string]string{"machine": key},
+		}
+	} else {
+		metaData = map[string]interface{}{
+			"instance-id":    d.MachineName,
+			"local-hostname": d.MachineName,
+			"public-keys":    []string{key},
+		}
+	}
+
+	data, err := json.MarshalIndent(metaData, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// cloudInitOptions returns the qemu options attaching the cloud-init data.
+func (d *Driver) cloudInitOptions() []string {
+	var options []string
+
+	if d.CloudConfigRoot != "" {
+		options = append(options,
+			"-fsdev",
+			fmt.Sprintf("local,security_model=passthrough,readonly,id=fsdev0,path=%s", d.CloudConfigRoot))
+		options = append(options, "-device", "virtio-9p-pci,id=fs0,fsdev=fsdev0,mount_tag=config-2")
+	}
+
+	if d.CloudInitISO != "" {
+		drive := fmt.Sprintf("file=%s,index=3,media=cdrom", d.CloudInitISO)
+		if d.VirtioDrives {
+			drive += ",if=virtio"
+		}
+		options = append(options, "-drive", drive)
+	}
+
+	return options
+}
+
+// generateCloudImageDisk copies the cloud image into the disk of the machine,
+// and grows it to size MB if it is smaller. The filesystem is grown by
+// cloud-init on first boot.
+func (d *Driver) generateCloudImageDisk(size int) error {
+	if stdout, stderr, err := cmdOutErr("qemu-img", "convert", "-O", "qcow2", d.CloudImage, d.diskPath()); err != nil {
+		fmt.Printf("OUTPUT: %s\n", stdout)
+		fmt.Printf("ERROR: %s\n", stderr)
+		return err
+	}
+
+	stdout, _, err := cmdOutErr("qemu-img", "info", "--output=json", d.diskPath())
+	if err != nil {
+		return err
+	}
+	var info struct {
+		VirtualSize int64 `json:"virtual-size"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &info); err != nil {
+		return fmt.Errorf("Error reading the size of %s: %s", d.diskPath(), err)
+	}
+
+	if info.VirtualSize >= int64(size)*1024*1024 {
+		log.Debugf("Cloud image is already %d bytes, not resizing it", info.VirtualSize)
+		return nil
+	}
+	if stdout, stderr, err := cmdOutErr("qemu-img", "resize", d.diskPath(), fmt.Sprintf("%dM", size)); err != nil {
+		fmt.Printf("OUTPUT: %s\n", stdout)
+		fmt.Printf("ERROR: %s\n", stderr)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package qemu
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPublicKey = "ssh-rsa AAAAB3NzaC1yc2E test"
+
+func newCloudInitDriver(t *testing.T, datasource string) (*Driver, func()) {
+	d, cleanup := newStoppedDriver(t)
+	d.CloudInitDatasource = datasource
+
+	if err := os.MkdirAll(d.ResolveStorePath("."), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(d.publicSSHKeyPath(), []byte(testPublicKey+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return d, cleanup
+}
+
+func writeTempFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGenerateConfigDrive(t *testing.T) {
+	d, cleanup := newCloudInitDriver(t, cloudInitConfigDrive)
+	defer cleanup()
+
+	d.UserDataFile = writeTempFile(t, d.StorePath, "user-data", "#!/bin/sh\necho hello\n")
+
+	assert.NoError(t, d.checkCloudInit())
+	assert.NoError(t, d.generateCloudInit())
+
+	root := d.ResolveStorePath("cloud-config")
+	assert.Equal(t, root, d.CloudConfigRoot)
+	assert.Empty(t, d.CloudInitISO)
+
+	userData, err := ioutil.ReadFile(filepath.Join(root, "openstack", "latest", "user_data"))
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\necho hello\n", string(userData))
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "openstack", "latest", "meta_data.json"))
+	assert.NoError(t, err)
+
+	var metaData map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &metaData))
+	assert.Equal(t, "default", metaData["hostname"])
+	assert.Equal(t, map[string]interface{}{"machine": testPublicKey}, metaData["public_keys"])
+
+	assert.Equal(t, []string{
+		"-fsdev", "local,security_model=passthrough,readonly,id=fsdev0,path=" + root,
+		"-device", "virtio-9p-pci,id=fs0,fsdev=fsdev0,mount_tag=config-2",
+	}, d.cloudInitOptions())
+}
+
+func TestGenerateConfigDriveWithMetaData(t *testing.T) {
+	d, cleanup := newCloudInitDriver(t, cloudInitConfigDrive)
+	defer cleanup()
+
+	d.MetaDataFile = writeTempFile(t, d.StorePath, "meta-data", `{"hostname": "custom"}`)
+
+	assert.NoError(t, d.generateCloudInit())
+
+	latest := filepath.Join(d.CloudConfigRoot, "openstack", "latest")
+
+	metaData, err := ioutil.ReadFile(filepath.Join(latest, "meta_data.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"hostname": "custom"}`, string(metaData))
+
+	userData, err := ioutil.ReadFile(filepath.Join(latest, "user_data"))
+	assert.NoError(t, err)
+	assert.Empty(t, userData)
+}
+
+func TestNoCloudMetaData(t *testing.T) {
+	d, cleanup := newCloudInitDriver(t, cloudInitNoCloud)
+	defer cleanup()
+
+	data, err := d.metaData()
+	assert.NoError(t, err)
+
+	var metaData map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &metaData))
+	assert.Equal(t, map[string]interface{}{
+		"instance-id":    "default",
+		"local-hostname": "default",
+		"public-keys":    []interface{}{testPublicKey},
+	}, metaData)
+}
+
+func TestNoCloudOptions(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	d.CloudInitISO = "/machines/default/cidata.iso"
+
+	assert.Equal(t, []string{"-drive", "file=/machines/default/cidata.iso,index=3,media=cdrom"}, d.cloudInitOptions())
+
+	d.VirtioDrives = true
+
+	assert.Equal(t, []string{"-drive", "file=/machines/default/cidata.iso,index=3,media=cdrom,if=virtio"}, d.cloudInitOptions())
+}
+
+func TestCheckCloudInit(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	assert.NoError(t, d.checkCloudInit())
+	assert.Empty(t, d.cloudInitOptions())
+
+	d.UserDataFile = filepath.Join(d.StorePath, "missing")
+	d.CloudInitDatasource = cloudInitConfigDrive
+	assert.Error(t, d.checkCloudInit())
+
+	d.UserDataFile = writeTempFile(t, d.StorePath, "user-data", "#cloud-config\n")
+	d.CloudInitDatasource = "floppy"
+	assert.EqualError(t, d.checkCloudInit(), `Invalid cloud-init datasource "floppy", must be config-2 or nocloud`)
+}
+
+func TestCloudImage(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	assert.Equal(t, []string{"-boot", "d", "-cdrom", filepath.Join(d.StorePath, "machines", "default", "boot2podman.iso")}, d.bootOptions())
+
+	d.CloudImage = filepath.Join(d.StorePath, "missing.qcow2")
+	d.CloudInitDatasource = cloudInitNoCloud
+
+	assert.True(t, d.hasCloudInit())
+	assert.Empty(t, d.bootOptions())
+	assert.EqualError(t, d.checkCloudInit(), "Cannot read cloud image: stat "+d.CloudImage+": no such file or directory")
+
+	d.CloudInitDatasource = cloudInitConfigDrive
+	assert.EqualError(t, d.checkCloudInit(), "Cloud images need the nocloud cloud-init datasource")
+}
@@ -0,0 +1,123 @@
+package qemu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/state"
+)
+
+// AddPortForward adds a hostfwd rule to the user network. Rules are passed
+// to qemu on start, and added with hostfwd_add when the VM is running.
+func (d *Driver) AddPortForward(f drivers.PortForward) error {
+	if d.Network != "user" {
+		return fmt.Errorf("Port forwarding requires the user network, machine uses %q", d.Network)
+	}
+
+	if f.Protocol == "tcp" && f.HostPort == d.SSHPort {
+		return fmt.Errorf("Host port %d is used for SSH", f.HostPort)
+	}
+	if d.findPortForward(f) >= 0 {
+		return fmt.Errorf("Host port %d/%s is already forwarded", f.HostPort, f.Protocol)
+	}
+
+	running, err := d.hasProcess()
+	if err != nil {
+		return err
+	}
+	if running {
+		if err := d.hostfwdCommand("hostfwd_add", hostfwdRule(f)); err != nil {
+			return err
+		}
+	}
+
+	d.PortForwards = append(d.PortForwards, f)
+	return nil
+}
+
+// RemovePortForward removes the hostfwd rule for the host port of f.
+func (d *Driver) RemovePortForward(f drivers.PortForward) error {
+	i := d.findPortForward(f)
+	if i < 0 {
+		return fmt.Errorf("Host port %d/%s is not forwarded", f.HostPort, f.Protocol)
+	}
+
+	running, err := d.hasProcess()
+	if err != nil {
+		return err
+	}
+	if running {
+		rule := fmt.Sprintf("%s:127.0.0.1:%d", f.Protocol, f.HostPort)
+		if err := d.hostfwdCommand("hostfwd_remove", rule); err != nil {
+			return err
+		}
+	}
+
+	d.PortForwards = append(d.PortForwards[:i], d.PortForwards[i+1:]...)
+	return nil
+}
+
+func (d *Driver) ListPortForwards() ([]drivers.PortForward, error) {
+	return d.PortForwards, nil
+}
+
+func (d *Driver) findPortForward(f drivers.PortForward) int {
+	for i, existing := range d.PortForwards {
+		if existing.Protocol == f.Protocol && existing.HostPort == f.HostPort {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasProcess reports whether qemu is running, whether or not the VM is
+// paused.
+func (d *Driver) hasProcess() (bool, error) {
+	s, err := d.GetState()
+	if err != nil {
+		return false, err
+	}
+	return s == state.Running || s == state.Paused, nil
+}
+
+// hostfwdCommand runs hostfwd_add or hostfwd_remove on the user network.
+// The human monitor reports failures as output rather than as errors.
+func (d *Driver) hostfwdCommand(cmd, rule string) error {
+	m, err := d.qmpMonitor()
+	if err != nil {
+		return err
+	}
+
+	// The user network is named n0 with -netdev, and user.0 on vlan 0
+	// with -net.
+	netID := "n0"
+	if d.NetVlan {
+		netID = "0 user.0"
+	}
+
+	output, err := m.HumanMonitorCommand(fmt.Sprintf("%s %s %s", cmd, netID, rule))
+	if err != nil {
+		return err
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		return fmt.Errorf("%s %s failed: %s", cmd, rule, output)
+	}
+	return nil
+}
+
+// hostfwdRule returns the rule forwarding the host port of f, on the
+// loopback interface only, to the guest.
+func hostfwdRule(f drivers.PortForward) string {
+	return fmt.Sprintf("%s:127.0.0.1:%d-:%d", f.Protocol, f.HostPort, f.GuestPort)
+}
+
+// hostfwdOptions returns the hostfwd options of the user network: SSH and
+// every configured forward.
+func (d *Driver) hostfwdOptions() string {
+	options := []string{fmt.Sprintf("hostfwd=tcp::%d-:22", d.SSHPort)}
+	for _, f := range d.PortForwards {
+		options = append(options, "hostfwd="+hostfwdRule(f))
+	}
+	return strings.Join(options, ",")
+}
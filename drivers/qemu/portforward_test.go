@@ -0,0 +1,75 @@
+package qemu
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStoppedDriver(t *testing.T) (*Driver, func()) {
+	storePath, err := ioutil.TempDir("", "qemu-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDriver("default", storePath).(*Driver)
+	d.Network = "user"
+	d.SSHPort = 2222
+
+	return d, func() { os.RemoveAll(storePath) }
+}
+
+func TestPortForwardsStopped(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	web := drivers.PortForward{Protocol: "tcp", HostPort: 8080, GuestPort: 80}
+	dns := drivers.PortForward{Protocol: "udp", HostPort: 5353, GuestPort: 53}
+
+	assert.NoError(t, d.AddPortForward(web))
+	assert.NoError(t, d.AddPortForward(dns))
+
+	forwards, err := d.ListPortForwards()
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.PortForward{web, dns}, forwards)
+	assert.Equal(t, "hostfwd=tcp::2222-:22,hostfwd=tcp:127.0.0.1:8080-:80,hostfwd=udp:127.0.0.1:5353-:53", d.hostfwdOptions())
+
+	assert.NoError(t, d.RemovePortForward(drivers.PortForward{Protocol: "tcp", HostPort: 8080}))
+
+	forwards, err = d.ListPortForwards()
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.PortForward{dns}, forwards)
+}
+
+func TestAddPortForwardConflicts(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	assert.NoError(t, d.AddPortForward(drivers.PortForward{Protocol: "tcp", HostPort: 8080, GuestPort: 80}))
+
+	assert.Error(t, d.AddPortForward(drivers.PortForward{Protocol: "tcp", HostPort: 8080, GuestPort: 8080}))
+	assert.Error(t, d.AddPortForward(drivers.PortForward{Protocol: "tcp", HostPort: 2222, GuestPort: 22}))
+	assert.NoError(t, d.AddPortForward(drivers.PortForward{Protocol: "udp", HostPort: 8080, GuestPort: 80}))
+}
+
+func TestPortForwardRequiresUserNetwork(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+	d.Network = "tap"
+
+	err := d.AddPortForward(drivers.PortForward{Protocol: "tcp", HostPort: 8080, GuestPort: 80})
+
+	assert.Error(t, err)
+}
+
+func TestRemoveUnknownPortForward(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	err := d.RemovePortForward(drivers.PortForward{Protocol: "tcp", HostPort: 8080})
+
+	assert.EqualError(t, err, "Host port 8080/tcp is not forwarded")
+}
@@ -3,7 +3,7 @@ package qemu
 import (
 	"archive/tar"
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -13,9 +13,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/boot2podman/machine/drivers/qemu/qmp"
 	"github.com/boot2podman/machine/libmachine/drivers"
 	"github.com/boot2podman/machine/libmachine/log"
 	"github.com/boot2podman/machine/libmachine/mcnflag"
@@ -29,8 +31,16 @@ const (
 	privateNetworkName = "podman-machines"
 
 	defaultSSHUser = "tc"
+
+	// defaultStopTimeout is the number of seconds Stop waits for the
+	// guest to power off before killing it.
+	defaultStopTimeout = 60
 )
 
+// killTimeout is how long Kill waits for qemu to exit after quit, and after
+// SIGKILL.
+var killTimeout = 5 * time.Second
+
 type Driver struct {
 	*drivers.BaseDriver
 	EnginePort int
@@ -60,10 +70,23 @@ type Driver struct {
 	connectionString string
 	//	conn             *libvirt.Connect
 	//	VM               *libvirt.Domain
-	vmLoaded        bool
-	UserDataFile    string
-	CloudConfigRoot string
-	LocalPorts      string
+	vmLoaded            bool
+	UserDataFile        string
+	MetaDataFile        string
+	CloudInitDatasource string
+	CloudConfigRoot     string
+	CloudInitISO        string
+	CloudImage          string
+	LocalPorts          string
+	StopTimeout         int
+	PortForwards        []drivers.PortForward
+	GrowDisk            bool
+	SharedFolders       []drivers.SharedFolder
+
+	// monitorMu guards monitor, as the RPC server runs calls concurrently,
+	// e.g. GetState while Stop waits for qemu to shut down
+	monitorMu sync.Mutex
+	monitor   *qmp.Monitor
 }
 
 func (d *Driver) GetCreateFlags() []mcnflag.Flag {
@@ -160,6 +183,32 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   "qemu-localports",
 			Usage:  "Port range to bind local SSH and engine ports",
 		},
+		mcnflag.IntFlag{
+			Name:  "qemu-stop-timeout",
+			Usage: "Seconds to wait for the VM to power off on stop before killing it",
+			Value: defaultStopTimeout,
+		},
+		mcnflag.StringFlag{
+			Name:  "qemu-user-data",
+			Usage: "Path to a cloud-init user-data file run on first boot",
+		},
+		mcnflag.StringFlag{
+			Name:  "qemu-meta-data",
+			Usage: "Path to a cloud-init meta-data file, generated if not given",
+		},
+		mcnflag.StringFlag{
+			Name:  "qemu-cloud-init-datasource",
+			Usage: "How cloud-init data is passed to the VM: config-2 (virtio-9p share) or nocloud (cidata ISO)",
+			Value: cloudInitConfigDrive,
+		},
+		mcnflag.StringFlag{
+			Name:  "qemu-cloud-image",
+			Usage: "Path to a cloud image (qcow2 or raw) booted as the disk instead of the boot2podman ISO",
+		},
+		mcnflag.StringSliceFlag{
+			Name:  "qemu-share-folder",
+			Usage: "Share a host directory with the VM over virtio-9p. Format: hostdir[:guestdir]",
+		},
 		/* Not yet implemented
 		mcnflag.Flag{
 			Name:  "qemu-no-share",
@@ -224,6 +273,18 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 
 	d.SSHUser = flags.String("qemu-ssh-user")
 	d.LocalPorts = flags.String("qemu-localports")
+	d.StopTimeout = flags.Int("qemu-stop-timeout")
+	d.UserDataFile = flags.String("qemu-user-data")
+	d.MetaDataFile = flags.String("qemu-meta-data")
+	d.CloudInitDatasource = flags.String("qemu-cloud-init-datasource")
+	d.CloudImage = flags.String("qemu-cloud-image")
+	if d.CloudImage != "" {
+		// Cloud images read their seed from a cidata ISO, not a 9p share
+		d.CloudInitDatasource = cloudInitNoCloud
+	}
+	if err := d.setSharedFolders(flags.StringSlice("qemu-share-folder")); err != nil {
+		return err
+	}
 	d.FirstQuery = true
 	d.SSHPort = 22
 	d.DiskPath = d.ResolveStorePath(fmt.Sprintf("%s.img", d.MachineName))
@@ -264,6 +325,18 @@ func (d *Driver) GetIP() (string, error) {
 	return d.NetworkAddress, nil
 }
 
+// readPid returns the pid recorded in the pidfile, or 0 if there is none.
+func (d *Driver) readPid() (int, error) {
+	p, err := ioutil.ReadFile(d.pidfilePath())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(p)))
+}
+
 func checkPid(pid int) error {
 	process, err := os.FindProcess(pid)
 	if err != nil {
@@ -273,33 +346,28 @@ func checkPid(pid int) error {
 }
 
 func (d *Driver) GetState() (state.State, error) {
-
-	if _, err := os.Stat(d.pidfilePath()); err != nil {
-		return state.Stopped, nil
-	}
-	p, err := ioutil.ReadFile(d.pidfilePath())
+	pid, err := d.readPid()
 	if err != nil {
 		return state.Error, err
 	}
-	pid, err := strconv.Atoi(strings.TrimSpace(string(p)))
-	if err != nil {
-		return state.Error, err
+	if pid == 0 {
+		return d.stoppedState(), nil
 	}
 	if err := checkPid(pid); err != nil {
 		// No pid, remove pidfile
 		os.Remove(d.pidfilePath())
-		return state.Stopped, nil
+		d.closeQMPMonitor()
+		return d.stoppedState(), nil
 	}
-	ret, err := d.RunQMPCommand("query-status")
+	m, err := d.qmpMonitor()
 	if err != nil {
 		return state.Error, err
 	}
-	// RunState is one of:
-	// 'debug', 'inmigrate', 'internal-error', 'io-error', 'paused',
-	// 'postmigrate', 'prelaunch', 'finish-migrate', 'restore-vm',
-	// 'running', 'save-vm', 'shutdown', 'suspended', 'watchdog',
-	// 'guest-panicked'
-	switch ret["status"] {
+	status, err := m.QueryStatus()
+	if err != nil {
+		return state.Error, err
+	}
+	switch status.Status {
 	case "running":
 		return state.Running, nil
 	case "paused":
@@ -310,51 +378,125 @@ func (d *Driver) GetState() (state.State, error) {
 	return state.None, nil
 }
 
+// stoppedState returns the state of a VM without a qemu process, which is
+// Saved if its state was saved to disk.
+func (d *Driver) stoppedState() state.State {
+	if _, err := os.Stat(d.savedStatePath()); err == nil {
+		return state.Saved
+	}
+	return state.Stopped
+}
+
 func (d *Driver) PreCreateCheck() error {
-	return nil
+	if err := d.checkSharedFolders(); err != nil {
+		return err
+	}
+	return d.checkCloudInit()
 }
 
-func (d *Driver) Create() error {
-	if d.Network == "user" {
-		minPort, maxPort, err := parsePortRange(d.LocalPorts)
-		log.Debugf("port range: %d -> %d", minPort, maxPort)
+// allocatePorts picks the host ports forwarded to SSH and the engine, with
+// the user network.
+func (d *Driver) allocatePorts() error {
+	if d.Network != "user" {
+		return nil
+	}
+
+	minPort, maxPort, err := parsePortRange(d.LocalPorts)
+	log.Debugf("port range: %d -> %d", minPort, maxPort)
+	if err != nil {
+		return err
+	}
+	d.SSHPort, err = getAvailableTCPPortFromRange(minPort, maxPort)
+	if err != nil {
+		return err
+	}
+
+	for {
+		d.EnginePort, err = getAvailableTCPPortFromRange(minPort, maxPort)
 		if err != nil {
 			return err
 		}
-		d.SSHPort, err = getAvailableTCPPortFromRange(minPort, maxPort)
-		if err != nil {
-			return err
+		if d.EnginePort == d.SSHPort {
+			// can't have both on same port
+			continue
 		}
+		break
+	}
 
-		for {
-			d.EnginePort, err = getAvailableTCPPortFromRange(minPort, maxPort)
-			if err != nil {
-				return err
-			}
-			if d.EnginePort == d.SSHPort {
-				// can't have both on same port
-				continue
-			}
-			break
-		}
+	return nil
+}
+
+// logProgress returns a context logging the progress events, for the calls
+// made without a context.
+func logProgress() context.Context {
+	return drivers.WithProgress(context.Background(), func(p drivers.Progress) {
+		log.Infof("%s...", p.Message)
+	})
+}
+
+// reportPhase reports the phase of create or start about to run, unless ctx
+// is done.
+func reportPhase(ctx context.Context, phase, message string) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	b2putils := mcnutils.NewB2pUtils(d.StorePath)
-	if err := b2putils.CopyIsoToMachineDir(d.Boot2PodmanURL, d.MachineName); err != nil {
+	drivers.ReportProgress(ctx, drivers.Progress{Phase: phase, Percent: -1, Message: message})
+	return nil
+}
+
+func (d *Driver) Create() error {
+	return d.CreateContext(logProgress())
+}
+
+// CreateContext creates the machine, and stops before the next phase when
+// ctx is done.
+func (d *Driver) CreateContext(ctx context.Context) error {
+	if err := d.allocatePorts(); err != nil {
 		return err
 	}
+	if d.CloudImage == "" {
+		if err := reportPhase(ctx, "iso", "Copying the boot2podman ISO"); err != nil {
+			return err
+		}
+		b2putils := mcnutils.NewB2pUtils(d.StorePath)
+		if err := b2putils.CopyIsoToMachineDir(d.Boot2PodmanURL, d.MachineName); err != nil {
+			return err
+		}
+	}
 
-	log.Infof("Creating SSH key...")
+	if err := reportPhase(ctx, "ssh-key", "Creating SSH key"); err != nil {
+		return err
+	}
 	if err := ssh.GenerateSSHKey(d.sshKeyPath()); err != nil {
 		return err
 	}
 
-	log.Infof("Creating Disk image...")
-	if err := d.generateDiskImage(d.DiskSize); err != nil {
-		return err
+	if d.hasCloudInit() {
+		if err := reportPhase(ctx, "cloud-init", "Creating cloud-init data"); err != nil {
+			return err
+		}
+		if err := d.generateCloudInit(); err != nil {
+			return err
+		}
 	}
 
-	log.Infof("Starting QEMU VM...")
-	return d.Start()
+	if d.CloudImage != "" {
+		if err := reportPhase(ctx, "disk", fmt.Sprintf("Creating Disk image from %s", d.CloudImage)); err != nil {
+			return err
+		}
+		if err := d.generateCloudImageDisk(d.DiskSize); err != nil {
+			return err
+		}
+	} else {
+		if err := reportPhase(ctx, "disk", "Creating Disk image"); err != nil {
+			return err
+		}
+		if err := d.generateDiskImage(d.DiskSize); err != nil {
+			return err
+		}
+	}
+
+	return d.StartContext(ctx)
 }
 
 func parsePortRange(rawPortRange string) (int, int, error) {
@@ -423,9 +565,38 @@ func getAvailableTCPPortFromRange(minPort int, maxPort int) (int, error) {
 	return 0, fmt.Errorf("unable to allocate tcp port")
 }
 
+// bootOptions returns the qemu options booting the boot2podman ISO. Cloud
+// images boot from their disk.
+func (d *Driver) bootOptions() []string {
+	if d.CloudImage != "" {
+		return nil
+	}
+
+	machineDir := filepath.Join(d.StorePath, "machines", d.GetMachineName())
+	isoPath := filepath.Join(machineDir, isoFilename)
+
+	options := []string{"-boot", "d"}
+	if d.VirtioDrives {
+		options = append(options,
+			"-drive", fmt.Sprintf("file=%s,index=2,media=cdrom,if=virtio", isoPath))
+	} else {
+		options = append(options,
+			"-cdrom", isoPath)
+	}
+	return options
+}
+
 func (d *Driver) Start() error {
+	return d.StartContext(logProgress())
+}
+
+// StartContext starts the machine. When ctx is done before the machine is up,
+// it is killed, so that it is left stopped.
+func (d *Driver) StartContext(ctx context.Context) error {
 	// fmt.Printf("Init qemu %s\n", i.VM)
-	machineDir := filepath.Join(d.StorePath, "machines", d.GetMachineName())
+	if err := reportPhase(ctx, "qemu", "Starting QEMU VM"); err != nil {
+		return err
+	}
 
 	var startCmd []string
 
@@ -451,16 +622,8 @@ func (d *Driver) Start() error {
 
 	startCmd = append(startCmd,
 		"-m", fmt.Sprintf("%d", d.Memory),
-		"-smp", fmt.Sprintf("%d", d.CPU),
-		"-boot", "d")
-	var isoPath = filepath.Join(machineDir, isoFilename)
-	if d.VirtioDrives {
-		startCmd = append(startCmd,
-			"-drive", fmt.Sprintf("file=%s,index=2,media=cdrom,if=virtio", isoPath))
-	} else {
-		startCmd = append(startCmd,
-			"-cdrom", isoPath)
-	}
+		"-smp", fmt.Sprintf("%d", d.CPU))
+	startCmd = append(startCmd, d.bootOptions()...)
 	startCmd = append(startCmd,
 		"-qmp", fmt.Sprintf("unix:%s,server,nowait", d.monitorPath()),
 		"-pidfile", d.pidfilePath(),
@@ -470,7 +633,7 @@ func (d *Driver) Start() error {
 		if d.Network == "user" {
 			startCmd = append(startCmd,
 				"-net", "nic,vlan=0,model=virtio",
-				"-net", fmt.Sprintf("user,vlan=0,hostfwd=tcp::%d-:22,hostname=%s", d.SSHPort, d.GetMachineName()),
+				"-net", fmt.Sprintf("user,vlan=0,%s,hostname=%s", d.hostfwdOptions(), d.GetMachineName()),
 			)
 		} else if d.Network == "tap" {
 			startCmd = append(startCmd,
@@ -489,7 +652,7 @@ func (d *Driver) Start() error {
 		if d.Network == "user" {
 			startCmd = append(startCmd,
 				"-device", "virtio-net,netdev=n0",
-				"-netdev", fmt.Sprintf("user,id=n0,hostfwd=tcp::%d-:22,hostname=%s", d.SSHPort, d.GetMachineName()),
+				"-netdev", fmt.Sprintf("user,id=n0,%s,hostname=%s", d.hostfwdOptions(), d.GetMachineName()),
 			)
 		} else if d.Network == "tap" {
 			startCmd = append(startCmd,
@@ -506,6 +669,12 @@ func (d *Driver) Start() error {
 		}
 	}
 
+	restoring := false
+	if _, err := os.Stat(d.savedStatePath()); err == nil {
+		startCmd = append(startCmd, "-incoming", fmt.Sprintf("exec:cat '%s'", d.savedStatePath()))
+		restoring = true
+	}
+
 	startCmd = append(startCmd, "-daemonize")
 
 	if d.HVF {
@@ -519,12 +688,8 @@ func (d *Driver) Start() error {
 		startCmd = append(startCmd, "-enable-kvm")
 	}
 
-	if d.CloudConfigRoot != "" {
-		startCmd = append(startCmd,
-			"-fsdev",
-			fmt.Sprintf("local,security_model=passthrough,readonly,id=fsdev0,path=%s", d.CloudConfigRoot))
-		startCmd = append(startCmd, "-device", "virtio-9p-pci,id=fs0,fsdev=fsdev0,mount_tag=config-2")
-	}
+	startCmd = append(startCmd, d.cloudInitOptions()...)
+	startCmd = append(startCmd, d.sharedFolderOptions()...)
 
 	if d.VirtioDrives {
 		startCmd = append(startCmd,
@@ -541,10 +706,52 @@ func (d *Driver) Start() error {
 		//if err := cmdStart(d.Program, startCmd...); err != nil {
 		//	return err
 	}
-	log.Infof("Waiting for VM to start (ssh -p %d %s@localhost)...", d.SSHPort, d.GetSSHUsername())
 
+	if err := d.waitForStart(ctx, restoring); err != nil {
+		if ctx.Err() != nil {
+			// The saved state is kept, a restore can be tried again
+			log.Debugf("Stopping the VM, its start was interrupted: %s", err)
+			if err := d.terminate(); err != nil {
+				log.Warnf("Failed to stop the VM: %s", err)
+			}
+		}
+		return err
+	}
+
+	if d.GrowDisk {
+		drivers.ReportProgress(ctx, drivers.Progress{Phase: "disk", Percent: -1, Message: "Growing the filesystem to the new disk size"})
+		if err := drivers.GrowFilesystem(d); err != nil {
+			log.Warnf("Failed to grow the filesystem: %s", err)
+		}
+		d.GrowDisk = false
+	}
+
+	if len(d.SharedFolders) > 0 {
+		if err := d.mountSharedFolders(); err != nil {
+			log.Warnf("Failed to mount the shared folders: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// waitForStart waits for the VM to restore its saved state, if any, and for
+// its SSH server to be up.
+func (d *Driver) waitForStart(ctx context.Context, restoring bool) error {
+	if restoring {
+		if err := reportPhase(ctx, "restore", "Restoring VM state"); err != nil {
+			return err
+		}
+		if err := d.finishRestore(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := reportPhase(ctx, "boot", fmt.Sprintf("Waiting for VM to start (ssh -p %d %s@localhost)", d.SSHPort, d.GetSSHUsername())); err != nil {
+		return err
+	}
 	//return ssh.WaitForTCP(fmt.Sprintf("localhost:%d", d.SSHPort))
-	return WaitForTCPWithDelay(fmt.Sprintf("localhost:%d", d.SSHPort), time.Second)
+	return waitForTCPContext(ctx, fmt.Sprintf("localhost:%d", d.SSHPort), time.Second)
 }
 
 func cmdOutErr(cmdStr string, args ...string) (string, string, error) {
@@ -578,57 +785,259 @@ func cmdStart(cmdStr string, args ...string) error {
 	return cmd.Start()
 }
 
+// Stop asks the guest to power off through ACPI, and kills the VM if it has
+// not shut down within StopTimeout seconds.
 func (d *Driver) Stop() error {
-	// _, err := d.RunQMPCommand("stop")
-	_, err := d.RunQMPCommand("system_powerdown")
+	m, err := d.qmpMonitor()
+	if err != nil {
+		return err
+	}
+
+	// A paused guest cannot react to the ACPI event
+	if status, err := m.QueryStatus(); err == nil && status.Status == "paused" {
+		if err := m.Cont(); err != nil {
+			return err
+		}
+	}
+
+	shutdown, unsubscribe := m.Subscribe("SHUTDOWN")
+	defer unsubscribe()
+
+	if err := m.SystemPowerdown(); err != nil {
+		return err
+	}
+
+	timeout := time.Duration(d.StopTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultStopTimeout * time.Second
+	}
+
+	select {
+	case <-shutdown:
+	case <-m.Done():
+	case <-time.After(timeout):
+		log.Warnf("Machine did not power off within %s, killing it...", timeout)
+		return d.Kill()
+	}
+
+	pid, err := d.readPid()
 	if err != nil {
 		return err
 	}
+	if pid != 0 && !waitForExit(pid, killTimeout) {
+		log.Warnf("QEMU process %d did not exit after shutdown, killing it...", pid)
+		return d.Kill()
+	}
+
+	d.cleanup()
 	return nil
 }
 
 func (d *Driver) Remove() error {
+	s, err := d.GetState()
+	if err != nil {
+		// The monitor may be wedged, make sure the process goes away
+		log.Debugf("Error getting state before removal: %s", err)
+		err = d.Kill()
+	} else if s != state.Stopped {
+		err = d.Kill()
+	}
+	if err != nil {
+		return err
+	}
+
+	return releaseBases(d.StorePath, d.MachineName)
+}
+
+func (d *Driver) Restart() error {
 	s, err := d.GetState()
 	if err != nil {
 		return err
 	}
+
 	if s == state.Running {
-		if err := d.Kill(); err != nil {
+		if err := d.Stop(); err != nil {
 			return err
 		}
 	}
-	if s != state.Stopped {
-		_, err = d.RunQMPCommand("quit")
+	return d.Start()
+}
+
+// Kill terminates qemu, and discards the saved state of the VM, if any.
+func (d *Driver) Kill() error {
+	if err := d.terminate(); err != nil {
+		return err
+	}
+
+	// A killed VM cannot be restored anymore
+	if err := os.Remove(d.savedStatePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// terminate stops qemu through the QMP quit command, and sends SIGKILL to the
+// process recorded in the pidfile if the monitor does not respond.
+func (d *Driver) terminate() error {
+	pid, err := d.readPid()
+	if err != nil {
+		return err
+	}
+
+	if err := d.quit(); err != nil {
+		log.Debugf("QMP quit failed: %s", err)
+	}
+
+	if pid != 0 && !waitForExit(pid, killTimeout) {
+		log.Infof("QEMU monitor is unresponsive, sending SIGKILL to process %d...", pid)
+		process, err := os.FindProcess(pid)
 		if err != nil {
 			return err
 		}
+		if err := process.Kill(); err != nil {
+			return err
+		}
+		if !waitForExit(pid, killTimeout) {
+			return fmt.Errorf("QEMU process %d did not exit after SIGKILL", pid)
+		}
 	}
+
+	d.cleanup()
 	return nil
 }
 
-func (d *Driver) Restart() error {
-	s, err := d.GetState()
+func (d *Driver) Pause() error {
+	m, err := d.qmpMonitor()
 	if err != nil {
 		return err
 	}
+	return m.Stop()
+}
 
-	if s == state.Running {
-		if err := d.Stop(); err != nil {
+func (d *Driver) Resume() error {
+	m, err := d.qmpMonitor()
+	if err != nil {
+		return err
+	}
+	return m.Cont()
+}
+
+// SaveState migrates the VM into a file in the machine directory, from which
+// the next Start restores it.
+func (d *Driver) SaveState() error {
+	m, err := d.qmpMonitor()
+	if err != nil {
+		return err
+	}
+
+	if err := m.Stop(); err != nil {
+		return err
+	}
+
+	log.Debugf("Saving VM state to %s", d.savedStatePath())
+	if err := m.Migrate(fmt.Sprintf("exec:cat > '%s'", d.savedStatePath())); err != nil {
+		os.Remove(d.savedStatePath())
+		return err
+	}
+
+	for {
+		info, err := m.QueryMigrate()
+		if err != nil {
+			os.Remove(d.savedStatePath())
 			return err
 		}
+		if info.Status == "completed" {
+			break
+		}
+		if info.Status == "failed" || info.Status == "cancelled" {
+			os.Remove(d.savedStatePath())
+			if err := m.Cont(); err != nil {
+				log.Debugf("Error resuming VM after failed save: %s", err)
+			}
+			return fmt.Errorf("Saving VM state failed: %s", info.ErrorDesc)
+		}
+		time.Sleep(500 * time.Millisecond)
 	}
-	return d.Start()
-}
 
-func (d *Driver) Kill() error {
-	// _, err := d.RunQMPCommand("quit")
-	_, err := d.RunQMPCommand("system_powerdown")
+	pid, err := d.readPid()
 	if err != nil {
 		return err
 	}
+	if err := d.quit(); err != nil {
+		log.Debugf("QMP quit failed: %s", err)
+	}
+	if pid != 0 && !waitForExit(pid, killTimeout) {
+		return fmt.Errorf("QEMU process %d did not exit after saving its state", pid)
+	}
+
+	d.cleanup()
 	return nil
 }
 
+// finishRestore waits for qemu to load the saved state given with -incoming,
+// continues the VM and discards the saved state.
+func (d *Driver) finishRestore(ctx context.Context) error {
+	m, err := d.qmpMonitor()
+	if err != nil {
+		return err
+	}
+
+	for {
+		status, err := m.QueryStatus()
+		if err != nil {
+			return err
+		}
+		if status.Status != "inmigrate" {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	if err := m.Cont(); err != nil {
+		return err
+	}
+
+	return os.Remove(d.savedStatePath())
+}
+
+func (d *Driver) quit() error {
+	m, err := d.qmpMonitor()
+	if err != nil {
+		return err
+	}
+	defer d.closeQMPMonitor()
+
+	m.SetTimeout(killTimeout)
+	return m.Quit()
+}
+
+// cleanup removes the files qemu leaves behind in the machine directory.
+func (d *Driver) cleanup() {
+	d.closeQMPMonitor()
+	for _, path := range []string{d.pidfilePath(), d.monitorPath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Debugf("Error removing %s: %s", path, err)
+		}
+	}
+}
+
+// waitForExit polls until the process is gone, and reports whether it
+// exited within the timeout.
+func waitForExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for checkPid(pid) == nil {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
+
 func (d *Driver) Upgrade() error {
 	return fmt.Errorf("hosts without a driver cannot be upgraded")
 }
@@ -656,6 +1065,11 @@ func (d *Driver) monitorPath() string {
 	return filepath.Join(machineDir, "monitor")
 }
 
+func (d *Driver) savedStatePath() string {
+	machineDir := filepath.Join(d.StorePath, "machines", d.GetMachineName())
+	return filepath.Join(machineDir, "saved-state")
+}
+
 func (d *Driver) pidfilePath() string {
 	machineDir := filepath.Join(d.StorePath, "machines", d.GetMachineName())
 	return filepath.Join(machineDir, "qemu.pid")
@@ -723,107 +1137,91 @@ func (d *Driver) generateDiskImage(size int) error {
 	return nil
 }
 
-func (d *Driver) RunQMPCommand(command string) (map[string]interface{}, error) {
-
-	// connect to monitor
-	conn, err := net.Dial("unix", d.monitorPath())
-	if err != nil {
-		return nil, err
+// qmpMonitor returns the connection to the QMP monitor of the running VM,
+// (re)connecting if there is none yet or qemu closed the previous one.
+func (d *Driver) qmpMonitor() (*qmp.Monitor, error) {
+	d.monitorMu.Lock()
+	defer d.monitorMu.Unlock()
+
+	if d.monitor != nil {
+		select {
+		case <-d.monitor.Done():
+			d.monitor = nil
+		default:
+			return d.monitor, nil
+		}
 	}
-	defer conn.Close()
 
-	// initial QMP response
-	var buf [1024]byte
-	nr, err := conn.Read(buf[:])
-	if err != nil {
-		return nil, err
-	}
-	type qmpInitialResponse struct {
-		QMP struct {
-			Version struct {
-				QEMU struct {
-					Micro int `json:"micro"`
-					Minor int `json:"minor"`
-					Major int `json:"major"`
-				} `json:"qemu"`
-				Package string `json:"package"`
-			} `json:"version"`
-			Capabilities []string `json:"capabilities"`
-		} `jason:"QMP"`
-	}
-
-	var initialResponse qmpInitialResponse
-	json.Unmarshal(buf[:nr], &initialResponse)
-
-	// run 'qmp_capabilities' to switch to command mode
-	// { "execute": "qmp_capabilities" }
-	type qmpCommand struct {
-		Command string `json:"execute"`
-	}
-	jsonCommand, err := json.Marshal(qmpCommand{Command: "qmp_capabilities"})
-	if err != nil {
-		return nil, err
-	}
-	_, err = conn.Write(jsonCommand)
-	if err != nil {
-		return nil, err
-	}
-	nr, err = conn.Read(buf[:])
-	if err != nil {
-		return nil, err
-	}
-	type qmpResponse struct {
-		Return map[string]interface{} `json:"return"`
-	}
-	var response qmpResponse
-	err = json.Unmarshal(buf[:nr], &response)
+	m, err := qmp.Dial(d.monitorPath(), qmp.DefaultTimeout)
 	if err != nil {
 		return nil, err
 	}
-	// expecting empty response
-	if len(response.Return) != 0 {
-		return nil, fmt.Errorf("qmp_capabilities failed: %v", response.Return)
-	}
+	d.monitor = m
+	return m, nil
+}
 
-	// { "execute": command }
-	jsonCommand, err = json.Marshal(qmpCommand{Command: command})
-	if err != nil {
-		return nil, err
-	}
-	_, err = conn.Write(jsonCommand)
-	if err != nil {
-		return nil, err
+// closeQMPMonitor drops the connection to the QMP monitor, if any.
+func (d *Driver) closeQMPMonitor() {
+	d.monitorMu.Lock()
+	defer d.monitorMu.Unlock()
+
+	if d.monitor != nil {
+		d.monitor.Close()
+		d.monitor = nil
 	}
-	nr, err = conn.Read(buf[:])
+}
+
+func (d *Driver) RunQMPCommand(command string) (map[string]interface{}, error) {
+	m, err := d.qmpMonitor()
 	if err != nil {
 		return nil, err
 	}
-	err = json.Unmarshal(buf[:nr], &response)
-	if err != nil {
+
+	var ret map[string]interface{}
+	if err := m.Run(command, nil, &ret); err != nil {
 		return nil, err
 	}
-	if strings.HasPrefix(command, "query-") {
-		return response.Return, nil
-	}
-	// non-query commands should return an empty response
-	if len(response.Return) != 0 {
-		return nil, fmt.Errorf("%s failed: %v", command, response.Return)
-	}
-	return response.Return, nil
+	return ret, nil
 }
 
 func WaitForTCPWithDelay(addr string, duration time.Duration) error {
+	return waitForTCPContext(context.Background(), addr, duration)
+}
+
+// readContext reads a byte from conn and closes it, or closes it early when
+// ctx is done.
+func readContext(ctx context.Context, conn net.Conn) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	_, err := conn.Read(make([]byte, 1))
+	conn.Close()
+	return err
+}
+
+// waitForTCPContext waits until the server at addr sends something, as SSH
+// servers do first, or ctx is done.
+func waitForTCPContext(ctx context.Context, addr string, duration time.Duration) error {
+	var dialer net.Dialer
 	for {
-		conn, err := net.Dial("tcp", addr)
-		if err != nil {
-			continue
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			err = readContext(ctx, conn)
+			if err == nil {
+				return nil
+			}
 		}
-		defer conn.Close()
-		if _, err = conn.Read(make([]byte, 1)); err != nil {
-			time.Sleep(duration)
-			continue
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(duration):
 		}
-		break
 	}
-	return nil
 }
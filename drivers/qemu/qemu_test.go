@@ -0,0 +1,392 @@
+package qemu
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMonitor is a minimal QMP server listening on the monitor socket of a
+// driver. Handlers run when their command is received, and may send events
+// after the reply.
+type fakeMonitor struct {
+	t        *testing.T
+	listener net.Listener
+	handlers map[string]func(m *fakeMonitor)
+
+	mu       sync.Mutex
+	conn     net.Conn
+	received []string
+	status   string
+}
+
+func newFakeMonitor(t *testing.T, d *Driver, handlers map[string]func(m *fakeMonitor)) *fakeMonitor {
+	listener, err := net.Listen("unix", d.monitorPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &fakeMonitor{
+		t:        t,
+		listener: listener,
+		handlers: handlers,
+	}
+	go m.serve()
+
+	return m
+}
+
+func (m *fakeMonitor) Close() {
+	m.listener.Close()
+	m.mu.Lock()
+	if m.conn != nil {
+		m.conn.Close()
+	}
+	m.mu.Unlock()
+}
+
+func (m *fakeMonitor) serve() {
+	conn, err := m.listener.Accept()
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.conn = conn
+	m.mu.Unlock()
+
+	m.send(map[string]interface{}{
+		"QMP": map[string]interface{}{
+			"version":      map[string]interface{}{"qemu": map[string]int{"major": 2, "minor": 11, "micro": 1}},
+			"capabilities": []string{},
+		},
+	})
+
+	dec := json.NewDecoder(conn)
+	for {
+		var cmd struct {
+			Execute string `json:"execute"`
+			ID      string `json:"id"`
+		}
+		if err := dec.Decode(&cmd); err != nil {
+			return
+		}
+
+		m.mu.Lock()
+		m.received = append(m.received, cmd.Execute)
+		m.mu.Unlock()
+
+		reply := map[string]interface{}{"return": map[string]interface{}{}}
+		if cmd.ID != "" {
+			reply["id"] = cmd.ID
+		}
+		if cmd.Execute == "query-status" {
+			reply["return"] = map[string]interface{}{"status": m.vmStatus(), "running": true}
+		}
+		m.send(reply)
+
+		if handler, ok := m.handlers[cmd.Execute]; ok {
+			handler(m)
+		}
+	}
+}
+
+// setStatus sets the status of the VM replied to query-status, running
+// unless set.
+func (m *fakeMonitor) setStatus(status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status = status
+}
+
+func (m *fakeMonitor) vmStatus() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.status == "" {
+		return "running"
+	}
+	return m.status
+}
+
+func (m *fakeMonitor) send(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.conn.Write(append(data, '\r', '\n'))
+}
+
+func (m *fakeMonitor) sendEvent(name string) {
+	m.send(map[string]interface{}{
+		"event":     name,
+		"timestamp": map[string]int64{"seconds": 1500000000, "microseconds": 42},
+	})
+}
+
+func (m *fakeMonitor) commands() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string{}, m.received...)
+}
+
+// startFakeQemu starts a process standing in for qemu, and records its pid
+// in the pidfile of the driver. The process is reaped as soon as it exits,
+// so that waitForExit sees it gone.
+func startFakeQemu(t *testing.T, d *Driver) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		t.Skip("no sleep command on windows")
+	}
+
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go cmd.Wait()
+
+	if err := ioutil.WriteFile(d.pidfilePath(), []byte(strconv.Itoa(cmd.Process.Pid)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return cmd
+}
+
+func newRunningDriver(t *testing.T) (*Driver, func()) {
+	d, cleanup := newStoppedDriver(t)
+	if err := os.MkdirAll(d.ResolveStorePath("."), 0700); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return d, cleanup
+}
+
+func TestStopShutsDown(t *testing.T) {
+	d, cleanup := newRunningDriver(t)
+	defer cleanup()
+
+	qemu := startFakeQemu(t, d)
+	m := newFakeMonitor(t, d, map[string]func(m *fakeMonitor){
+		"system_powerdown": func(m *fakeMonitor) {
+			qemu.Process.Kill()
+			m.sendEvent("SHUTDOWN")
+		},
+	})
+	defer m.Close()
+
+	assert.NoError(t, d.Stop())
+	assert.Equal(t, []string{"qmp_capabilities", "query-status", "system_powerdown"}, m.commands())
+
+	_, err := os.Stat(d.pidfilePath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStopTimeoutKills(t *testing.T) {
+	d, cleanup := newRunningDriver(t)
+	defer cleanup()
+	d.StopTimeout = 1
+
+	qemu := startFakeQemu(t, d)
+	m := newFakeMonitor(t, d, map[string]func(m *fakeMonitor){
+		"quit": func(m *fakeMonitor) {
+			qemu.Process.Kill()
+		},
+	})
+	defer m.Close()
+
+	assert.NoError(t, d.Stop())
+	assert.Equal(t, []string{"qmp_capabilities", "query-status", "system_powerdown", "quit"}, m.commands())
+
+	_, err := os.Stat(d.pidfilePath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGetStateConcurrent(t *testing.T) {
+	d, cleanup := newRunningDriver(t)
+	defer cleanup()
+
+	qemu := startFakeQemu(t, d)
+	m := newFakeMonitor(t, d, map[string]func(m *fakeMonitor){
+		"quit": func(m *fakeMonitor) {
+			qemu.Process.Kill()
+		},
+	})
+	defer m.Close()
+
+	// The RPC server runs the calls of the client concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := d.GetState()
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.NoError(t, d.Kill())
+}
+
+func TestKillUnresponsiveMonitor(t *testing.T) {
+	defer func(timeout time.Duration) { killTimeout = timeout }(killTimeout)
+	killTimeout = 500 * time.Millisecond
+
+	d, cleanup := newRunningDriver(t)
+	defer cleanup()
+
+	qemu := startFakeQemu(t, d)
+
+	// There is no monitor, so the process gets SIGKILL
+	assert.NoError(t, d.Kill())
+	assert.Error(t, checkPid(qemu.Process.Pid))
+
+	_, err := os.Stat(d.pidfilePath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestKillDeadProcess(t *testing.T) {
+	d, cleanup := newRunningDriver(t)
+	defer cleanup()
+
+	qemu := startFakeQemu(t, d)
+	qemu.Process.Kill()
+	assert.True(t, waitForExit(qemu.Process.Pid, killTimeout))
+
+	assert.NoError(t, d.Kill())
+
+	_, err := os.Stat(d.pidfilePath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCreateContextCancelled(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, d.CreateContext(ctx))
+
+	_, err := os.Stat(d.diskPath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStartContextCancelled(t *testing.T) {
+	d, cleanup := newRunningDriver(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, d.StartContext(ctx))
+
+	_, err := os.Stat(d.pidfilePath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStartContextInterruptedRestore(t *testing.T) {
+	d, cleanup := newRunningDriver(t)
+	defer cleanup()
+
+	// qemu daemonizes, the process standing in for it is already running
+	program := d.ResolveStorePath("qemu")
+	if err := ioutil.WriteFile(program, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	d.Program = program
+	if err := ioutil.WriteFile(d.savedStatePath(), []byte("state"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qemu := startFakeQemu(t, d)
+	m := newFakeMonitor(t, d, map[string]func(m *fakeMonitor){
+		"quit": func(m *fakeMonitor) {
+			qemu.Process.Kill()
+		},
+	})
+	defer m.Close()
+	m.setStatus("inmigrate")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, d.StartContext(ctx))
+	assert.Contains(t, m.commands(), "quit")
+	assert.NotContains(t, m.commands(), "cont")
+
+	_, err := os.Stat(d.pidfilePath())
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(d.savedStatePath())
+	assert.NoError(t, err)
+}
+
+// listenSSH listens on a local port standing in for the SSH server of a
+// driver, which greets its clients unless silent.
+func listenSSH(t *testing.T, d *Driver, silent bool) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SSHPort = listener.Addr().(*net.TCPAddr).Port
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if !silent {
+				conn.Write([]byte("SSH-2.0-OpenSSH\r\n"))
+			}
+			defer conn.Close()
+		}
+	}()
+
+	return listener
+}
+
+func TestWaitForStartReportsProgress(t *testing.T) {
+	d, cleanup := newRunningDriver(t)
+	defer cleanup()
+
+	listener := listenSSH(t, d, false)
+	defer listener.Close()
+
+	var phases []string
+	ctx := drivers.WithProgress(context.Background(), func(p drivers.Progress) {
+		phases = append(phases, p.Phase)
+	})
+
+	assert.NoError(t, d.waitForStart(ctx, false))
+	assert.Equal(t, []string{"boot"}, phases)
+}
+
+func TestWaitForStartTimeout(t *testing.T) {
+	d, cleanup := newRunningDriver(t)
+	defer cleanup()
+
+	listener := listenSSH(t, d, true)
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, d.waitForStart(ctx, false))
+}
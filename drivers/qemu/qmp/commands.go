@@ -0,0 +1,89 @@
+package qmp
+
+// Status is the reply to query-status.
+type Status struct {
+	Running    bool `json:"running"`
+	Singlestep bool `json:"singlestep"`
+	// Status is one of:
+	// 'debug', 'inmigrate', 'internal-error', 'io-error', 'paused',
+	// 'postmigrate', 'prelaunch', 'finish-migrate', 'restore-vm',
+	// 'running', 'save-vm', 'shutdown', 'suspended', 'watchdog',
+	// 'guest-panicked'
+	Status string `json:"status"`
+}
+
+// QueryStatus returns the run state of the virtual machine.
+func (m *Monitor) QueryStatus() (*Status, error) {
+	var status Status
+	if err := m.Run("query-status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SystemPowerdown requests an ACPI shutdown of the guest.
+func (m *Monitor) SystemPowerdown() error {
+	return m.Run("system_powerdown", nil, nil)
+}
+
+// SystemReset resets the virtual machine.
+func (m *Monitor) SystemReset() error {
+	return m.Run("system_reset", nil, nil)
+}
+
+// Stop pauses the virtual machine.
+func (m *Monitor) Stop() error {
+	return m.Run("stop", nil, nil)
+}
+
+// Cont resumes a paused virtual machine.
+func (m *Monitor) Cont() error {
+	return m.Run("cont", nil, nil)
+}
+
+// Quit terminates qemu immediately. The connection is closed by qemu, so an
+// ErrClosed reply is not treated as a failure.
+func (m *Monitor) Quit() error {
+	if err := m.Run("quit", nil, nil); err != nil && err != ErrClosed {
+		return err
+	}
+	return nil
+}
+
+// HumanMonitorCommand runs a command of the human monitor (HMP) and returns
+// its output.
+func (m *Monitor) HumanMonitorCommand(cmdline string) (string, error) {
+	var output string
+	args := map[string]interface{}{
+		"command-line": cmdline,
+	}
+	if err := m.Run("human-monitor-command", args, &output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// MigrationInfo is the reply to query-migrate.
+type MigrationInfo struct {
+	// Status is one of:
+	// 'none', 'setup', 'cancelling', 'cancelled', 'active',
+	// 'postcopy-active', 'completed', 'failed', 'colo',
+	// 'pre-switchover'
+	Status    string `json:"status"`
+	ErrorDesc string `json:"error-desc"`
+}
+
+// Migrate starts migrating the virtual machine to uri, e.g. "exec:cat > f".
+// Use QueryMigrate to follow its progress.
+func (m *Monitor) Migrate(uri string) error {
+	return m.Run("migrate", map[string]interface{}{"uri": uri}, nil)
+}
+
+// QueryMigrate returns the status of the current migration.
+func (m *Monitor) QueryMigrate() (*MigrationInfo, error) {
+	var info MigrationInfo
+	if err := m.Run("query-migrate", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,400 @@
+// Package qmp implements a client for the QEMU Machine Protocol.
+//
+// A Monitor keeps a single connection to the QMP socket of a running qemu
+// process. Commands may be issued concurrently, replies are correlated to
+// their command by id, and asynchronous events (SHUTDOWN, RESET, STOP, ...)
+// are delivered to subscribers.
+package qmp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/boot2podman/machine/libmachine/log"
+)
+
+const (
+	// DefaultTimeout is used for the handshake and for commands when no
+	// other timeout was set on the Monitor.
+	DefaultTimeout = 10 * time.Second
+
+	eventBufferSize = 16
+)
+
+var (
+	// ErrClosed is returned for commands issued on, or interrupted by, a
+	// closed connection.
+	ErrClosed = errors.New("qmp: monitor connection closed")
+
+	// ErrTimeout is returned when qemu did not reply within the timeout.
+	ErrTimeout = errors.New("qmp: timed out waiting for reply")
+)
+
+// Error is an error reply sent by qemu for a failed command.
+type Error struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("qmp: %s: %s", e.Class, e.Desc)
+}
+
+// Version is the qemu version announced in the QMP greeting.
+type Version struct {
+	QEMU struct {
+		Micro int `json:"micro"`
+		Minor int `json:"minor"`
+		Major int `json:"major"`
+	} `json:"qemu"`
+	Package string `json:"package"`
+}
+
+// Greeting is the first message qemu sends on a new connection.
+type Greeting struct {
+	Version      Version  `json:"version"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// Event is an asynchronous notification sent by qemu.
+type Event struct {
+	Name      string
+	Data      map[string]interface{}
+	Timestamp time.Time
+}
+
+type command struct {
+	Execute   string      `json:"execute"`
+	Arguments interface{} `json:"arguments,omitempty"`
+	ID        string      `json:"id,omitempty"`
+}
+
+type response struct {
+	Return json.RawMessage
+	Error  *Error
+}
+
+// message is the union of every kind of message qemu can send.
+type message struct {
+	QMP       *Greeting       `json:"QMP"`
+	Event     string          `json:"event"`
+	Data      json.RawMessage `json:"data"`
+	Timestamp struct {
+		Seconds      int64 `json:"seconds"`
+		Microseconds int64 `json:"microseconds"`
+	} `json:"timestamp"`
+	Return json.RawMessage `json:"return"`
+	Error  *Error          `json:"error"`
+	ID     string          `json:"id"`
+}
+
+type subscriber struct {
+	ch    chan Event
+	names map[string]bool
+}
+
+// Monitor is a connection to a QMP socket.
+type Monitor struct {
+	// Timeout bounds how long a command waits for its reply. Once the
+	// monitor is in use, it is changed with SetTimeout.
+	Timeout time.Duration
+
+	greeting Greeting
+
+	conn    net.Conn
+	dec     *json.Decoder
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[string]chan response
+	subs    map[int]*subscriber
+	nextSub int
+	err     error
+	done    chan struct{}
+}
+
+// Dial connects to the QMP unix socket at path, performs the capabilities
+// negotiation and returns a Monitor in command mode.
+func Dial(path string, timeout time.Duration) (*Monitor, error) {
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := NewMonitor(conn, timeout)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// NewMonitor performs the QMP handshake on an established connection.
+func NewMonitor(conn net.Conn, timeout time.Duration) (*Monitor, error) {
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	m := &Monitor{
+		Timeout: timeout,
+		conn:    conn,
+		dec:     json.NewDecoder(conn),
+		pending: map[string]chan response{},
+		subs:    map[int]*subscriber{},
+		done:    make(chan struct{}),
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var msg message
+	if err := m.dec.Decode(&msg); err != nil {
+		return nil, fmt.Errorf("qmp: reading greeting: %s", err)
+	}
+	if msg.QMP == nil {
+		return nil, errors.New("qmp: unexpected greeting from server")
+	}
+	m.greeting = *msg.QMP
+
+	// Switch from capabilities negotiation to command mode. No reader is
+	// running yet, so the reply is read synchronously.
+	if err := m.write(command{Execute: "qmp_capabilities"}); err != nil {
+		return nil, err
+	}
+	for {
+		msg = message{}
+		if err := m.dec.Decode(&msg); err != nil {
+			return nil, fmt.Errorf("qmp: negotiating capabilities: %s", err)
+		}
+		if msg.Event != "" {
+			continue
+		}
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		break
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	go m.readLoop()
+
+	return m, nil
+}
+
+// Greeting returns the greeting received when the connection was opened.
+func (m *Monitor) Greeting() Greeting {
+	return m.greeting
+}
+
+// Close closes the connection. Pending commands fail with ErrClosed and
+// all event channels are closed.
+func (m *Monitor) Close() error {
+	err := m.conn.Close()
+	<-m.done
+	return err
+}
+
+// Done is closed once the connection has been closed, either by Close or
+// because qemu went away.
+func (m *Monitor) Done() <-chan struct{} {
+	return m.done
+}
+
+// Err returns the error that terminated the connection, if any.
+func (m *Monitor) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Subscribe returns a channel receiving the events with the given names, or
+// every event when no name is given. The returned function unsubscribes and
+// closes the channel. Events are dropped for subscribers that do not keep up.
+func (m *Monitor) Subscribe(names ...string) (<-chan Event, func()) {
+	sub := &subscriber{
+		ch:    make(chan Event, eventBufferSize),
+		names: map[string]bool{},
+	}
+	for _, name := range names {
+		sub.names[name] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+
+	id := m.nextSub
+	m.nextSub++
+	m.subs[id] = sub
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if _, ok := m.subs[id]; ok {
+				delete(m.subs, id)
+				close(sub.ch)
+			}
+		})
+	}
+}
+
+// SetTimeout changes the timeout of the commands run from now on, while
+// others may be running.
+func (m *Monitor) SetTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	m.Timeout = timeout
+	m.mu.Unlock()
+}
+
+// Execute runs a command with optional arguments and returns the raw
+// "return" value of the reply.
+func (m *Monitor) Execute(name string, args interface{}) (json.RawMessage, error) {
+	replyCh := make(chan response, 1)
+
+	m.mu.Lock()
+	if m.err != nil {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	m.nextID++
+	id := strconv.FormatUint(m.nextID, 10)
+	m.pending[id] = replyCh
+	timeout := m.Timeout
+	m.mu.Unlock()
+
+	log.Debugf("qmp: executing %s", name)
+
+	if err := m.write(command{Execute: name, Arguments: args, ID: id}); err != nil {
+		m.forget(id)
+		return nil, err
+	}
+
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	select {
+	case reply := <-replyCh:
+		if reply.Error != nil {
+			return nil, reply.Error
+		}
+		return reply.Return, nil
+	case <-m.done:
+		return nil, ErrClosed
+	case <-time.After(timeout):
+		m.forget(id)
+		return nil, ErrTimeout
+	}
+}
+
+// Run runs a command and decodes its "return" value into ret, which may be
+// nil for commands whose result is not interesting.
+func (m *Monitor) Run(name string, args interface{}, ret interface{}) error {
+	raw, err := m.Execute(name, args)
+	if err != nil {
+		return err
+	}
+	if ret == nil || len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, ret)
+}
+
+func (m *Monitor) forget(id string) {
+	m.mu.Lock()
+	delete(m.pending, id)
+	m.mu.Unlock()
+}
+
+func (m *Monitor) write(cmd command) error {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	_, err = m.conn.Write(append(data, '\n'))
+	return err
+}
+
+func (m *Monitor) readLoop() {
+	var err error
+	for {
+		var msg message
+		if err = m.dec.Decode(&msg); err != nil {
+			break
+		}
+
+		switch {
+		case msg.Event != "":
+			m.dispatch(msg)
+		case msg.ID != "":
+			m.mu.Lock()
+			replyCh, ok := m.pending[msg.ID]
+			delete(m.pending, msg.ID)
+			m.mu.Unlock()
+			if ok {
+				replyCh <- response{Return: msg.Return, Error: msg.Error}
+			}
+		default:
+			log.Debugf("qmp: ignoring uncorrelated message")
+		}
+	}
+
+	m.mu.Lock()
+	m.err = err
+	for id, sub := range m.subs {
+		close(sub.ch)
+		delete(m.subs, id)
+	}
+	m.pending = map[string]chan response{}
+	m.mu.Unlock()
+
+	m.conn.Close()
+	close(m.done)
+}
+
+func (m *Monitor) dispatch(msg message) {
+	event := Event{
+		Name:      msg.Event,
+		Timestamp: time.Unix(msg.Timestamp.Seconds, msg.Timestamp.Microseconds*int64(time.Microsecond)),
+	}
+	if len(msg.Data) != 0 {
+		if err := json.Unmarshal(msg.Data, &event.Data); err != nil {
+			log.Debugf("qmp: invalid data for event %s: %s", msg.Event, err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, sub := range m.subs {
+		if len(sub.names) != 0 && !sub.names[event.Name] {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			log.Debugf("qmp: dropping event %s for slow subscriber", event.Name)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package qmp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeHandler func(args map[string]interface{}) (interface{}, *Error)
+
+// fakeServer is a minimal QMP server listening on a unix socket.
+type fakeServer struct {
+	t        *testing.T
+	dir      string
+	path     string
+	listener net.Listener
+	handlers map[string]fakeHandler
+
+	mu       sync.Mutex
+	conn     net.Conn
+	received []command
+}
+
+func newFakeServer(t *testing.T, handlers map[string]fakeHandler) *fakeServer {
+	dir, err := ioutil.TempDir("", "qmp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "monitor")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeServer{
+		t:        t,
+		dir:      dir,
+		path:     path,
+		listener: listener,
+		handlers: handlers,
+	}
+	go s.serve()
+
+	return s
+}
+
+func (s *fakeServer) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.mu.Unlock()
+	os.RemoveAll(s.dir)
+}
+
+func (s *fakeServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+
+	s.send(map[string]interface{}{
+		"QMP": map[string]interface{}{
+			"version": map[string]interface{}{
+				"qemu":    map[string]int{"major": 2, "minor": 11, "micro": 1},
+				"package": "fake",
+			},
+			"capabilities": []string{},
+		},
+	})
+
+	dec := json.NewDecoder(conn)
+	for {
+		var cmd struct {
+			Execute   string                 `json:"execute"`
+			Arguments map[string]interface{} `json:"arguments"`
+			ID        string                 `json:"id"`
+		}
+		if err := dec.Decode(&cmd); err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.received = append(s.received, command{Execute: cmd.Execute, Arguments: cmd.Arguments, ID: cmd.ID})
+		s.mu.Unlock()
+
+		reply := map[string]interface{}{}
+		if cmd.ID != "" {
+			reply["id"] = cmd.ID
+		}
+
+		handler, ok := s.handlers[cmd.Execute]
+		switch {
+		case cmd.Execute == "qmp_capabilities":
+			reply["return"] = map[string]interface{}{}
+		case !ok:
+			reply["error"] = &Error{Class: "CommandNotFound", Desc: "The command " + cmd.Execute + " has not been found"}
+		default:
+			ret, qmpErr := handler(cmd.Arguments)
+			if ret == nil && qmpErr == nil {
+				// Handler does not want to reply.
+				continue
+			}
+			if qmpErr != nil {
+				reply["error"] = qmpErr
+			} else {
+				reply["return"] = ret
+			}
+		}
+		s.send(reply)
+	}
+}
+
+func (s *fakeServer) send(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conn.Write(append(data, '\r', '\n'))
+}
+
+func (s *fakeServer) sendEvent(name string, data map[string]interface{}) {
+	s.send(map[string]interface{}{
+		"event":     name,
+		"data":      data,
+		"timestamp": map[string]int64{"seconds": 1500000000, "microseconds": 42},
+	})
+}
+
+func (s *fakeServer) commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := []string{}
+	for _, cmd := range s.received {
+		names = append(names, cmd.Execute)
+	}
+	return names
+}
+
+func TestDialNegotiatesCapabilities(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.Close()
+
+	m, err := Dial(s.path, time.Second)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	assert.Equal(t, 2, m.Greeting().Version.QEMU.Major)
+	assert.Equal(t, 11, m.Greeting().Version.QEMU.Minor)
+	assert.Equal(t, []string{"qmp_capabilities"}, s.commands())
+}
+
+func TestDialNoServer(t *testing.T) {
+	_, err := Dial(filepath.Join(os.TempDir(), "qmp-test-missing-socket"), time.Second)
+
+	assert.Error(t, err)
+}
+
+func TestQueryStatus(t *testing.T) {
+	s := newFakeServer(t, map[string]fakeHandler{
+		"query-status": func(map[string]interface{}) (interface{}, *Error) {
+			return map[string]interface{}{"running": false, "singlestep": false, "status": "paused"}, nil
+		},
+	})
+	defer s.Close()
+
+	m, err := Dial(s.path, time.Second)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	status, err := m.QueryStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, "paused", status.Status)
+	assert.False(t, status.Running)
+}
+
+func TestCommandWithArguments(t *testing.T) {
+	var received map[string]interface{}
+	s := newFakeServer(t, map[string]fakeHandler{
+		"human-monitor-command": func(args map[string]interface{}) (interface{}, *Error) {
+			received = args
+			return "done\r\n", nil
+		},
+	})
+	defer s.Close()
+
+	m, err := Dial(s.path, time.Second)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	output, err := m.HumanMonitorCommand("info status")
+	assert.NoError(t, err)
+	assert.Equal(t, "done\r\n", output)
+	assert.Equal(t, map[string]interface{}{"command-line": "info status"}, received)
+}
+
+func TestCommandError(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.Close()
+
+	m, err := Dial(s.path, time.Second)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	err = m.Run("no-such-command", nil, nil)
+
+	qmpErr, ok := err.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, "CommandNotFound", qmpErr.Class)
+}
+
+func TestConcurrentCommandsAreCorrelated(t *testing.T) {
+	s := newFakeServer(t, map[string]fakeHandler{
+		"echo": func(args map[string]interface{}) (interface{}, *Error) {
+			return args, nil
+		},
+	})
+	defer s.Close()
+
+	m, err := Dial(s.path, time.Second)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var ret struct {
+				N int `json:"n"`
+			}
+			err := m.Run("echo", map[string]int{"n": i}, &ret)
+			assert.NoError(t, err)
+			assert.Equal(t, i, ret.N)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCommandTimeout(t *testing.T) {
+	s := newFakeServer(t, map[string]fakeHandler{
+		"hang": func(map[string]interface{}) (interface{}, *Error) {
+			return nil, nil
+		},
+	})
+	defer s.Close()
+
+	m, err := Dial(s.path, time.Second)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	m.Timeout = 50 * time.Millisecond
+	err = m.Run("hang", nil, nil)
+
+	assert.Equal(t, ErrTimeout, err)
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.Close()
+
+	m, err := Dial(s.path, time.Second)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	shutdowns, unsubscribe := m.Subscribe("SHUTDOWN")
+	defer unsubscribe()
+	all, unsubscribeAll := m.Subscribe()
+	defer unsubscribeAll()
+
+	s.sendEvent("STOP", nil)
+	s.sendEvent("SHUTDOWN", map[string]interface{}{"guest": true})
+
+	select {
+	case event := <-shutdowns:
+		assert.Equal(t, "SHUTDOWN", event.Name)
+		assert.Equal(t, true, event.Data["guest"])
+		assert.Equal(t, int64(1500000000), event.Timestamp.Unix())
+	case <-time.After(time.Second):
+		t.Fatal("SHUTDOWN event not received")
+	}
+
+	for _, expected := range []string{"STOP", "SHUTDOWN"} {
+		select {
+		case event := <-all:
+			assert.Equal(t, expected, event.Name)
+		case <-time.After(time.Second):
+			t.Fatalf("%s event not received", expected)
+		}
+	}
+}
+
+func TestServerCloseEndsMonitor(t *testing.T) {
+	s := newFakeServer(t, map[string]fakeHandler{
+		"quit": func(map[string]interface{}) (interface{}, *Error) {
+			return nil, nil
+		},
+	})
+
+	m, err := Dial(s.path, time.Second)
+	assert.NoError(t, err)
+
+	events, _ := m.Subscribe()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.Close()
+	}()
+
+	assert.NoError(t, m.Quit())
+
+	select {
+	case <-m.Done():
+	case <-time.After(time.Second):
+		t.Fatal("monitor not closed")
+	}
+
+	_, ok := <-events
+	assert.False(t, ok)
+	assert.Equal(t, ErrClosed, m.Run("query-status", nil, nil))
+}
+
+func TestMigrate(t *testing.T) {
+	var uri interface{}
+	s := newFakeServer(t, map[string]fakeHandler{
+		"migrate": func(args map[string]interface{}) (interface{}, *Error) {
+			uri = args["uri"]
+			return map[string]interface{}{}, nil
+		},
+		"query-migrate": func(map[string]interface{}) (interface{}, *Error) {
+			return map[string]interface{}{"status": "completed"}, nil
+		},
+	})
+	defer s.Close()
+
+	m, err := Dial(s.path, time.Second)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	assert.NoError(t, m.Migrate("exec:cat > /tmp/state"))
+	assert.Equal(t, "exec:cat > /tmp/state", uri)
+
+	info, err := m.QueryMigrate()
+	assert.NoError(t, err)
+	assert.Equal(t, "completed", info.Status)
+}
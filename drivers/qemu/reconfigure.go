@@ -0,0 +1,48 @@
+package qemu
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/log"
+)
+
+// Reconfigure changes the CPUs, memory and disk size of a stopped VM. The
+// disk image is grown with qemu-img, and its filesystem on the next start.
+func (d *Driver) Reconfigure(r drivers.Resources) error {
+	running, err := d.hasProcess()
+	if err != nil {
+		return err
+	}
+	if running {
+		return drivers.ErrRequiresStop
+	}
+	if _, err := os.Stat(d.savedStatePath()); err == nil {
+		return fmt.Errorf("Machine has a saved state, start it before changing its resources")
+	}
+
+	if r.DiskSize != 0 && r.DiskSize < d.DiskSize {
+		return fmt.Errorf("Disk size can only grow, the disk is %d MB", d.DiskSize)
+	}
+
+	if r.DiskSize > d.DiskSize {
+		log.Debugf("Growing disk image by %d MB...", r.DiskSize-d.DiskSize)
+		if stdout, stderr, err := cmdOutErr("qemu-img", "resize", d.diskPath(), fmt.Sprintf("+%dM", r.DiskSize-d.DiskSize)); err != nil {
+			fmt.Printf("OUTPUT: %s\n", stdout)
+			fmt.Printf("ERROR: %s\n", stderr)
+			return err
+		}
+		d.DiskSize = r.DiskSize
+		d.GrowDisk = true
+	}
+
+	if r.CPUs != 0 {
+		d.CPU = r.CPUs
+	}
+	if r.Memory != 0 {
+		d.Memory = r.Memory
+	}
+
+	return nil
+}
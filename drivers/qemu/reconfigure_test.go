@@ -0,0 +1,48 @@
+package qemu
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconfigureStopped(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	d.CPU = 1
+	d.Memory = 1024
+	d.DiskSize = 20000
+
+	assert.NoError(t, d.Reconfigure(drivers.Resources{CPUs: 2, Memory: 2048}))
+	assert.Equal(t, 2, d.CPU)
+	assert.Equal(t, 2048, d.Memory)
+	assert.Equal(t, 20000, d.DiskSize)
+	assert.False(t, d.GrowDisk)
+}
+
+func TestReconfigureDiskCannotShrink(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	d.DiskSize = 20000
+
+	assert.EqualError(t, d.Reconfigure(drivers.Resources{DiskSize: 10000}), "Disk size can only grow, the disk is 20000 MB")
+}
+
+func TestReconfigureSavedState(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	if err := os.MkdirAll(d.ResolveStorePath("."), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(d.savedStatePath(), []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Error(t, d.Reconfigure(drivers.Resources{Memory: 2048}))
+}
@@ -0,0 +1,36 @@
+package qemu
+
+import (
+	"fmt"
+	"os"
+)
+
+// exportedBase is the name of the base of the disk of a clone in its
+// archive, and in the directory of the imported machine.
+const exportedBase = "base.qcow2"
+
+// ExportFiles returns the base of the disk of a clone, which is in the bases
+// directory of the store.
+func (d *Driver) ExportFiles() (map[string]string, error) {
+	base, err := findBase(d.StorePath, d.MachineName)
+	if err != nil || base == "" {
+		return nil, err
+	}
+	return map[string]string{exportedBase: base}, nil
+}
+
+// Relocate picks new host ports for SSH and the engine, as the ports used
+// where the machine comes from may be taken here. The disk of a clone is
+// backed by the base imported with it.
+func (d *Driver) Relocate() error {
+	base := d.ResolveStorePath(exportedBase)
+	if _, err := os.Stat(base); err == nil {
+		if stdout, stderr, err := cmdOutErr("qemu-img", "rebase", "-u", "-b", base, "-F", "qcow2", d.diskPath()); err != nil {
+			fmt.Printf("OUTPUT: %s\n", stdout)
+			fmt.Printf("ERROR: %s\n", stderr)
+			return err
+		}
+	}
+
+	return d.allocatePorts()
+}
@@ -0,0 +1,65 @@
+package qemu
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+)
+
+// setSharedFolders parses the --qemu-share-folder flags. Each folder gets
+// its own mount tag.
+func (d *Driver) setSharedFolders(specs []string) error {
+	d.SharedFolders = nil
+	for i, spec := range specs {
+		f, err := drivers.ParseSharedFolder(spec)
+		if err != nil {
+			return err
+		}
+		f.Tag = fmt.Sprintf("share%d", i)
+		d.SharedFolders = append(d.SharedFolders, f)
+	}
+	return nil
+}
+
+func (d *Driver) checkSharedFolders() error {
+	for _, f := range d.SharedFolders {
+		fi, err := os.Stat(f.HostPath)
+		if err != nil {
+			return fmt.Errorf("Cannot share folder: %s", err)
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("Cannot share folder: %s is not a directory", f.HostPath)
+		}
+	}
+	return nil
+}
+
+// sharedFolderOptions returns the qemu options exporting the shared
+// folders, one virtio-9p device per folder.
+func (d *Driver) sharedFolderOptions() []string {
+	var options []string
+	for _, f := range d.SharedFolders {
+		options = append(options,
+			"-fsdev", fmt.Sprintf("local,security_model=none,id=%s,path=%s", f.Tag, escapeOptionValue(f.HostPath)),
+			"-device", fmt.Sprintf("virtio-9p-pci,id=%s-dev,fsdev=%s,mount_tag=%s", f.Tag, f.Tag, f.Tag))
+	}
+	return options
+}
+
+// escapeOptionValue escapes the commas of a value of a qemu option, which
+// would otherwise end the value and start another option.
+func escapeOptionValue(value string) string {
+	return strings.Replace(value, ",", ",,", -1)
+}
+
+// mountSharedFolders mounts the shared folders in the guest, which loses
+// them on every reboot.
+func (d *Driver) mountSharedFolders() error {
+	if err := drivers.WaitForSSH(d); err != nil {
+		return err
+	}
+	_, err := drivers.RunSSHCommandFromDriver(d, drivers.MountSharedFoldersCommand(d.SharedFolders))
+	return err
+}
@@ -0,0 +1,49 @@
+package qemu
+
+import (
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedFolderOptions(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	assert.NoError(t, d.setSharedFolders([]string{"/home/user/src:/src", "/data"}))
+
+	assert.Equal(t, []drivers.SharedFolder{
+		{HostPath: "/home/user/src", GuestPath: "/src", Tag: "share0"},
+		{HostPath: "/data", GuestPath: "/data", Tag: "share1"},
+	}, d.SharedFolders)
+	assert.Equal(t, []string{
+		"-fsdev", "local,security_model=none,id=share0,path=/home/user/src",
+		"-device", "virtio-9p-pci,id=share0-dev,fsdev=share0,mount_tag=share0",
+		"-fsdev", "local,security_model=none,id=share1,path=/data",
+		"-device", "virtio-9p-pci,id=share1-dev,fsdev=share1,mount_tag=share1",
+	}, d.sharedFolderOptions())
+}
+
+func TestSharedFolderOptionsEscapeCommas(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	assert.NoError(t, d.setSharedFolders([]string{"/home/user/a,readonly=on,b:/src"}))
+
+	assert.Equal(t, []string{
+		"-fsdev", "local,security_model=none,id=share0,path=/home/user/a,,readonly=on,,b",
+		"-device", "virtio-9p-pci,id=share0-dev,fsdev=share0,mount_tag=share0",
+	}, d.sharedFolderOptions())
+}
+
+func TestCheckSharedFolders(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	assert.NoError(t, d.setSharedFolders([]string{d.StorePath + ":/store"}))
+	assert.NoError(t, d.checkSharedFolders())
+
+	assert.NoError(t, d.setSharedFolders([]string{d.StorePath + "/missing:/missing"}))
+	assert.Error(t, d.checkSharedFolders())
+}
@@ -0,0 +1,112 @@
+package qemu
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/state"
+)
+
+var (
+	reSnapshotLine = regexp.MustCompile(`^(\S+)\s+(\S+)\s+.*?(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})`)
+
+	// reSnapshotName matches the names which are safe to pass on a human
+	// monitor command line.
+	reSnapshotName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// SaveSnapshot uses savevm on a running VM, so that the memory is saved as
+// well, and qemu-img on the disk image of a stopped VM.
+func (d *Driver) SaveSnapshot(name string) error {
+	return d.snapshotCommand("savevm", "-c", name)
+}
+
+func (d *Driver) RestoreSnapshot(name string) error {
+	return d.snapshotCommand("loadvm", "-a", name)
+}
+
+func (d *Driver) RemoveSnapshot(name string) error {
+	return d.snapshotCommand("delvm", "-d", name)
+}
+
+func (d *Driver) ListSnapshots() ([]drivers.Snapshot, error) {
+	s, err := d.GetState()
+	if err != nil {
+		return nil, err
+	}
+
+	var output string
+	if s == state.Stopped || s == state.Saved {
+		if output, _, err = cmdOutErr("qemu-img", "snapshot", "-l", d.diskPath()); err != nil {
+			return nil, err
+		}
+	} else {
+		m, err := d.qmpMonitor()
+		if err != nil {
+			return nil, err
+		}
+		if output, err = m.HumanMonitorCommand("info snapshots"); err != nil {
+			return nil, err
+		}
+	}
+
+	return parseSnapshotList(output), nil
+}
+
+// snapshotCommand runs the human monitor command hmpCmd when the VM is
+// running, and `qemu-img snapshot <imgFlag>` when it is stopped. The disk of
+// a VM with a saved state must match that state, so it is left alone.
+func (d *Driver) snapshotCommand(hmpCmd, imgFlag, name string) error {
+	if name == "" {
+		return fmt.Errorf("Snapshot name cannot be empty")
+	}
+	if !reSnapshotName.MatchString(name) {
+		return fmt.Errorf("Invalid snapshot name %q, only letters, digits, '.', '_' and '-' are allowed", name)
+	}
+
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	switch s {
+	case state.Saved:
+		return fmt.Errorf("Machine has a saved state, start it before changing its snapshots")
+	case state.Stopped:
+		_, _, err := cmdOutErr("qemu-img", "snapshot", imgFlag, name, d.diskPath())
+		return err
+	}
+
+	m, err := d.qmpMonitor()
+	if err != nil {
+		return err
+	}
+	output, err := m.HumanMonitorCommand(fmt.Sprintf("%s %s", hmpCmd, name))
+	if err != nil {
+		return err
+	}
+	// The human monitor only prints something when the command failed
+	if output = strings.TrimSpace(output); output != "" {
+		return fmt.Errorf("%s %s failed: %s", hmpCmd, name, output)
+	}
+	return nil
+}
+
+// parseSnapshotList parses the snapshot tables printed by both
+// `qemu-img snapshot -l` and the `info snapshots` monitor command.
+func parseSnapshotList(output string) []drivers.Snapshot {
+	snapshots := []drivers.Snapshot{}
+	for _, line := range strings.Split(output, "\n") {
+		groups := reSnapshotLine.FindStringSubmatch(strings.TrimSpace(line))
+		if groups == nil {
+			continue
+		}
+		snapshots = append(snapshots, drivers.Snapshot{
+			Name:    groups[2],
+			Created: groups[3],
+		})
+	}
+	return snapshots
+}
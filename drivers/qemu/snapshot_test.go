@@ -0,0 +1,66 @@
+package qemu
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSnapshotListQemuImg(t *testing.T) {
+	output := `Snapshot list:
+ID        TAG                 VM SIZE                DATE       VM CLOCK
+1         clean                     0 2019-05-01 10:00:00   00:00:00.000
+2         with-images               0 2019-05-02 11:30:15   00:00:00.000
+`
+
+	snapshots := parseSnapshotList(output)
+
+	assert.Equal(t, []drivers.Snapshot{
+		{Name: "clean", Created: "2019-05-01 10:00:00"},
+		{Name: "with-images", Created: "2019-05-02 11:30:15"},
+	}, snapshots)
+}
+
+func TestParseSnapshotListMonitor(t *testing.T) {
+	output := "List of snapshots present on all disks:\r\n" +
+		"ID        TAG               VM SIZE                DATE     VM CLOCK     ICOUNT\r\n" +
+		"--        running          150 MiB 2019-05-03 09:12:01 00:01:02.345\r\n"
+
+	snapshots := parseSnapshotList(output)
+
+	assert.Equal(t, []drivers.Snapshot{
+		{Name: "running", Created: "2019-05-03 09:12:01"},
+	}, snapshots)
+}
+
+func TestParseSnapshotListEmpty(t *testing.T) {
+	snapshots := parseSnapshotList("There is no snapshot available.\r\n")
+
+	assert.Empty(t, snapshots)
+}
+
+func TestSnapshotInvalidName(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	for _, name := range []string{"a b", "x; quit", "../up", "snap\n"} {
+		assert.Error(t, d.SaveSnapshot(name), name)
+	}
+}
+
+func TestSnapshotSavedState(t *testing.T) {
+	d, cleanup := newStoppedDriver(t)
+	defer cleanup()
+
+	if err := os.MkdirAll(d.ResolveStorePath("."), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(d.savedStatePath(), []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.EqualError(t, d.RestoreSnapshot("clean"), "Machine has a saved state, start it before changing its snapshots")
+}
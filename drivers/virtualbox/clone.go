@@ -0,0 +1,83 @@
+package virtualbox
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/mcnutils"
+	"github.com/boot2podman/machine/libmachine/state"
+)
+
+// CreateClone creates the VM as a linked clone of a stopped VM, from a
+// snapshot taken for it. The disk of the source becomes the base of both
+// VMs, so the snapshot can't be deleted while the clone exists.
+func (d *Driver) CreateClone(source []byte) error {
+	src := NewDriver("", "")
+	if err := json.Unmarshal(source, src); err != nil {
+		return err
+	}
+	src.VBoxManager = d.VBoxManager
+
+	if err := d.cloneVM(src); err != nil {
+		return err
+	}
+
+	// The disk of the source only authorizes its key, replaced once the
+	// clone is running.
+	log.Infof("Copying SSH key...")
+	if err := mcnutils.CopyFile(src.GetSSHKeyPath(), d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+	if err := mcnutils.CopyFile(src.publicSSHKeyPath(), d.publicSSHKeyPath()); err != nil {
+		return err
+	}
+
+	log.Info("Starting the VM...")
+	return d.Start()
+}
+
+func (d *Driver) cloneVM(src *Driver) error {
+	s, err := src.GetState()
+	if err != nil {
+		return err
+	}
+	if s != state.Stopped {
+		return fmt.Errorf("Machine %q must be stopped to be cloned", src.MachineName)
+	}
+
+	snapshot := "clone-" + d.MachineName
+
+	log.Infof("Creating VirtualBox VM linked to %q...", src.MachineName)
+	if err := d.vbm("snapshot", src.MachineName, "take", snapshot); err != nil {
+		return err
+	}
+	if err := d.vbm("clonevm", src.MachineName,
+		"--snapshot", snapshot,
+		"--options", "link",
+		"--name", d.MachineName,
+		"--basefolder", d.ResolveStorePath("."),
+		"--register"); err != nil {
+		return err
+	}
+
+	// The forwards of the source would conflict with its own, once both
+	// are running.
+	for _, f := range d.PortForwards {
+		d.vbm("modifyvm", d.MachineName, "--natpf1", "delete", natpfName(f))
+	}
+	d.PortForwards = nil
+	d.SSHPort = 0
+	d.GrowDisk = false
+
+	if err := d.b2pUpdater.CopyIsoToMachineDir(d.StorePath, d.MachineName, d.Boot2PodmanURL); err != nil {
+		return err
+	}
+
+	return d.vbm("storageattach", d.MachineName,
+		"--storagectl", "SATA",
+		"--port", "0",
+		"--device", "0",
+		"--type", "dvddrive",
+		"--medium", d.ResolveStorePath("boot2podman.iso"))
+}
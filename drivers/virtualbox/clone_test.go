@@ -0,0 +1,47 @@
+package virtualbox
+
+import (
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloneVM(t *testing.T) {
+	src := NewDriver("default", "path")
+
+	driver := NewDriver("clone", "path")
+	driver.SSHPort = 2222
+	driver.GrowDisk = true
+	driver.PortForwards = []drivers.PortForward{{Protocol: "tcp", HostPort: 8080, GuestPort: 80}}
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="poweroff"`, nil},
+		{"vbm snapshot default take clone-clone", "", nil},
+		{"vbm clonevm default --snapshot clone-clone --options link --name clone --basefolder path/machines/clone --register", "", nil},
+		{"vbm modifyvm clone --natpf1 delete tcp-8080", "", nil},
+		{"CopyIsoToMachineDir path clone http://b2p.org", "", nil},
+		{"vbm storageattach clone --storagectl SATA --port 0 --device 0 --type dvddrive --medium path/machines/clone/boot2podman.iso", "", nil},
+	})
+	src.VBoxManager = driver.VBoxManager
+
+	err := driver.cloneVM(src)
+
+	assert.NoError(t, err)
+	assert.Empty(t, driver.PortForwards)
+	assert.Equal(t, 0, driver.SSHPort)
+	assert.False(t, driver.GrowDisk)
+}
+
+func TestCloneVMRunningSource(t *testing.T) {
+	src := NewDriver("default", "path")
+
+	driver := NewDriver("clone", "path")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="running"`, nil},
+	})
+	src.VBoxManager = driver.VBoxManager
+
+	err := driver.cloneVM(src)
+
+	assert.EqualError(t, err, `Machine "default" must be stopped to be cloned`)
+}
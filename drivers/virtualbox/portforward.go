@@ -0,0 +1,87 @@
+package virtualbox
+
+import (
+	"fmt"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/state"
+)
+
+// AddPortForward adds a NAT port forwarding rule to the first adapter, with
+// controlvm when the VM is running and modifyvm otherwise.
+func (d *Driver) AddPortForward(f drivers.PortForward) error {
+	if f.Protocol == "tcp" && f.HostPort == d.SSHPort {
+		return fmt.Errorf("Host port %d is used for SSH", f.HostPort)
+	}
+	if d.findPortForward(f) >= 0 {
+		return fmt.Errorf("Host port %d/%s is already forwarded", f.HostPort, f.Protocol)
+	}
+
+	rule := fmt.Sprintf("%s,%s,127.0.0.1,%d,,%d", natpfName(f), f.Protocol, f.HostPort, f.GuestPort)
+	if err := d.natpf(rule); err != nil {
+		return err
+	}
+
+	d.PortForwards = append(d.PortForwards, f)
+	return nil
+}
+
+// RemovePortForward deletes the NAT port forwarding rule for the host port
+// of f.
+func (d *Driver) RemovePortForward(f drivers.PortForward) error {
+	i := d.findPortForward(f)
+	if i < 0 {
+		return fmt.Errorf("Host port %d/%s is not forwarded", f.HostPort, f.Protocol)
+	}
+
+	if err := d.natpf("delete", natpfName(f)); err != nil {
+		return err
+	}
+
+	d.PortForwards = append(d.PortForwards[:i], d.PortForwards[i+1:]...)
+	return nil
+}
+
+func (d *Driver) ListPortForwards() ([]drivers.PortForward, error) {
+	return d.PortForwards, nil
+}
+
+func (d *Driver) findPortForward(f drivers.PortForward) int {
+	for i, existing := range d.PortForwards {
+		if existing.Protocol == f.Protocol && existing.HostPort == f.HostPort {
+			return i
+		}
+	}
+	return -1
+}
+
+// natpf changes the NAT rules of the first adapter of the VM.
+func (d *Driver) natpf(args ...string) error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s == state.Running || s == state.Paused {
+		return d.vbm(append([]string{"controlvm", d.MachineName, "natpf1"}, args...)...)
+	}
+	return d.vbm(append([]string{"modifyvm", d.MachineName, "--natpf1"}, args...)...)
+}
+
+// setPortForwards re-creates the rules of the configured forwards. VirtualBox
+// keeps them in the VM settings, but they may have been changed outside of
+// podman-machine.
+func (d *Driver) setPortForwards() error {
+	for _, f := range d.PortForwards {
+		d.vbm("modifyvm", d.MachineName, "--natpf1", "delete", natpfName(f))
+		if err := d.vbm("modifyvm", d.MachineName, "--natpf1",
+			fmt.Sprintf("%s,%s,127.0.0.1,%d,,%d", natpfName(f), f.Protocol, f.HostPort, f.GuestPort)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func natpfName(f drivers.PortForward) string {
+	return fmt.Sprintf("%s-%d", f.Protocol, f.HostPort)
+}
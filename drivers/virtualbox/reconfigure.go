@@ -0,0 +1,62 @@
+package virtualbox
+
+import (
+	"fmt"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/state"
+)
+
+// Reconfigure changes the CPUs, memory and disk size of a powered off VM
+// with modifyvm and modifymedium. The filesystem is grown on the next start.
+func (d *Driver) Reconfigure(r drivers.Resources) error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+	switch s {
+	case state.Stopped:
+	case state.Saved:
+		return fmt.Errorf("Machine has a saved state, start it before changing its resources")
+	default:
+		return drivers.ErrRequiresStop
+	}
+
+	if r.DiskSize != 0 && r.DiskSize < d.DiskSize {
+		return fmt.Errorf("Disk size can only grow, the disk is %d MB", d.DiskSize)
+	}
+
+	args := []string{"modifyvm", d.MachineName}
+	if r.CPUs != 0 {
+		cpus := r.CPUs
+		if cpus > 32 {
+			cpus = 32
+		}
+		args = append(args, "--cpus", fmt.Sprintf("%d", cpus))
+	}
+	if r.Memory != 0 {
+		args = append(args, "--memory", fmt.Sprintf("%d", r.Memory))
+	}
+	if len(args) > 2 {
+		if err := d.vbm(args...); err != nil {
+			return err
+		}
+	}
+
+	if r.DiskSize > d.DiskSize {
+		if err := d.vbm("modifymedium", "disk", d.diskPath(), "--resize", fmt.Sprintf("%d", r.DiskSize)); err != nil {
+			return err
+		}
+		d.DiskSize = r.DiskSize
+		d.GrowDisk = true
+	}
+
+	if r.CPUs != 0 {
+		d.CPU = r.CPUs
+	}
+	if r.Memory != 0 {
+		d.Memory = r.Memory
+	}
+
+	return nil
+}
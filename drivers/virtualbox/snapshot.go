@@ -0,0 +1,64 @@
+package virtualbox
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/state"
+)
+
+var (
+	reSnapshotName = regexp.MustCompile(`^SnapshotName(-[\d-]+)?$`)
+	reNoSnapshots  = regexp.MustCompile(`does not have any snapshots`)
+)
+
+func (d *Driver) SaveSnapshot(name string) error {
+	return d.vbm("snapshot", d.MachineName, "take", name)
+}
+
+func (d *Driver) RestoreSnapshot(name string) error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s == state.Running || s == state.Paused {
+		return fmt.Errorf("Machine %q must be stopped to restore a snapshot", d.MachineName)
+	}
+
+	return d.vbm("snapshot", d.MachineName, "restore", name)
+}
+
+func (d *Driver) ListSnapshots() ([]drivers.Snapshot, error) {
+	return listSnapshots(d.MachineName, d.VBoxManager)
+}
+
+func (d *Driver) RemoveSnapshot(name string) error {
+	return d.vbm("snapshot", d.MachineName, "delete", name)
+}
+
+func listSnapshots(name string, vbox VBoxManager) ([]drivers.Snapshot, error) {
+	snapshots := []drivers.Snapshot{}
+
+	stdout, stderr, err := vbox.vbmOutErr("snapshot", name, "list", "--machinereadable")
+	if err != nil {
+		if reNoSnapshots.MatchString(stdout + stderr) {
+			return snapshots, nil
+		}
+		return nil, err
+	}
+
+	err = parseKeyValues(stdout, reEqualLine, func(key, val string) error {
+		if reSnapshotName.MatchString(key) {
+			snapshots = append(snapshots, drivers.Snapshot{Name: strings.Trim(val, `"`)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
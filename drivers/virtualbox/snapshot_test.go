@@ -0,0 +1,60 @@
+package virtualbox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+var stdOutSnapshotList = `SnapshotName="clean"
+SnapshotUUID="2b5a2b5e-3f1c-4d0e-9c3a-6f0f1a6e9d01"
+SnapshotName-1="with-images"
+SnapshotUUID-1="7d0c4b2e-1a2b-4c3d-8e9f-0a1b2c3d4e5f"
+SnapshotName-1-1="nested"
+SnapshotUUID-1-1="0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"
+CurrentSnapshotName="nested"
+CurrentSnapshotUUID="0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"
+CurrentSnapshotNode="SnapshotName-1-1"`
+
+func TestListSnapshots(t *testing.T) {
+	vbox := &VBoxManagerMock{
+		args:   "snapshot host list --machinereadable",
+		stdOut: stdOutSnapshotList,
+	}
+
+	snapshots, err := listSnapshots("host", vbox)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Snapshot{
+		{Name: "clean"},
+		{Name: "with-images"},
+		{Name: "nested"},
+	}, snapshots)
+}
+
+func TestListSnapshotsNone(t *testing.T) {
+	vbox := &VBoxManagerMock{
+		args:   "snapshot host list --machinereadable",
+		stdOut: "This machine does not have any snapshots",
+		err:    errors.New("exit status 1"),
+	}
+
+	snapshots, err := listSnapshots("host", vbox)
+
+	assert.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
+
+func TestListSnapshotsError(t *testing.T) {
+	vbox := &VBoxManagerMock{
+		args: "snapshot host list --machinereadable",
+		err:  errors.New("BUG"),
+	}
+
+	snapshots, err := listSnapshots("host", vbox)
+
+	assert.Nil(t, snapshots)
+	assert.EqualError(t, err, "BUG")
+}
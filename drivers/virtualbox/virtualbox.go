@@ -67,6 +67,8 @@ type Driver struct {
 	DNSProxy            bool
 	NoVTXCheck          bool
 	ShareFolder         string
+	PortForwards        []drivers.PortForward
+	GrowDisk            bool
 }
 
 // NewDriver creates a new VirtualBox driver with default settings.
@@ -479,6 +481,10 @@ func (d *Driver) Start() error {
 			return err
 		}
 
+		if err := d.setPortForwards(); err != nil {
+			return err
+		}
+
 		if err := d.vbm("startvm", d.MachineName, "--type", d.UIType); err != nil {
 			if lines, readErr := d.readVBoxLog(); readErr == nil && len(lines) > 0 {
 				return fmt.Errorf("Unable to start the VM: %s\nDetails: %s", err, lines[len(lines)-1])
@@ -511,6 +517,14 @@ func (d *Driver) Start() error {
 		return err
 	}
 
+	if d.GrowDisk {
+		log.Infof("Growing the filesystem to the new disk size...")
+		if err := drivers.GrowFilesystem(d); err != nil {
+			log.Warnf("Failed to grow the filesystem: %s", err)
+		}
+		d.GrowDisk = false
+	}
+
 	if hostOnlyAdapter == nil {
 		return nil
 	}
@@ -615,6 +629,19 @@ func (d *Driver) Kill() error {
 	return d.vbm("controlvm", d.MachineName, "poweroff")
 }
 
+func (d *Driver) Pause() error {
+	return d.vbm("controlvm", d.MachineName, "pause")
+}
+
+func (d *Driver) Resume() error {
+	return d.vbm("controlvm", d.MachineName, "resume")
+}
+
+// SaveState saves the state of the VM to disk, Start restores it.
+func (d *Driver) SaveState() error {
+	return d.vbm("controlvm", d.MachineName, "savestate")
+}
+
 func (d *Driver) Remove() error {
 	s, err := d.GetState()
 	if err == ErrMachineNotExist {
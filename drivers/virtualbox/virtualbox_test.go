@@ -756,3 +756,100 @@ func TestRemovePaused(t *testing.T) {
 
 	assert.NoError(t, err)
 }
+
+func TestPauseResumeSave(t *testing.T) {
+	var tests = []struct {
+		args   string
+		action func(d *Driver) error
+	}{
+		{"controlvm default pause", (*Driver).Pause},
+		{"controlvm default resume", (*Driver).Resume},
+		{"controlvm default savestate", (*Driver).SaveState},
+	}
+
+	for _, test := range tests {
+		driver := newTestDriver("default")
+		driver.VBoxManager = &VBoxManagerMock{
+			args: test.args,
+		}
+
+		assert.NoError(t, test.action(driver))
+	}
+}
+
+func TestAddPortForward(t *testing.T) {
+	var tests = []struct {
+		vmState string
+		args    string
+	}{
+		{"running", "vbm controlvm default natpf1 tcp-8080,tcp,127.0.0.1,8080,,80"},
+		{"paused", "vbm controlvm default natpf1 tcp-8080,tcp,127.0.0.1,8080,,80"},
+		{"poweroff", "vbm modifyvm default --natpf1 tcp-8080,tcp,127.0.0.1,8080,,80"},
+	}
+
+	for _, test := range tests {
+		driver := NewDriver("default", "path")
+		mockCalls(t, driver, []Call{
+			{"vbm showvminfo default --machinereadable", fmt.Sprintf(`VMState="%s"`, test.vmState), nil},
+			{test.args, "", nil},
+		})
+
+		f := drivers.PortForward{Protocol: "tcp", HostPort: 8080, GuestPort: 80}
+		err := driver.AddPortForward(f)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []drivers.PortForward{f}, driver.PortForwards)
+	}
+}
+
+func TestRemovePortForward(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.PortForwards = []drivers.PortForward{{Protocol: "udp", HostPort: 5353, GuestPort: 53}}
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="running"`, nil},
+		{"vbm controlvm default natpf1 delete udp-5353", "", nil},
+	})
+
+	err := driver.RemovePortForward(drivers.PortForward{Protocol: "udp", HostPort: 5353})
+
+	assert.NoError(t, err)
+	assert.Empty(t, driver.PortForwards)
+}
+
+func TestAddPortForwardConflicts(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.SSHPort = 2222
+	driver.PortForwards = []drivers.PortForward{{Protocol: "tcp", HostPort: 8080, GuestPort: 80}}
+
+	assert.Error(t, driver.AddPortForward(drivers.PortForward{Protocol: "tcp", HostPort: 8080, GuestPort: 81}))
+	assert.Error(t, driver.AddPortForward(drivers.PortForward{Protocol: "tcp", HostPort: 2222, GuestPort: 22}))
+}
+
+func TestReconfigure(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="poweroff"`, nil},
+		{"vbm modifyvm default --cpus 4 --memory 4096", "", nil},
+		{"vbm modifymedium disk path/machines/default/disk.vmdk --resize 40000", "", nil},
+	})
+
+	err := driver.Reconfigure(drivers.Resources{CPUs: 4, Memory: 4096, DiskSize: 40000})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, driver.CPU)
+	assert.Equal(t, 4096, driver.Memory)
+	assert.Equal(t, 40000, driver.DiskSize)
+	assert.True(t, driver.GrowDisk)
+}
+
+func TestReconfigureRunning(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mockCalls(t, driver, []Call{
+		{"vbm showvminfo default --machinereadable", `VMState="running"`, nil},
+	})
+
+	err := driver.Reconfigure(drivers.Resources{Memory: 4096})
+
+	assert.Equal(t, drivers.ErrRequiresStop, err)
+	assert.Equal(t, defaultMemory, driver.Memory)
+}
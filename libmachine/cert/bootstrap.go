@@ -1,9 +1,13 @@
 package cert
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/boot2podman/machine/libmachine/auth"
 	"github.com/boot2podman/machine/libmachine/log"
@@ -70,6 +74,68 @@ func createCert(authOptions *auth.Options, org string, bits int) error {
 	return nil
 }
 
+// ReissueServerCert issues the server certificate of a machine again, for the
+// same hosts, with the CA of authOptions, which is bootstrapped if needed,
+// and updates the copies of the CA and client certificates in the machine
+// directory. It is for the machines imported from another store, whose
+// certificates were issued by the CA of that store.
+func ReissueServerCert(authOptions *auth.Options) error {
+	if err := BootstrapCertificates(authOptions); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(authOptions.ServerCertPath)
+	if os.IsNotExist(err) {
+		// The machine was never provisioned
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("No certificate in %s", authOptions.ServerCertPath)
+	}
+	serverCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	hosts := serverCert.DNSNames
+	for _, ip := range serverCert.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	org := ""
+	if len(serverCert.Subject.Organization) > 0 {
+		org = serverCert.Subject.Organization[0]
+	}
+
+	if err := GenerateCert(&Options{
+		Hosts:     hosts,
+		CertFile:  authOptions.ServerCertPath,
+		KeyFile:   authOptions.ServerKeyPath,
+		CAFile:    authOptions.CaCertPath,
+		CAKeyFile: authOptions.CaPrivateKeyPath,
+		Org:       org,
+		Bits:      2048,
+	}); err != nil {
+		return fmt.Errorf("error generating server cert: %s", err)
+	}
+
+	copies := map[string]string{
+		"ca.pem":   authOptions.CaCertPath,
+		"cert.pem": authOptions.ClientCertPath,
+		"key.pem":  authOptions.ClientKeyPath,
+	}
+	for name, src := range copies {
+		if err := mcnutils.CopyFile(src, filepath.Join(authOptions.StorePath, name)); err != nil {
+			return fmt.Errorf("Copying %s to machine dir failed: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
 func BootstrapCertificates(authOptions *auth.Options) error {
 	certDir := authOptions.CertDir
 	caCertPath := authOptions.CaCertPath
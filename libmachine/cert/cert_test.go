@@ -1,10 +1,15 @@
 package cert
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/boot2podman/machine/libmachine/auth"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGenerateCACertificate(t *testing.T) {
@@ -78,3 +83,71 @@ func TestGenerateCert(t *testing.T) {
 		t.Fatalf("key not created at %s", keyPath)
 	}
 }
+
+func TestReissueServerCert(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+
+	// The machine comes from another store, with its own CA
+	oldCaCertPath := filepath.Join(tmpDir, "old", "ca.pem")
+	oldCaKeyPath := filepath.Join(tmpDir, "old", "ca-key.pem")
+	machineDir := filepath.Join(tmpDir, "machines", "box")
+	for _, dir := range []string{filepath.Dir(oldCaCertPath), machineDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := GenerateCACertificate(oldCaCertPath, oldCaKeyPath, "old-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	certDir := filepath.Join(tmpDir, "certs")
+	authOptions := &auth.Options{
+		CertDir:          certDir,
+		CaCertPath:       filepath.Join(certDir, "ca.pem"),
+		CaPrivateKeyPath: filepath.Join(certDir, "ca-key.pem"),
+		ClientCertPath:   filepath.Join(certDir, "cert.pem"),
+		ClientKeyPath:    filepath.Join(certDir, "key.pem"),
+		ServerCertPath:   filepath.Join(machineDir, "server.pem"),
+		ServerKeyPath:    filepath.Join(machineDir, "server-key.pem"),
+		StorePath:        machineDir,
+	}
+	if err := GenerateCert(&Options{
+		Hosts:     []string{"192.168.64.2", "localhost"},
+		CertFile:  authOptions.ServerCertPath,
+		KeyFile:   authOptions.ServerKeyPath,
+		CAFile:    oldCaCertPath,
+		CAKeyFile: oldCaKeyPath,
+		Org:       "user.box",
+		Bits:      2048,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, ReissueServerCert(authOptions))
+
+	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
+	assert.NoError(t, err)
+	roots := x509.NewCertPool()
+	assert.True(t, roots.AppendCertsFromPEM(caCert))
+
+	data, err := ioutil.ReadFile(authOptions.ServerCertPath)
+	assert.NoError(t, err)
+	block, _ := pem.Decode(data)
+	serverCert, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err)
+
+	_, err = serverCert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "localhost"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"localhost"}, serverCert.DNSNames)
+	assert.Equal(t, "192.168.64.2", serverCert.IPAddresses[0].String())
+	assert.Equal(t, []string{"user.box"}, serverCert.Subject.Organization)
+
+	machineCaCert, err := ioutil.ReadFile(filepath.Join(machineDir, "ca.pem"))
+	assert.NoError(t, err)
+	assert.Equal(t, caCert, machineCaCert)
+}
@@ -0,0 +1,66 @@
+package drivers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/ssh"
+)
+
+// Cloner is implemented by drivers that can create a machine as a copy of
+// another one, sharing the disk of the source where possible.
+type Cloner interface {
+	// CreateClone creates and starts the machine as a clone of the stopped
+	// machine whose driver config is source, instead of Create. The clone
+	// comes up with the SSH key of its source.
+	CreateClone(source []byte) error
+}
+
+// CloneConfig returns the driver config of a clone named name, from the
+// driver config of its source. The settings specific to a machine, which
+// the base driver keeps, are reset. The others are left to CreateClone.
+func CloneConfig(source []byte, name string) ([]byte, error) {
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(source, &config); err != nil {
+		return nil, err
+	}
+
+	config["MachineName"] = name
+	delete(config, "IPAddress")
+	delete(config, "SSHKeyPath")
+
+	return json.Marshal(config)
+}
+
+// ReplaceSSHKey generates a new SSH key for the machine of d, and
+// authorizes it in place of the keys authorized so far. It is used on
+// clones, so that they don't share the key of their source.
+func ReplaceSSHKey(d Driver) error {
+	keyPath := d.GetSSHKeyPath()
+	newKeyPath := keyPath + ".new"
+
+	os.Remove(newKeyPath)
+	os.Remove(newKeyPath + ".pub")
+	if err := ssh.GenerateSSHKey(newKeyPath); err != nil {
+		return err
+	}
+
+	pubKey, err := ioutil.ReadFile(newKeyPath + ".pub")
+	if err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf("mkdir -p ~/.ssh && printf '%%s\\n' '%s' | tee ~/.ssh/authorized_keys ~/.ssh/authorized_keys2 >/dev/null",
+		strings.TrimSpace(string(pubKey)))
+	if _, err := RunSSHCommandFromDriver(d, command); err != nil {
+		return fmt.Errorf("Error authorizing the new SSH key: %s", err)
+	}
+
+	if err := os.Rename(newKeyPath+".pub", keyPath+".pub"); err != nil {
+		return err
+	}
+	return os.Rename(newKeyPath, keyPath)
+}
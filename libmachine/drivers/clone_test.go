@@ -0,0 +1,20 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloneConfig(t *testing.T) {
+	config, err := CloneConfig([]byte(`{"MachineName":"src","IPAddress":"192.168.99.100","SSHKeyPath":"/store/machines/src/id_rsa","SSHPort":22,"Memory":2048}`), "dst")
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"MachineName":"dst","SSHPort":22,"Memory":2048}`, string(config))
+}
+
+func TestCloneConfigInvalid(t *testing.T) {
+	_, err := CloneConfig([]byte(`not json`), "dst")
+
+	assert.Error(t, err)
+}
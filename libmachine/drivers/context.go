@@ -0,0 +1,74 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/boot2podman/machine/libmachine/log"
+)
+
+// Progress is an event reported by a driver during a long operation.
+type Progress struct {
+	Phase   string
+	Percent int // 0 to 100, or -1 if unknown
+	Message string
+}
+
+func (p Progress) String() string {
+	if p.Percent < 0 {
+		return fmt.Sprintf("%s: %s", p.Phase, p.Message)
+	}
+	return fmt.Sprintf("%s: %s (%d%%)", p.Phase, p.Message, p.Percent)
+}
+
+// ContextDriver is implemented by drivers whose long operations can be
+// cancelled, and report their progress, through a context.
+type ContextDriver interface {
+	// CreateContext creates the machine, as Create does
+	CreateContext(ctx context.Context) error
+
+	// StartContext starts the machine, as Start does
+	StartContext(ctx context.Context) error
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose progress events are passed to
+// report.
+func WithProgress(ctx context.Context, report func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// ReportProgress reports an event to the receiver set by WithProgress, if
+// any.
+func ReportProgress(ctx context.Context, p Progress) {
+	if report, ok := ctx.Value(progressKey{}).(func(Progress)); ok {
+		report(p)
+	}
+}
+
+// LogProgress returns a context logging the progress events of the machine
+// name.
+func LogProgress(ctx context.Context, name string) context.Context {
+	return WithProgress(ctx, func(p Progress) {
+		log.Infof("(%s) %s", name, p)
+	})
+}
+
+// CreateContext creates the machine of d with ctx, if d is a ContextDriver.
+// Other drivers cannot be interrupted, and are simply created.
+func CreateContext(ctx context.Context, d Driver) error {
+	if cd, ok := d.(ContextDriver); ok {
+		return cd.CreateContext(ctx)
+	}
+	return d.Create()
+}
+
+// StartContext starts the machine of d with ctx, if d is a ContextDriver.
+// Other drivers cannot be interrupted, and are simply started.
+func StartContext(ctx context.Context, d Driver) error {
+	if cd, ok := d.(ContextDriver); ok {
+		return cd.StartContext(ctx)
+	}
+	return d.Start()
+}
@@ -74,6 +74,10 @@ type Driver interface {
 
 var ErrHostIsNotRunning = errors.New("Host is not running")
 
+// ErrNotSupported is returned by drivers for optional operations they do
+// not implement.
+var ErrNotSupported = errors.New("Operation not supported by the driver")
+
 type DriverOptions interface {
 	String(key string) string
 	StringSlice(key string) []string
@@ -0,0 +1,18 @@
+package drivers
+
+// Pauser is implemented by drivers that can freeze a running machine in
+// memory and later let it continue.
+type Pauser interface {
+	// Pause suspends the execution of a running machine
+	Pause() error
+
+	// Resume continues the execution of a paused machine
+	Resume() error
+}
+
+// Saver is implemented by drivers that can suspend a machine to disk. A
+// saved machine is brought back by Start.
+type Saver interface {
+	// SaveState writes the state of a running machine to disk and stops it
+	SaveState() error
+}
@@ -0,0 +1,111 @@
+package localbinary
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// PluginPrefix starts the name of every driver plugin binary.
+const PluginPrefix = "podman-machine-driver-"
+
+// PluginsDir is searched for driver plugins before the PATH. It is left
+// empty to only search the PATH.
+var PluginsDir = ""
+
+// DriverBinary is a driver found on this system.
+type DriverBinary struct {
+	Name string
+	Path string
+	// Core drivers are built into the podman-machine binary.
+	Core bool
+}
+
+// FindDrivers returns the core drivers, followed by the plugins found in
+// PluginsDir and the directories of the PATH, sorted by name. As when
+// launching a driver, the first binary found for a name wins and hides the
+// others.
+func FindDrivers() []DriverBinary {
+	found := map[string]bool{}
+	coreDrivers := []DriverBinary{}
+	for _, name := range CoreDrivers {
+		path, err := exec.LookPath(driverPath(name))
+		if err != nil {
+			path = ""
+		}
+		coreDrivers = append(coreDrivers, DriverBinary{Name: name, Path: path, Core: true})
+		found[name] = true
+	}
+
+	plugins := []DriverBinary{}
+	for _, dir := range pluginDirs() {
+		for _, plugin := range findPlugins(dir) {
+			if !found[plugin.Name] {
+				plugins = append(plugins, plugin)
+				found[plugin.Name] = true
+			}
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+
+	return append(coreDrivers, plugins...)
+}
+
+func pluginDirs() []string {
+	dirs := filepath.SplitList(os.Getenv("PATH"))
+	if PluginsDir != "" {
+		dirs = append([]string{PluginsDir}, dirs...)
+	}
+	return dirs
+}
+
+// findPlugins returns the executable plugin binaries of a directory.
+func findPlugins(dir string) []DriverBinary {
+	if dir == "" {
+		// An empty PATH element means the current directory, which
+		// exec.LookPath doesn't search either.
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	plugins := []DriverBinary{}
+	for _, file := range files {
+		name := file.Name()
+		if runtime.GOOS == "windows" {
+			name = strings.TrimSuffix(name, ".exe")
+		}
+		if !strings.HasPrefix(name, PluginPrefix) || len(name) == len(PluginPrefix) {
+			continue
+		}
+
+		path, err := exec.LookPath(filepath.Join(dir, file.Name()))
+		if err != nil {
+			continue
+		}
+
+		plugins = append(plugins, DriverBinary{
+			Name: strings.TrimPrefix(name, PluginPrefix),
+			Path: path,
+		})
+	}
+
+	return plugins
+}
+
+// lookPath is exec.LookPath, searching PluginsDir first for plugins.
+func lookPath(file string) (string, error) {
+	if PluginsDir != "" && strings.HasPrefix(file, PluginPrefix) {
+		if path, err := exec.LookPath(filepath.Join(PluginsDir, file)); err == nil {
+			return path, nil
+		}
+	}
+	return exec.LookPath(file)
+}
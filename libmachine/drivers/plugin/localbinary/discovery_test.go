@@ -0,0 +1,81 @@
+package localbinary
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFakePlugin(t *testing.T, dir, name string, mode os.FileMode) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindDrivers(t *testing.T) {
+	pluginsDir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pluginsDir)
+
+	pathDir, err := ioutil.TempDir("", "path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pathDir)
+
+	kvm := writeFakePlugin(t, pluginsDir, "podman-machine-driver-kvm", 0755)
+	writeFakePlugin(t, pathDir, "podman-machine-driver-kvm", 0755)
+	xhyve := writeFakePlugin(t, pathDir, "podman-machine-driver-xhyve", 0755)
+	writeFakePlugin(t, pathDir, "podman-machine-driver-qemu", 0755)
+	writeFakePlugin(t, pathDir, "podman-machine-driver-noexec", 0644)
+	writeFakePlugin(t, pathDir, "podman-machine-driver-", 0755)
+	writeFakePlugin(t, pathDir, "podman-machine", 0755)
+
+	defer func(path, dir string) {
+		os.Setenv("PATH", path)
+		PluginsDir = dir
+	}(os.Getenv("PATH"), PluginsDir)
+	os.Setenv("PATH", pathDir)
+	PluginsDir = pluginsDir
+
+	found := FindDrivers()
+
+	assert.Len(t, found, len(CoreDrivers)+2)
+	for i, name := range CoreDrivers {
+		assert.Equal(t, name, found[i].Name)
+		assert.True(t, found[i].Core)
+	}
+	assert.Equal(t, []DriverBinary{
+		{Name: "kvm", Path: kvm},
+		{Name: "xhyve", Path: xhyve},
+	}, found[len(CoreDrivers):])
+}
+
+func TestNewPluginSearchesPluginsDir(t *testing.T) {
+	pluginsDir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pluginsDir)
+
+	kvm := writeFakePlugin(t, pluginsDir, "podman-machine-driver-kvm", 0755)
+
+	defer func(dir string) { PluginsDir = dir }(PluginsDir)
+	PluginsDir = pluginsDir
+
+	p, err := NewPlugin("kvm")
+
+	assert.NoError(t, err)
+	assert.Equal(t, kvm, p.Executor.(*Executor).binaryPath)
+
+	_, err = NewPlugin("xhyve")
+
+	assert.Equal(t, ErrPluginBinaryNotFound{"xhyve", "podman-machine-driver-xhyve"}, err)
+}
@@ -92,8 +92,8 @@ func (e ErrPluginBinaryNotFound) Error() string {
 // driverPath finds the path of a driver binary by its name.
 //  + If the driver is a core driver, there is no separate driver binary. We reuse current binary if it's `podman-machine`
 // or we assume `podman-machine` is in the PATH.
-//  + If the driver is NOT a core driver, then the separate binary must be in the PATH and it's name must be
-// `podman-machine-driver-driverName`
+//  + If the driver is NOT a core driver, then the separate binary must be in the plugins directory or the PATH
+// and it's name must be `podman-machine-driver-driverName`
 func driverPath(driverName string) string {
 	for _, coreDriver := range CoreDrivers {
 		if coreDriver == driverName {
@@ -105,14 +105,24 @@ func driverPath(driverName string) string {
 		}
 	}
 
-	return fmt.Sprintf("podman-machine-driver-%s", driverName)
+	return PluginPrefix + driverName
 }
 
-func NewPlugin(driverName string) (*Plugin, error) {
+// BinaryPath returns the path of the binary serving a driver, without
+// starting it.
+func BinaryPath(driverName string) (string, error) {
 	driverPath := driverPath(driverName)
-	binaryPath, err := exec.LookPath(driverPath)
+	binaryPath, err := lookPath(driverPath)
+	if err != nil {
+		return "", ErrPluginBinaryNotFound{driverName, driverPath}
+	}
+	return binaryPath, nil
+}
+
+func NewPlugin(driverName string) (*Plugin, error) {
+	binaryPath, err := BinaryPath(driverName)
 	if err != nil {
-		return nil, ErrPluginBinaryNotFound{driverName, driverPath}
+		return nil, err
 	}
 
 	log.Debugf("Found binary path at %s", binaryPath)
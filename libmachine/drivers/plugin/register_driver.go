@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/rpc"
 	"os"
+	"os/signal"
 	"time"
 
 	"github.com/boot2podman/machine/libmachine/drivers"
@@ -19,7 +20,18 @@ var (
 	heartbeatTimeout = 10 * time.Second
 )
 
+// RegisterDriver serves d, for one machine.
 func RegisterDriver(d drivers.Driver) {
+	serve(rpcdriver.NewRPCServerDriver(d))
+}
+
+// RegisterDriverFactory serves a driver made by newDriver for each machine
+// used by the client, so that a single plugin is started for all of them.
+func RegisterDriverFactory(newDriver func() drivers.Driver) {
+	serve(rpcdriver.NewRPCServerDriverPool(rpc.DefaultServer, newDriver))
+}
+
+func serve(rpcd *rpcdriver.RPCServerDriver) {
 	if os.Getenv(localbinary.PluginEnvKey) != localbinary.PluginEnvVal {
 		fmt.Fprintf(os.Stderr, `This is a Podman Machine plugin binary.
 Plugin binaries are not intended to be invoked directly.
@@ -32,7 +44,11 @@ Please use this plugin through the main 'podman-machine' binary.
 	log.SetDebug(true)
 	os.Setenv("MACHINE_DEBUG", "1")
 
-	rpcd := rpcdriver.NewRPCServerDriver(d)
+	// Ctrl-C reaches the plugins too, which would die in the middle of a
+	// create or start. The client cancels their calls instead, and they exit
+	// when it stops sending heartbeats.
+	signal.Ignore(os.Interrupt)
+
 	rpc.RegisterName(rpcdriver.RPCServiceNameV0, rpcd)
 	rpc.RegisterName(rpcdriver.RPCServiceNameV1, rpcd)
 	rpc.HandleHTTP()
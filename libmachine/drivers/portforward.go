@@ -0,0 +1,77 @@
+package drivers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PortForward forwards a port on the host to a port of the machine.
+type PortForward struct {
+	Protocol  string
+	HostPort  int
+	GuestPort int
+}
+
+// String returns the forward in the hostport:guestport/protocol form
+// accepted by ParsePortForward.
+func (f PortForward) String() string {
+	return fmt.Sprintf("%d:%d/%s", f.HostPort, f.GuestPort, f.Protocol)
+}
+
+// ParsePortForward parses a [hostport:]guestport[/tcp|/udp] specification.
+// The host port defaults to the guest port and the protocol to tcp.
+func ParsePortForward(spec string) (PortForward, error) {
+	f := PortForward{Protocol: "tcp"}
+
+	ports := spec
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		ports, f.Protocol = spec[:i], strings.ToLower(spec[i+1:])
+	}
+	if f.Protocol != "tcp" && f.Protocol != "udp" {
+		return f, fmt.Errorf("Invalid protocol %q in port forward %q, expected tcp or udp", f.Protocol, spec)
+	}
+
+	parts := strings.Split(ports, ":")
+	if len(parts) > 2 {
+		return f, fmt.Errorf("Invalid port forward %q, expected [hostport:]guestport[/udp]", spec)
+	}
+
+	var err error
+	if f.GuestPort, err = parsePort(parts[len(parts)-1]); err != nil {
+		return f, fmt.Errorf("Invalid guest port in port forward %q: %s", spec, err)
+	}
+	f.HostPort = f.GuestPort
+	if len(parts) == 2 {
+		if f.HostPort, err = parsePort(parts[0]); err != nil {
+			return f, fmt.Errorf("Invalid host port in port forward %q: %s", spec, err)
+		}
+	}
+
+	return f, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("%d is out of range", port)
+	}
+	return port, nil
+}
+
+// PortForwarder is implemented by drivers that can forward host ports to the
+// machine. Forwards are kept in the driver config, applied when the machine
+// starts and, when possible, added to or removed from a running machine.
+type PortForwarder interface {
+	// AddPortForward adds a forward
+	AddPortForward(f PortForward) error
+
+	// RemovePortForward removes the forward for the host port of f
+	RemovePortForward(f PortForward) error
+
+	// ListPortForwards returns the configured forwards
+	ListPortForwards() ([]PortForward, error)
+}
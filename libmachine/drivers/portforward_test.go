@@ -0,0 +1,51 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePortForward(t *testing.T) {
+	var tests = []struct {
+		spec     string
+		expected PortForward
+	}{
+		{"80", PortForward{Protocol: "tcp", HostPort: 80, GuestPort: 80}},
+		{"8080:80", PortForward{Protocol: "tcp", HostPort: 8080, GuestPort: 80}},
+		{"53/udp", PortForward{Protocol: "udp", HostPort: 53, GuestPort: 53}},
+		{"5353:53/UDP", PortForward{Protocol: "udp", HostPort: 5353, GuestPort: 53}},
+		{"8443:443/tcp", PortForward{Protocol: "tcp", HostPort: 8443, GuestPort: 443}},
+	}
+
+	for _, test := range tests {
+		f, err := ParsePortForward(test.spec)
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, f)
+	}
+}
+
+func TestParsePortForwardInvalid(t *testing.T) {
+	var tests = []string{
+		"",
+		"http",
+		"0",
+		"70000",
+		"80/sctp",
+		"1:2:3",
+		"x:80",
+	}
+
+	for _, spec := range tests {
+		_, err := ParsePortForward(spec)
+
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestPortForwardString(t *testing.T) {
+	f := PortForward{Protocol: "udp", HostPort: 5353, GuestPort: 53}
+
+	assert.Equal(t, "5353:53/udp", f.String())
+}
@@ -0,0 +1,56 @@
+package drivers
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrRequiresStop is returned by Reconfigure when a change cannot be
+// applied while the machine is running.
+var ErrRequiresStop = errors.New("Machine must be stopped to apply the change")
+
+// Resources are the virtual hardware settings of a machine. Zero values
+// are left unchanged.
+type Resources struct {
+	CPUs     int
+	Memory   int // MB
+	DiskSize int // MB
+}
+
+func (r Resources) String() string {
+	return fmt.Sprintf("cpus=%d memory=%dMB disk-size=%dMB", r.CPUs, r.Memory, r.DiskSize)
+}
+
+// Reconfigurer is implemented by drivers that can change the resources of
+// an existing machine.
+type Reconfigurer interface {
+	// Reconfigure applies the non-zero settings of r, or returns
+	// ErrRequiresStop if the machine is running and they cannot be
+	// applied live
+	Reconfigure(r Resources) error
+}
+
+// GrowFilesystemCommand extends the partition holding the persistent data
+// of the machine, and its ext4 filesystem, to the size of the disk. It is
+// run over SSH on the first boot after the disk was resized.
+const GrowFilesystemCommand = `set -e
+part=$(awk '$1 ~ "^/dev/" && ($2 == "/" || $2 ~ "^/mnt/") { p = $1 } END { print p }' /proc/mounts)
+disk=$(echo "$part" | sed -e 's/p\?[0-9]*$//')
+num=$(echo "$part" | sed -e 's/^.*[^0-9]//')
+if command -v growpart >/dev/null; then
+  sudo growpart "$disk" "$num" || true
+else
+  echo ', +' | sudo sfdisk --no-reread -N "$num" "$disk"
+  sudo partx -u "$disk" 2>/dev/null || true
+fi
+sudo resize2fs "$part"`
+
+// GrowFilesystem waits for SSH to be available and runs
+// GrowFilesystemCommand on the machine.
+func GrowFilesystem(d Driver) error {
+	if err := WaitForSSH(d); err != nil {
+		return err
+	}
+	_, err := RunSSHCommandFromDriver(d, GrowFilesystemCommand)
+	return err
+}
@@ -0,0 +1,16 @@
+package drivers
+
+// Relocator is implemented by drivers whose machines can be moved to
+// another store, or to another host, as an archive of their directory.
+type Relocator interface {
+	// ExportFiles returns the files used by the machine out of its
+	// directory, such as the base of its disk, by their path in the
+	// archive, relative to the machine directory
+	ExportFiles() (map[string]string, error)
+
+	// Relocate prepares the machine, whose config and files were moved to
+	// its store path, for its next start: it allocates the host ports in
+	// use by the machine again, and registers the machine with the
+	// hypervisor if needed
+	Relocate() error
+}
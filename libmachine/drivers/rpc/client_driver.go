@@ -1,9 +1,12 @@
 package rpcdriver
 
 import (
+	"context"
 	"fmt"
 	"net/rpc"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"io"
@@ -28,19 +31,33 @@ type RPCClientDriverFactory interface {
 type DefaultRPCClientDriverFactory struct {
 	openedDrivers     []*RPCClientDriver
 	openedDriversLock sync.Locker
+	plugins           map[string]*sharedPlugin
+	startDriver       func(driverName string) (*RPCClientDriver, error)
+}
+
+// sharedPlugin is the plugin of a driver, serving all the machines of that
+// driver if its API version is 3 or more.
+type sharedPlugin struct {
+	once   sync.Once
+	server *RPCClientDriver
+	err    error
 }
 
 func NewRPCClientDriverFactory() RPCClientDriverFactory {
-	return &DefaultRPCClientDriverFactory{
+	f := &DefaultRPCClientDriverFactory{
 		openedDrivers:     []*RPCClientDriver{},
 		openedDriversLock: &sync.Mutex{},
+		plugins:           map[string]*sharedPlugin{},
 	}
+	f.startDriver = f.startPluginDriver
+	return f
 }
 
 type RPCClientDriver struct {
 	plugin          localbinary.DriverPlugin
 	heartbeatDoneCh chan bool
 	Client          *InternalClient
+	serverVersion   int
 }
 
 type RPCCall struct {
@@ -83,8 +100,35 @@ const (
 	RestartMethod            = `.Restart`
 	KillMethod               = `.Kill`
 	UpgradeMethod            = `.Upgrade`
+	SaveSnapshotMethod       = `.SaveSnapshot`
+	RestoreSnapshotMethod    = `.RestoreSnapshot`
+	ListSnapshotsMethod      = `.ListSnapshots`
+	RemoveSnapshotMethod     = `.RemoveSnapshot`
+	PauseMethod              = `.Pause`
+	ResumeMethod             = `.Resume`
+	SaveStateMethod          = `.SaveState`
+	AddPortForwardMethod     = `.AddPortForward`
+	RemovePortForwardMethod  = `.RemovePortForward`
+	ListPortForwardsMethod   = `.ListPortForwards`
+	ReconfigureMethod        = `.Reconfigure`
+	CreateCloneMethod        = `.CreateClone`
+	RelocateMethod           = `.Relocate`
+	ExportFilesMethod        = `.ExportFiles`
+
+	// Methods of API version 2
+	CreateContextMethod = `.CreateContext`
+	StartContextMethod  = `.StartContext`
+	CancelMethod        = `.Cancel`
+	WatchProgressMethod = `.WatchProgress`
+
+	// Methods of API version 3
+	NewDriverMethod = `.NewDriver`
 )
 
+// callIDs numbers the calls of API version 2, for the plugins to tell them
+// apart.
+var callIDs uint64
+
 func (ic *InternalClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
 	if serviceMethod != HeartbeatMethod {
 		log.Debugf("(%s) Calling %+v", ic.MachineName, serviceMethod)
@@ -92,6 +136,20 @@ func (ic *InternalClient) Call(serviceMethod string, args interface{}, reply int
 	return ic.RPCClient.Call(ic.rpcServiceName+serviceMethod, args, reply)
 }
 
+// CallContext is Call, returning early with the error of ctx once it is
+// done. The call itself goes on, its reply is dropped.
+func (ic *InternalClient) CallContext(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) error {
+	log.Debugf("(%s) Calling %+v", ic.MachineName, serviceMethod)
+	call := ic.RPCClient.Go(ic.rpcServiceName+serviceMethod, args, reply, make(chan *rpc.Call, 1))
+
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (ic *InternalClient) switchToV0() {
 	ic.rpcServiceName = RPCServiceNameV0
 }
@@ -119,56 +177,83 @@ func (f *DefaultRPCClientDriverFactory) Close() error {
 }
 
 func (f *DefaultRPCClientDriverFactory) NewRPCClientDriver(driverName string, rawDriver []byte) (*RPCClientDriver, error) {
-	mcnName := ""
-
-	p, err := localbinary.NewPlugin(driverName)
+	c, err := f.pluginDriver(driverName)
 	if err != nil {
 		return nil, err
 	}
 
-	go func() {
-		if err := p.Serve(); err != nil {
-			// TODO: Is this best approach?
-			log.Warn(err)
-			return
-		}
-	}()
+	if err := c.SetConfigRaw(rawDriver); err != nil {
+		return nil, err
+	}
 
-	addr, err := p.Address()
-	if err != nil {
-		return nil, fmt.Errorf("Error attempting to get plugin server address for RPC: %s", err)
+	mcnName := c.GetMachineName()
+	c.Client.MachineName = mcnName
+	// The output of shared plugins keeps the driver name
+	if p, ok := c.plugin.(*localbinary.Plugin); ok {
+		p.MachineName = mcnName
 	}
 
-	rpcclient, err := rpc.DialHTTP("tcp", addr)
-	if err != nil {
-		return nil, err
+	return c, nil
+}
+
+// pluginDriver returns a new driver of the shared plugin of driverName. The
+// plugin is started by the first call, and used as is by that call if it
+// can't serve several machines, in which case the next calls start their own.
+func (f *DefaultRPCClientDriverFactory) pluginDriver(driverName string) (*RPCClientDriver, error) {
+	f.openedDriversLock.Lock()
+	shared, ok := f.plugins[driverName]
+	if !ok {
+		shared = &sharedPlugin{}
+		f.plugins[driverName] = shared
 	}
+	f.openedDriversLock.Unlock()
 
-	c := &RPCClientDriver{
-		Client:          NewInternalClient(rpcclient),
-		heartbeatDoneCh: make(chan bool),
+	started := false
+	shared.once.Do(func() {
+		shared.server, shared.err = f.startDriver(driverName)
+		started = true
+	})
+	if shared.err != nil {
+		return nil, shared.err
+	}
+
+	if shared.server.serverVersion >= 3 {
+		c, err := shared.server.newDriver()
+		if err != drivers.ErrNotSupported {
+			return c, err
+		}
+	}
+	if started {
+		return shared.server, nil
 	}
+	return f.startDriver(driverName)
+}
+
+// startPluginDriver starts the plugin of a driver and keeps it alive until
+// the factory is closed.
+func (f *DefaultRPCClientDriverFactory) startPluginDriver(driverName string) (*RPCClientDriver, error) {
+	p, c, err := startPlugin(driverName)
+	if err != nil {
+		return nil, err
+	}
+	p.MachineName = driverName
+	c.Client.MachineName = driverName
+	c.plugin = p
 
 	f.openedDriversLock.Lock()
 	f.openedDrivers = append(f.openedDrivers, c)
 	f.openedDriversLock.Unlock()
 
-	var serverVersion int
-	if err := c.Client.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
-		// this is the first call we make to the server. We try to play nice with old pre 0.5.1 client,
-		// by gracefully trying old RPCServiceName, we do this only once, and keep the result for future calls.
-		log.Debugf(err.Error())
-		log.Debugf("Client (%s) with %s does not work, re-attempting with %s", c.Client.MachineName, RPCServiceNameV1, RPCServiceNameV0)
-		c.Client.switchToV0()
-		if err := c.Client.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
-			return nil, err
-		}
+	serverVersion, err := c.getServerVersion()
+	if err != nil {
+		return nil, err
 	}
 
-	if serverVersion != version.APIVersion {
+	if !compatibleAPIVersion(serverVersion) {
 		return nil, fmt.Errorf("Driver binary uses an incompatible API version (%d)", serverVersion)
 	}
 	log.Debug("Using API Version ", serverVersion)
+	c.serverVersion = serverVersion
 
 	go func(c *RPCClientDriver) {
 		for {
@@ -186,16 +271,78 @@ func (f *DefaultRPCClientDriverFactory) NewRPCClientDriver(driverName string, ra
 		}
 	}(c)
 
-	if err := c.SetConfigRaw(rawDriver); err != nil {
+	return c, nil
+}
+
+// newDriver returns a client of a new driver served by the plugin of c,
+// sharing its connection. It is closed with c.
+func (c *RPCClientDriver) newDriver() (*RPCClientDriver, error) {
+	var serviceName string
+	if err := c.optionalCall(NewDriverMethod, struct{}{}, &serviceName); err != nil {
 		return nil, err
 	}
 
-	mcnName = c.GetMachineName()
-	p.MachineName = mcnName
-	c.Client.MachineName = mcnName
-	c.plugin = p
+	return &RPCClientDriver{
+		Client: &InternalClient{
+			MachineName:    c.Client.MachineName,
+			RPCClient:      c.Client.RPCClient,
+			rpcServiceName: serviceName,
+		},
+		serverVersion: c.serverVersion,
+	}, nil
+}
 
-	return c, nil
+// startPlugin launches the plugin binary of a driver and connects to it.
+func startPlugin(driverName string) (*localbinary.Plugin, *RPCClientDriver, error) {
+	p, err := localbinary.NewPlugin(driverName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	go func() {
+		if err := p.Serve(); err != nil {
+			// TODO: Is this best approach?
+			log.Warn(err)
+			return
+		}
+	}()
+
+	addr, err := p.Address()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error attempting to get plugin server address for RPC: %s", err)
+	}
+
+	rpcclient, err := rpc.DialHTTP("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return p, &RPCClientDriver{
+		Client:          NewInternalClient(rpcclient),
+		heartbeatDoneCh: make(chan bool),
+	}, nil
+}
+
+// compatibleAPIVersion tells whether plugins of API version v can be used.
+func compatibleAPIVersion(v int) bool {
+	return v >= version.MinAPIVersion && v <= version.APIVersion
+}
+
+// getServerVersion returns the API version of the plugin server.
+func (c *RPCClientDriver) getServerVersion() (int, error) {
+	var serverVersion int
+	if err := c.Client.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
+		// this is the first call we make to the server. We try to play nice with old pre 0.5.1 client,
+		// by gracefully trying old RPCServiceName, we do this only once, and keep the result for future calls.
+		log.Debugf(err.Error())
+		log.Debugf("Client (%s) with %s does not work, re-attempting with %s", c.Client.MachineName, RPCServiceNameV1, RPCServiceNameV0)
+		c.Client.switchToV0()
+		if err := c.Client.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
+			return 0, err
+		}
+	}
+
+	return serverVersion, nil
 }
 
 func (c *RPCClientDriver) MarshalJSON() ([]byte, error) {
@@ -342,6 +489,12 @@ func (c *RPCClientDriver) Create() error {
 	return c.Client.Call(CreateMethod, struct{}{}, nil)
 }
 
+// CreateContext creates the machine. With plugins of API version 1, the
+// creation goes on when ctx is done, and there is no progress.
+func (c *RPCClientDriver) CreateContext(ctx context.Context) error {
+	return c.contextCall(ctx, CreateContextMethod, CreateMethod)
+}
+
 func (c *RPCClientDriver) Remove() error {
 	return c.Client.Call(RemoveMethod, struct{}{}, nil)
 }
@@ -350,6 +503,12 @@ func (c *RPCClientDriver) Start() error {
 	return c.Client.Call(StartMethod, struct{}{}, nil)
 }
 
+// StartContext starts the machine. With plugins of API version 1, the start
+// goes on when ctx is done, and there is no progress.
+func (c *RPCClientDriver) StartContext(ctx context.Context) error {
+	return c.contextCall(ctx, StartContextMethod, StartMethod)
+}
+
 func (c *RPCClientDriver) Stop() error {
 	return c.Client.Call(StopMethod, struct{}{}, nil)
 }
@@ -365,3 +524,163 @@ func (c *RPCClientDriver) Kill() error {
 func (c *RPCClientDriver) Upgrade() error {
 	return c.Client.Call(UpgradeMethod, struct{}{}, nil)
 }
+
+// Helper method to call methods backing optional driver interfaces. Drivers
+// which do not implement the interface, and plugins too old to know the
+// method, are both reported as drivers.ErrNotSupported.
+func (c *RPCClientDriver) optionalCall(method string, args interface{}, reply interface{}) error {
+	err := c.Client.Call(method, args, reply)
+	if err == nil {
+		return nil
+	}
+	if err.Error() == drivers.ErrNotSupported.Error() || strings.HasPrefix(err.Error(), "rpc: can't find method") {
+		return drivers.ErrNotSupported
+	}
+	return err
+}
+
+func (c *RPCClientDriver) SaveSnapshot(name string) error {
+	return c.optionalCall(SaveSnapshotMethod, name, nil)
+}
+
+func (c *RPCClientDriver) RestoreSnapshot(name string) error {
+	return c.optionalCall(RestoreSnapshotMethod, name, nil)
+}
+
+func (c *RPCClientDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	var snapshots []drivers.Snapshot
+
+	if err := c.optionalCall(ListSnapshotsMethod, struct{}{}, &snapshots); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+func (c *RPCClientDriver) RemoveSnapshot(name string) error {
+	return c.optionalCall(RemoveSnapshotMethod, name, nil)
+}
+
+func (c *RPCClientDriver) Pause() error {
+	return c.optionalCall(PauseMethod, struct{}{}, nil)
+}
+
+func (c *RPCClientDriver) Resume() error {
+	return c.optionalCall(ResumeMethod, struct{}{}, nil)
+}
+
+func (c *RPCClientDriver) SaveState() error {
+	return c.optionalCall(SaveStateMethod, struct{}{}, nil)
+}
+
+func (c *RPCClientDriver) AddPortForward(f drivers.PortForward) error {
+	return c.optionalCall(AddPortForwardMethod, f, nil)
+}
+
+func (c *RPCClientDriver) RemovePortForward(f drivers.PortForward) error {
+	return c.optionalCall(RemovePortForwardMethod, f, nil)
+}
+
+func (c *RPCClientDriver) ListPortForwards() ([]drivers.PortForward, error) {
+	var forwards []drivers.PortForward
+
+	if err := c.optionalCall(ListPortForwardsMethod, struct{}{}, &forwards); err != nil {
+		return nil, err
+	}
+
+	return forwards, nil
+}
+
+func (c *RPCClientDriver) Reconfigure(r drivers.Resources) error {
+	err := c.optionalCall(ReconfigureMethod, r, nil)
+	if err != nil && err.Error() == drivers.ErrRequiresStop.Error() {
+		return drivers.ErrRequiresStop
+	}
+	return err
+}
+
+func (c *RPCClientDriver) CreateClone(source []byte) error {
+	return c.optionalCall(CreateCloneMethod, source, nil)
+}
+
+func (c *RPCClientDriver) ExportFiles() (map[string]string, error) {
+	var files map[string]string
+
+	if err := c.optionalCall(ExportFilesMethod, struct{}{}, &files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (c *RPCClientDriver) Relocate() error {
+	return c.optionalCall(RelocateMethod, struct{}{}, nil)
+}
+
+// contextCall makes a call of API version 2 with the deadline of ctx,
+// cancels it when ctx is done, and passes its progress events to the
+// receiver of ctx. Older plugins get v1Method, left behind if ctx is done.
+func (c *RPCClientDriver) contextCall(ctx context.Context, method, v1Method string) error {
+	if c.serverVersion < 2 {
+		return c.Client.CallContext(ctx, v1Method, struct{}{}, nil)
+	}
+
+	args := ContextArgs{ID: atomic.AddUint64(&callIDs, 1)}
+	if deadline, ok := ctx.Deadline(); ok {
+		args.Timeout = time.Until(deadline)
+	}
+
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		c.watchProgress(watchCtx, args.ID)
+	}()
+
+	err := c.Client.CallContext(ctx, method, &args, nil)
+	if err != nil && err == ctx.Err() {
+		log.Debugf("Cancelling call %d: %s", args.ID, err)
+		if err := c.Client.Call(CancelMethod, args.ID, nil); err != nil {
+			log.Debugf("Failed to cancel call %d: %s", args.ID, err)
+		}
+		return err
+	}
+
+	// The last events are sent with the end of the call.
+	<-watchDone
+
+	return contextError(err)
+}
+
+func (c *RPCClientDriver) watchProgress(ctx context.Context, id uint64) {
+	for {
+		var reply ProgressReply
+		if err := c.Client.CallContext(ctx, WatchProgressMethod, id, &reply); err != nil {
+			log.Debugf("Stopped watching the progress of call %d: %s", id, err)
+			return
+		}
+
+		for _, p := range reply.Events {
+			drivers.ReportProgress(ctx, p)
+		}
+		if reply.Done {
+			return
+		}
+	}
+}
+
+// contextError maps the errors of contexts returned by plugins back to their
+// values.
+func contextError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch err.Error() {
+	case context.Canceled.Error():
+		return context.Canceled
+	case context.DeadlineExceeded.Error():
+		return context.DeadlineExceeded
+	}
+	return err
+}
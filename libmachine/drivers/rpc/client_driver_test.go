@@ -0,0 +1,372 @@
+package rpcdriver
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/mcnflag"
+	"github.com/boot2podman/machine/libmachine/state"
+	"github.com/boot2podman/machine/libmachine/version"
+	"github.com/stretchr/testify/assert"
+)
+
+type snapshotDriver struct {
+	*fakedriver.Driver
+	snapshots []drivers.Snapshot
+}
+
+func (d *snapshotDriver) SaveSnapshot(name string) error {
+	d.snapshots = append(d.snapshots, drivers.Snapshot{Name: name})
+	return nil
+}
+
+func (d *snapshotDriver) RestoreSnapshot(name string) error {
+	return nil
+}
+
+func (d *snapshotDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	return d.snapshots, nil
+}
+
+func (d *snapshotDriver) RemoveSnapshot(name string) error {
+	return nil
+}
+
+// newTestClientDriver serves d in-process and returns a client talking to it
+// through the same RPC protocol used with plugin binaries.
+func newTestClientDriver(t *testing.T, d drivers.Driver) *RPCClientDriver {
+	server := rpc.NewServer()
+	if err := server.RegisterName(RPCServiceNameV1, NewRPCServerDriver(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+
+	return &RPCClientDriver{
+		Client: NewInternalClient(rpc.NewClient(clientConn)),
+	}
+}
+
+func TestRPCClientDriverSnapshots(t *testing.T) {
+	client := newTestClientDriver(t, &snapshotDriver{Driver: &fakedriver.Driver{}})
+
+	assert.NoError(t, client.SaveSnapshot("first"))
+	assert.NoError(t, client.SaveSnapshot("second"))
+
+	snapshots, err := client.ListSnapshots()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Snapshot{{Name: "first"}, {Name: "second"}}, snapshots)
+}
+
+func TestRPCClientDriverSnapshotsNotSupported(t *testing.T) {
+	client := newTestClientDriver(t, &fakedriver.Driver{})
+
+	snapshots, err := client.ListSnapshots()
+
+	assert.Nil(t, snapshots)
+	assert.Equal(t, drivers.ErrNotSupported, err)
+	assert.Equal(t, drivers.ErrNotSupported, client.SaveSnapshot("snap"))
+}
+
+func TestRPCClientDriverMethodUnknownToPlugin(t *testing.T) {
+	client := newTestClientDriver(t, &fakedriver.Driver{})
+
+	err := client.optionalCall(".NoSuchMethod", struct{}{}, nil)
+
+	assert.Equal(t, drivers.ErrNotSupported, err)
+}
+
+type portForwardDriver struct {
+	*fakedriver.Driver
+	forwards []drivers.PortForward
+}
+
+func (d *portForwardDriver) AddPortForward(f drivers.PortForward) error {
+	d.forwards = append(d.forwards, f)
+	return nil
+}
+
+func (d *portForwardDriver) RemovePortForward(f drivers.PortForward) error {
+	d.forwards = nil
+	return nil
+}
+
+func (d *portForwardDriver) ListPortForwards() ([]drivers.PortForward, error) {
+	return d.forwards, nil
+}
+
+func TestRPCClientDriverPortForwards(t *testing.T) {
+	client := newTestClientDriver(t, &portForwardDriver{Driver: &fakedriver.Driver{}})
+	f := drivers.PortForward{Protocol: "udp", HostPort: 5353, GuestPort: 53}
+
+	assert.NoError(t, client.AddPortForward(f))
+
+	forwards, err := client.ListPortForwards()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.PortForward{f}, forwards)
+	assert.Equal(t, drivers.ErrNotSupported, newTestClientDriver(t, &fakedriver.Driver{}).RemovePortForward(f))
+}
+
+type reconfigureDriver struct {
+	*fakedriver.Driver
+	resources drivers.Resources
+}
+
+func (d *reconfigureDriver) Reconfigure(r drivers.Resources) error {
+	if d.MockState == state.Running {
+		return drivers.ErrRequiresStop
+	}
+	d.resources = r
+	return nil
+}
+
+func TestRPCClientDriverReconfigure(t *testing.T) {
+	d := &reconfigureDriver{Driver: &fakedriver.Driver{}}
+	client := newTestClientDriver(t, d)
+	r := drivers.Resources{CPUs: 2, Memory: 2048, DiskSize: 40000}
+
+	assert.NoError(t, client.Reconfigure(r))
+	assert.Equal(t, r, d.resources)
+
+	d.MockState = state.Running
+
+	assert.Equal(t, drivers.ErrRequiresStop, client.Reconfigure(r))
+	assert.Equal(t, drivers.ErrNotSupported, newTestClientDriver(t, &fakedriver.Driver{}).Reconfigure(r))
+}
+
+type flagsDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *flagsDriver) DriverName() string {
+	return "flags"
+}
+
+func (d *flagsDriver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.IntFlag{Name: "flags-memory", EnvVar: "FLAGS_MEMORY", Value: 1024},
+	}
+}
+
+func TestRPCClientDriverPluginInfo(t *testing.T) {
+	client := newTestClientDriver(t, &flagsDriver{Driver: &fakedriver.Driver{}})
+
+	info, err := client.pluginInfo("flags")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &PluginInfo{
+		DriverName:  "flags",
+		APIVersion:  version.APIVersion,
+		Compatible:  true,
+		CreateFlags: []mcnflag.Flag{&mcnflag.IntFlag{Name: "flags-memory", EnvVar: "FLAGS_MEMORY", Value: 1024}},
+	}, info)
+}
+
+type contextDriver struct {
+	*fakedriver.Driver
+	created   string
+	cancelled chan error
+}
+
+func (d *contextDriver) Create() error {
+	d.created = "Create"
+	return nil
+}
+
+func (d *contextDriver) CreateContext(ctx context.Context) error {
+	drivers.ReportProgress(ctx, drivers.Progress{Phase: "disk", Percent: 50, Message: "Creating disk"})
+	drivers.ReportProgress(ctx, drivers.Progress{Phase: "disk", Percent: 100, Message: "Created disk"})
+	d.created = "CreateContext"
+	return nil
+}
+
+func (d *contextDriver) StartContext(ctx context.Context) error {
+	<-ctx.Done()
+	d.cancelled <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestRPCClientDriverCreateContextProgress(t *testing.T) {
+	d := &contextDriver{Driver: &fakedriver.Driver{}}
+	client := newTestClientDriver(t, d)
+	client.serverVersion = 2
+
+	progress := []drivers.Progress{}
+	ctx := drivers.WithProgress(context.Background(), func(p drivers.Progress) {
+		progress = append(progress, p)
+	})
+
+	assert.NoError(t, client.CreateContext(ctx))
+	assert.Equal(t, "CreateContext", d.created)
+	assert.Equal(t, []drivers.Progress{
+		{Phase: "disk", Percent: 50, Message: "Creating disk"},
+		{Phase: "disk", Percent: 100, Message: "Created disk"},
+	}, progress)
+}
+
+func TestRPCClientDriverStartContextCancel(t *testing.T) {
+	d := &contextDriver{Driver: &fakedriver.Driver{}, cancelled: make(chan error, 1)}
+	client := newTestClientDriver(t, d)
+	client.serverVersion = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	assert.Equal(t, context.Canceled, client.StartContext(ctx))
+	assert.Equal(t, context.Canceled, <-d.cancelled)
+}
+
+func TestRPCClientDriverStartContextDeadline(t *testing.T) {
+	d := &contextDriver{Driver: &fakedriver.Driver{}, cancelled: make(chan error, 1)}
+	client := newTestClientDriver(t, d)
+	client.serverVersion = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, client.StartContext(ctx))
+	// The plugin gets the deadline too, but the cancellation may come first
+	assert.Error(t, <-d.cancelled)
+}
+
+func TestRPCClientDriverCreateContextAPIVersion1(t *testing.T) {
+	d := &contextDriver{Driver: &fakedriver.Driver{}}
+	client := newTestClientDriver(t, d)
+	client.serverVersion = 1
+
+	assert.NoError(t, client.CreateContext(context.Background()))
+	assert.Equal(t, "Create", d.created)
+}
+
+type cloneDriver struct {
+	*fakedriver.Driver
+	source string
+}
+
+func (d *cloneDriver) CreateClone(source []byte) error {
+	d.source = string(source)
+	return nil
+}
+
+func TestRPCClientDriverCreateClone(t *testing.T) {
+	d := &cloneDriver{Driver: &fakedriver.Driver{}}
+	client := newTestClientDriver(t, d)
+
+	assert.NoError(t, client.CreateClone([]byte(`{"MachineName":"src"}`)))
+	assert.Equal(t, `{"MachineName":"src"}`, d.source)
+	assert.Equal(t, drivers.ErrNotSupported, newTestClientDriver(t, &fakedriver.Driver{}).CreateClone(nil))
+}
+
+type relocateDriver struct {
+	*fakedriver.Driver
+	relocated bool
+}
+
+func (d *relocateDriver) ExportFiles() (map[string]string, error) {
+	return map[string]string{"base.qcow2": "/store/bases/box.qcow2"}, nil
+}
+
+func (d *relocateDriver) Relocate() error {
+	d.relocated = true
+	return nil
+}
+
+func TestRPCClientDriverRelocate(t *testing.T) {
+	d := &relocateDriver{Driver: &fakedriver.Driver{}}
+	client := newTestClientDriver(t, d)
+
+	assert.NoError(t, client.Relocate())
+	assert.True(t, d.relocated)
+	assert.Equal(t, drivers.ErrNotSupported, newTestClientDriver(t, &fakedriver.Driver{}).Relocate())
+
+	files, err := client.ExportFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"base.qcow2": "/store/bases/box.qcow2"}, files)
+	_, err = newTestClientDriver(t, &fakedriver.Driver{}).ExportFiles()
+	assert.Equal(t, drivers.ErrNotSupported, err)
+}
+
+// newTestPoolClientDriver serves the drivers made by newDriver in-process,
+// like the plugins of API version 3.
+func newTestPoolClientDriver(t *testing.T, newDriver func() drivers.Driver) *RPCClientDriver {
+	server := rpc.NewServer()
+	if err := server.RegisterName(RPCServiceNameV1, NewRPCServerDriverPool(server, newDriver)); err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+
+	return &RPCClientDriver{
+		Client:        NewInternalClient(rpc.NewClient(clientConn)),
+		serverVersion: version.APIVersion,
+	}
+}
+
+func TestRPCClientDriverNewDriver(t *testing.T) {
+	server := newTestPoolClientDriver(t, func() drivers.Driver { return &fakedriver.Driver{} })
+
+	box, err := server.newDriver()
+	assert.NoError(t, err)
+	dev, err := server.newDriver()
+	assert.NoError(t, err)
+
+	assert.NoError(t, box.SetConfigRaw([]byte(`{"MockName":"box"}`)))
+	assert.NoError(t, dev.SetConfigRaw([]byte(`{"MockName":"dev"}`)))
+
+	assert.Equal(t, "box", box.GetMachineName())
+	assert.Equal(t, "dev", dev.GetMachineName())
+}
+
+func TestRPCClientDriverNewDriverNotSupported(t *testing.T) {
+	server := newTestClientDriver(t, &fakedriver.Driver{})
+
+	_, err := server.newDriver()
+	assert.Equal(t, drivers.ErrNotSupported, err)
+}
+
+func TestRPCClientDriverFactorySharesPlugin(t *testing.T) {
+	started := 0
+	f := NewRPCClientDriverFactory().(*DefaultRPCClientDriverFactory)
+	f.startDriver = func(driverName string) (*RPCClientDriver, error) {
+		started++
+		return newTestPoolClientDriver(t, func() drivers.Driver { return &fakedriver.Driver{} }), nil
+	}
+
+	box, err := f.NewRPCClientDriver("fake", []byte(`{"MockName":"box"}`))
+	assert.NoError(t, err)
+	dev, err := f.NewRPCClientDriver("fake", []byte(`{"MockName":"dev"}`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, started)
+	assert.Equal(t, "box", box.GetMachineName())
+	assert.Equal(t, "dev", dev.GetMachineName())
+}
+
+func TestRPCClientDriverFactoryPluginPerMachine(t *testing.T) {
+	started := 0
+	f := NewRPCClientDriverFactory().(*DefaultRPCClientDriverFactory)
+	f.startDriver = func(driverName string) (*RPCClientDriver, error) {
+		started++
+		c := newTestClientDriver(t, &fakedriver.Driver{})
+		c.serverVersion = 2
+		return c, nil
+	}
+
+	box, err := f.NewRPCClientDriver("fake", []byte(`{"MockName":"box"}`))
+	assert.NoError(t, err)
+	dev, err := f.NewRPCClientDriver("fake", []byte(`{"MockName":"dev"}`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, started)
+	assert.Equal(t, "box", box.GetMachineName())
+	assert.Equal(t, "dev", dev.GetMachineName())
+}
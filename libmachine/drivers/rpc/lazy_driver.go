@@ -0,0 +1,319 @@
+package rpcdriver
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/mcnflag"
+	"github.com/boot2podman/machine/libmachine/state"
+)
+
+// LazyRPCClientDriver is the driver of a stored machine, whose plugin is only
+// started by the first method needing it. The names of the machine and of
+// its driver are answered from the stored config, so that listing many
+// machines doesn't start a plugin for each of them up front.
+type LazyRPCClientDriver struct {
+	factory     RPCClientDriverFactory
+	driverName  string
+	machineName string
+
+	lock      sync.Mutex
+	rawDriver []byte
+	driver    *RPCClientDriver
+	err       error
+}
+
+// NewLazyRPCClientDriver returns a driver starting the plugin of driverName
+// with the config rawDriver, through factory, when needed.
+func NewLazyRPCClientDriver(factory RPCClientDriverFactory, driverName string, rawDriver []byte) *LazyRPCClientDriver {
+	var config struct {
+		MachineName string
+	}
+	if err := json.Unmarshal(rawDriver, &config); err != nil {
+		log.Debugf("Error reading the machine name from the driver config: %s", err)
+	}
+
+	return &LazyRPCClientDriver{
+		factory:     factory,
+		driverName:  driverName,
+		machineName: config.MachineName,
+		rawDriver:   rawDriver,
+	}
+}
+
+// client starts the plugin, once. Its error is kept for later calls.
+func (d *LazyRPCClientDriver) client() (*RPCClientDriver, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.driver == nil && d.err == nil {
+		d.driver, d.err = d.factory.NewRPCClientDriver(d.driverName, d.rawDriver)
+	}
+
+	return d.driver, d.err
+}
+
+// Started tells whether the plugin was started.
+func (d *LazyRPCClientDriver) Started() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.driver != nil
+}
+
+func (d *LazyRPCClientDriver) MarshalJSON() ([]byte, error) {
+	d.lock.Lock()
+	driver, rawDriver := d.driver, d.rawDriver
+	d.lock.Unlock()
+
+	if driver == nil {
+		return rawDriver, nil
+	}
+	return driver.GetConfigRaw()
+}
+
+func (d *LazyRPCClientDriver) UnmarshalJSON(data []byte) error {
+	d.lock.Lock()
+	driver := d.driver
+	d.rawDriver = data
+	d.lock.Unlock()
+
+	if driver == nil {
+		return nil
+	}
+	return driver.SetConfigRaw(data)
+}
+
+// DriverName returns the name of the driver, from the stored config
+func (d *LazyRPCClientDriver) DriverName() string {
+	return d.driverName
+}
+
+// GetMachineName returns the name of the machine, from the stored config
+func (d *LazyRPCClientDriver) GetMachineName() string {
+	if d.machineName == "" {
+		c, err := d.client()
+		if err != nil {
+			log.Warnf("Error attempting to get machine name: %s", err)
+			return ""
+		}
+		return c.GetMachineName()
+	}
+	return d.machineName
+}
+
+func (d *LazyRPCClientDriver) GetCreateFlags() []mcnflag.Flag {
+	c, err := d.client()
+	if err != nil {
+		log.Warnf("Error attempting to get create flags: %s", err)
+		return nil
+	}
+	return c.GetCreateFlags()
+}
+
+func (d *LazyRPCClientDriver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	c, err := d.client()
+	if err != nil {
+		return err
+	}
+	return c.SetConfigFromFlags(flags)
+}
+
+func (d *LazyRPCClientDriver) GetURL() (string, error) {
+	c, err := d.client()
+	if err != nil {
+		return "", err
+	}
+	return c.GetURL()
+}
+
+func (d *LazyRPCClientDriver) GetIP() (string, error) {
+	c, err := d.client()
+	if err != nil {
+		return "", err
+	}
+	return c.GetIP()
+}
+
+func (d *LazyRPCClientDriver) GetSSHHostname() (string, error) {
+	c, err := d.client()
+	if err != nil {
+		return "", err
+	}
+	return c.GetSSHHostname()
+}
+
+func (d *LazyRPCClientDriver) GetSSHKeyPath() string {
+	c, err := d.client()
+	if err != nil {
+		log.Warnf("Error attempting to get SSH key path: %s", err)
+		return ""
+	}
+	return c.GetSSHKeyPath()
+}
+
+func (d *LazyRPCClientDriver) GetSSHPort() (int, error) {
+	c, err := d.client()
+	if err != nil {
+		return 0, err
+	}
+	return c.GetSSHPort()
+}
+
+func (d *LazyRPCClientDriver) GetSSHUsername() string {
+	c, err := d.client()
+	if err != nil {
+		log.Warnf("Error attempting to get SSH username: %s", err)
+		return ""
+	}
+	return c.GetSSHUsername()
+}
+
+func (d *LazyRPCClientDriver) GetState() (state.State, error) {
+	c, err := d.client()
+	if err != nil {
+		return state.Error, err
+	}
+	return c.GetState()
+}
+
+// call runs action with the started plugin.
+func (d *LazyRPCClientDriver) call(action func(*RPCClientDriver) error) error {
+	c, err := d.client()
+	if err != nil {
+		return err
+	}
+	return action(c)
+}
+
+func (d *LazyRPCClientDriver) PreCreateCheck() error {
+	return d.call((*RPCClientDriver).PreCreateCheck)
+}
+
+func (d *LazyRPCClientDriver) Create() error {
+	return d.call((*RPCClientDriver).Create)
+}
+
+func (d *LazyRPCClientDriver) CreateContext(ctx context.Context) error {
+	return d.call(func(c *RPCClientDriver) error {
+		return c.CreateContext(ctx)
+	})
+}
+
+func (d *LazyRPCClientDriver) Remove() error {
+	return d.call((*RPCClientDriver).Remove)
+}
+
+func (d *LazyRPCClientDriver) Start() error {
+	return d.call((*RPCClientDriver).Start)
+}
+
+func (d *LazyRPCClientDriver) StartContext(ctx context.Context) error {
+	return d.call(func(c *RPCClientDriver) error {
+		return c.StartContext(ctx)
+	})
+}
+
+func (d *LazyRPCClientDriver) Stop() error {
+	return d.call((*RPCClientDriver).Stop)
+}
+
+func (d *LazyRPCClientDriver) Restart() error {
+	return d.call((*RPCClientDriver).Restart)
+}
+
+func (d *LazyRPCClientDriver) Kill() error {
+	return d.call((*RPCClientDriver).Kill)
+}
+
+func (d *LazyRPCClientDriver) Upgrade() error {
+	return d.call((*RPCClientDriver).Upgrade)
+}
+
+func (d *LazyRPCClientDriver) SaveSnapshot(name string) error {
+	return d.call(func(c *RPCClientDriver) error {
+		return c.SaveSnapshot(name)
+	})
+}
+
+func (d *LazyRPCClientDriver) RestoreSnapshot(name string) error {
+	return d.call(func(c *RPCClientDriver) error {
+		return c.RestoreSnapshot(name)
+	})
+}
+
+func (d *LazyRPCClientDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	var snapshots []drivers.Snapshot
+	err := d.call(func(c *RPCClientDriver) (err error) {
+		snapshots, err = c.ListSnapshots()
+		return err
+	})
+	return snapshots, err
+}
+
+func (d *LazyRPCClientDriver) RemoveSnapshot(name string) error {
+	return d.call(func(c *RPCClientDriver) error {
+		return c.RemoveSnapshot(name)
+	})
+}
+
+func (d *LazyRPCClientDriver) Pause() error {
+	return d.call((*RPCClientDriver).Pause)
+}
+
+func (d *LazyRPCClientDriver) Resume() error {
+	return d.call((*RPCClientDriver).Resume)
+}
+
+func (d *LazyRPCClientDriver) SaveState() error {
+	return d.call((*RPCClientDriver).SaveState)
+}
+
+func (d *LazyRPCClientDriver) AddPortForward(f drivers.PortForward) error {
+	return d.call(func(c *RPCClientDriver) error {
+		return c.AddPortForward(f)
+	})
+}
+
+func (d *LazyRPCClientDriver) RemovePortForward(f drivers.PortForward) error {
+	return d.call(func(c *RPCClientDriver) error {
+		return c.RemovePortForward(f)
+	})
+}
+
+func (d *LazyRPCClientDriver) ListPortForwards() ([]drivers.PortForward, error) {
+	var forwards []drivers.PortForward
+	err := d.call(func(c *RPCClientDriver) (err error) {
+		forwards, err = c.ListPortForwards()
+		return err
+	})
+	return forwards, err
+}
+
+func (d *LazyRPCClientDriver) Reconfigure(r drivers.Resources) error {
+	return d.call(func(c *RPCClientDriver) error {
+		return c.Reconfigure(r)
+	})
+}
+
+func (d *LazyRPCClientDriver) CreateClone(source []byte) error {
+	return d.call(func(c *RPCClientDriver) error {
+		return c.CreateClone(source)
+	})
+}
+
+func (d *LazyRPCClientDriver) ExportFiles() (map[string]string, error) {
+	var files map[string]string
+	err := d.call(func(c *RPCClientDriver) (err error) {
+		files, err = c.ExportFiles()
+		return err
+	})
+	return files, err
+}
+
+func (d *LazyRPCClientDriver) Relocate() error {
+	return d.call((*RPCClientDriver).Relocate)
+}
@@ -0,0 +1,88 @@
+package rpcdriver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClientDriverFactory struct {
+	t       *testing.T
+	driver  drivers.Driver
+	started []string
+}
+
+func (f *fakeClientDriverFactory) NewRPCClientDriver(driverName string, rawDriver []byte) (*RPCClientDriver, error) {
+	f.started = append(f.started, driverName)
+	c := newTestClientDriver(f.t, f.driver)
+	if err := c.SetConfigRaw(rawDriver); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (f *fakeClientDriverFactory) Close() error {
+	return nil
+}
+
+func TestLazyRPCClientDriverConfigFromStore(t *testing.T) {
+	factory := &fakeClientDriverFactory{t: t, driver: &fakedriver.Driver{}}
+	rawDriver := []byte(`{"MachineName":"box","MockState":1}`)
+
+	d := NewLazyRPCClientDriver(factory, "fake", rawDriver)
+
+	assert.Equal(t, "box", d.GetMachineName())
+	assert.Equal(t, "fake", d.DriverName())
+
+	data, err := json.Marshal(d)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(rawDriver), string(data))
+	assert.False(t, d.Started())
+	assert.Empty(t, factory.started)
+}
+
+func TestLazyRPCClientDriverStartsPluginOnce(t *testing.T) {
+	factory := &fakeClientDriverFactory{t: t, driver: &fakedriver.Driver{}}
+
+	d := NewLazyRPCClientDriver(factory, "fake", []byte(`{"MachineName":"box","MockState":1}`))
+
+	s, err := d.GetState()
+
+	assert.NoError(t, err)
+	assert.Equal(t, state.Running, s)
+	assert.NoError(t, d.Stop())
+
+	s, err = d.GetState()
+
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+	assert.True(t, d.Started())
+	assert.Equal(t, []string{"fake"}, factory.started)
+
+	// The config now comes from the plugin
+	data, err := json.Marshal(d)
+
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"MockState":4`)
+}
+
+func TestLazyRPCClientDriverOptionalInterfaces(t *testing.T) {
+	factory := &fakeClientDriverFactory{t: t, driver: &fakedriver.Driver{}}
+
+	var d drivers.Driver = NewLazyRPCClientDriver(factory, "fake", []byte(`{}`))
+
+	s, ok := d.(drivers.Snapshotter)
+
+	assert.True(t, ok)
+	assert.Equal(t, drivers.ErrNotSupported, s.SaveSnapshot("snap"))
+	assert.Implements(t, (*drivers.Pauser)(nil), d)
+	assert.Implements(t, (*drivers.Saver)(nil), d)
+	assert.Implements(t, (*drivers.PortForwarder)(nil), d)
+	assert.Implements(t, (*drivers.Reconfigurer)(nil), d)
+	assert.Implements(t, (*drivers.ContextDriver)(nil), d)
+}
@@ -0,0 +1,58 @@
+package rpcdriver
+
+import (
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/mcnflag"
+)
+
+// PluginInfo describes a driver plugin, as reported by the plugin itself.
+type PluginInfo struct {
+	DriverName  string
+	APIVersion  int
+	Compatible  bool
+	CreateFlags []mcnflag.Flag
+}
+
+// QueryPlugin launches the plugin of a driver once, to ask for its API
+// version, name and create flags. A plugin with an incompatible API version
+// is reported as such rather than refused, but only its version is known.
+func QueryPlugin(driverName string) (*PluginInfo, error) {
+	p, c, err := startPlugin(driverName)
+	if err != nil {
+		return nil, err
+	}
+	p.MachineName = driverName
+	c.Client.MachineName = driverName
+
+	defer func() {
+		if err := c.Client.Call(CloseMethod, struct{}{}, nil); err != nil {
+			log.Debugf("Failed to make call to close driver server: %s", err)
+		}
+		p.Close()
+	}()
+
+	return c.pluginInfo(driverName)
+}
+
+func (c *RPCClientDriver) pluginInfo(driverName string) (*PluginInfo, error) {
+	serverVersion, err := c.getServerVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &PluginInfo{
+		DriverName: driverName,
+		APIVersion: serverVersion,
+		Compatible: compatibleAPIVersion(serverVersion),
+	}
+	if !info.Compatible {
+		return info, nil
+	}
+
+	if name := c.DriverName(); name != "" {
+		info.DriverName = name
+	}
+	info.CreateFlags = c.GetCreateFlags()
+
+	return info, nil
+}
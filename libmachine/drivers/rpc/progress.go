@@ -0,0 +1,150 @@
+package rpcdriver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+)
+
+// ContextArgs are the arguments of the calls of API version 2 which can be
+// cancelled. ID identifies the call to the Cancel and WatchProgress calls.
+type ContextArgs struct {
+	ID      uint64
+	Timeout time.Duration // zero for no deadline
+}
+
+// ProgressReply carries the progress events of a call. Done is set with the
+// last events, once the call returned.
+type ProgressReply struct {
+	Events []drivers.Progress
+	Done   bool
+}
+
+// serverCall tracks a call of API version 2 on the plugin side. It is
+// created by whichever of the call, Cancel or WatchProgress arrives first,
+// as the RPC server runs them concurrently.
+type serverCall struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock    sync.Mutex
+	events  []drivers.Progress
+	done    bool
+	changed chan struct{}
+}
+
+type serverCalls struct {
+	lock  sync.Mutex
+	calls map[uint64]*serverCall
+	// finished are the ids of the calls which returned, so that a Cancel
+	// or WatchProgress arriving late does not track them again.
+	finished map[uint64]bool
+}
+
+func (s *serverCalls) get(id uint64) *serverCall {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.getLocked(id)
+}
+
+// lookup returns the call for Cancel and WatchProgress. Calls which did not
+// start yet are tracked, and the ones which finished and were removed are
+// not found.
+func (s *serverCalls) lookup(id uint64) (*serverCall, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.finished[id] {
+		call, ok := s.calls[id]
+		return call, ok
+	}
+	return s.getLocked(id), true
+}
+
+func (s *serverCalls) getLocked(id uint64) *serverCall {
+	if s.calls == nil {
+		s.calls = map[uint64]*serverCall{}
+	}
+
+	call, ok := s.calls[id]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		call = &serverCall{
+			ctx:     ctx,
+			cancel:  cancel,
+			changed: make(chan struct{}),
+		}
+		s.calls[id] = call
+	}
+
+	return call
+}
+
+func (s *serverCalls) remove(id uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.calls, id)
+}
+
+// finish marks the call as returned. The call is kept for WatchProgress to
+// return its last events, unless the client stopped watching it because it
+// was cancelled or timed out.
+func (s *serverCalls) finish(id uint64, call *serverCall, abandoned bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	call.finish()
+
+	if s.finished == nil {
+		s.finished = map[uint64]bool{}
+	}
+	s.finished[id] = true
+	if abandoned {
+		delete(s.calls, id)
+	}
+}
+
+// notify wakes up the waiters. It is called with the lock held.
+func (c *serverCall) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *serverCall) report(p drivers.Progress) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.events = append(c.events, p)
+	c.notify()
+}
+
+func (c *serverCall) finish() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.done = true
+	c.notify()
+	c.cancel()
+}
+
+// wait returns the events not returned yet, waiting for one if there are
+// none and the call is still running.
+func (c *serverCall) wait() ([]drivers.Progress, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for len(c.events) == 0 && !c.done {
+		changed := c.changed
+		c.lock.Unlock()
+		<-changed
+		c.lock.Lock()
+	}
+
+	events := c.events
+	c.events = nil
+	return events, c.done
+}
@@ -1,10 +1,13 @@
 package rpcdriver
 
 import (
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"net/rpc"
 	"runtime/debug"
+	"sync/atomic"
 
 	"github.com/boot2podman/machine/libmachine/drivers"
 	"github.com/boot2podman/machine/libmachine/log"
@@ -84,6 +87,12 @@ type RPCServerDriver struct {
 	ActualDriver drivers.Driver
 	CloseCh      chan bool
 	HeartbeatCh  chan bool
+	calls        serverCalls
+
+	// server and newDriver serve the drivers of NewDriver
+	server    *rpc.Server
+	newDriver func() drivers.Driver
+	served    uint64
 }
 
 func NewRPCServerDriver(d drivers.Driver) *RPCServerDriver {
@@ -94,6 +103,16 @@ func NewRPCServerDriver(d drivers.Driver) *RPCServerDriver {
 	}
 }
 
+// NewRPCServerDriverPool returns a server driver which also serves a new
+// driver made by newDriver on server for each NewDriver call, so that one
+// plugin serves the machines of its client.
+func NewRPCServerDriverPool(server *rpc.Server, newDriver func() drivers.Driver) *RPCServerDriver {
+	r := NewRPCServerDriver(newDriver())
+	r.server = server
+	r.newDriver = newDriver
+	return r
+}
+
 func (r *RPCServerDriver) Close(_, _ *struct{}) error {
 	r.CloseCh <- true
 	return nil
@@ -142,6 +161,13 @@ func (r *RPCServerDriver) Create(_, _ *struct{}) (err error) {
 	return err
 }
 
+// CreateContext is the Create call of API version 2.
+func (r *RPCServerDriver) CreateContext(args *ContextArgs, _ *struct{}) (err error) {
+	defer trapPanic(&err)
+
+	return r.contextCall(args, drivers.CreateContext)
+}
+
 func (r *RPCServerDriver) DriverName(_ *struct{}, reply *string) error {
 	*reply = r.ActualDriver.DriverName()
 	return nil
@@ -217,11 +243,203 @@ func (r *RPCServerDriver) Start(_ *struct{}, _ *struct{}) error {
 	return r.ActualDriver.Start()
 }
 
+// StartContext is the Start call of API version 2.
+func (r *RPCServerDriver) StartContext(args *ContextArgs, _ *struct{}) (err error) {
+	defer trapPanic(&err)
+
+	return r.contextCall(args, drivers.StartContext)
+}
+
 func (r *RPCServerDriver) Stop(_ *struct{}, _ *struct{}) error {
 	return r.ActualDriver.Stop()
 }
 
+// NewDriver serves a new driver under its own service name, which is
+// returned. It is the only call of API version 3.
+func (r *RPCServerDriver) NewDriver(_ *struct{}, reply *string) error {
+	if r.server == nil {
+		return drivers.ErrNotSupported
+	}
+
+	name := fmt.Sprintf("%s%d", RPCServiceNameV1, atomic.AddUint64(&r.served, 1))
+	served := &RPCServerDriver{
+		ActualDriver: r.newDriver(),
+		CloseCh:      r.CloseCh,
+		HeartbeatCh:  r.HeartbeatCh,
+	}
+	if err := r.server.RegisterName(name, served); err != nil {
+		return err
+	}
+
+	*reply = name
+	return nil
+}
+
 func (r *RPCServerDriver) Heartbeat(_ *struct{}, _ *struct{}) error {
 	r.HeartbeatCh <- true
 	return nil
 }
+
+func (r *RPCServerDriver) SaveSnapshot(name string, _ *struct{}) error {
+	s, ok := r.ActualDriver.(drivers.Snapshotter)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return s.SaveSnapshot(name)
+}
+
+func (r *RPCServerDriver) RestoreSnapshot(name string, _ *struct{}) error {
+	s, ok := r.ActualDriver.(drivers.Snapshotter)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return s.RestoreSnapshot(name)
+}
+
+func (r *RPCServerDriver) ListSnapshots(_ *struct{}, reply *[]drivers.Snapshot) error {
+	s, ok := r.ActualDriver.(drivers.Snapshotter)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	snapshots, err := s.ListSnapshots()
+	*reply = snapshots
+	return err
+}
+
+func (r *RPCServerDriver) RemoveSnapshot(name string, _ *struct{}) error {
+	s, ok := r.ActualDriver.(drivers.Snapshotter)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return s.RemoveSnapshot(name)
+}
+
+func (r *RPCServerDriver) Pause(_ *struct{}, _ *struct{}) error {
+	p, ok := r.ActualDriver.(drivers.Pauser)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return p.Pause()
+}
+
+func (r *RPCServerDriver) Resume(_ *struct{}, _ *struct{}) error {
+	p, ok := r.ActualDriver.(drivers.Pauser)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return p.Resume()
+}
+
+func (r *RPCServerDriver) SaveState(_ *struct{}, _ *struct{}) error {
+	s, ok := r.ActualDriver.(drivers.Saver)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return s.SaveState()
+}
+
+func (r *RPCServerDriver) AddPortForward(f drivers.PortForward, _ *struct{}) error {
+	p, ok := r.ActualDriver.(drivers.PortForwarder)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return p.AddPortForward(f)
+}
+
+func (r *RPCServerDriver) RemovePortForward(f drivers.PortForward, _ *struct{}) error {
+	p, ok := r.ActualDriver.(drivers.PortForwarder)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return p.RemovePortForward(f)
+}
+
+func (r *RPCServerDriver) ListPortForwards(_ *struct{}, reply *[]drivers.PortForward) error {
+	p, ok := r.ActualDriver.(drivers.PortForwarder)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	forwards, err := p.ListPortForwards()
+	*reply = forwards
+	return err
+}
+
+func (r *RPCServerDriver) Reconfigure(resources drivers.Resources, _ *struct{}) error {
+	c, ok := r.ActualDriver.(drivers.Reconfigurer)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return c.Reconfigure(resources)
+}
+
+func (r *RPCServerDriver) CreateClone(source []byte, _ *struct{}) (err error) {
+	defer trapPanic(&err)
+
+	c, ok := r.ActualDriver.(drivers.Cloner)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return c.CreateClone(source)
+}
+
+func (r *RPCServerDriver) ExportFiles(_ *struct{}, reply *map[string]string) error {
+	rel, ok := r.ActualDriver.(drivers.Relocator)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	files, err := rel.ExportFiles()
+	*reply = files
+	return err
+}
+
+func (r *RPCServerDriver) Relocate(_ *struct{}, _ *struct{}) error {
+	rel, ok := r.ActualDriver.(drivers.Relocator)
+	if !ok {
+		return drivers.ErrNotSupported
+	}
+	return rel.Relocate()
+}
+
+// contextCall runs action with the context of the call, which reports
+// progress events to its watcher.
+func (r *RPCServerDriver) contextCall(args *ContextArgs, action func(context.Context, drivers.Driver) error) error {
+	call := r.calls.get(args.ID)
+
+	ctx := call.ctx
+	if args.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.Timeout)
+		defer cancel()
+	}
+	defer func() {
+		r.calls.finish(args.ID, call, ctx.Err() != nil)
+	}()
+
+	return action(drivers.WithProgress(ctx, call.report), r.ActualDriver)
+}
+
+// Cancel cancels the context of a call of API version 2. Calls which
+// already finished are left alone.
+func (r *RPCServerDriver) Cancel(id uint64, _ *struct{}) error {
+	if call, ok := r.calls.lookup(id); ok {
+		call.cancel()
+	}
+	return nil
+}
+
+// WatchProgress returns the progress events of a call of API version 2,
+// waiting for the next ones if there are none yet. Calls which already
+// finished and were watched to the end are reported done.
+func (r *RPCServerDriver) WatchProgress(id uint64, reply *ProgressReply) error {
+	call, ok := r.calls.lookup(id)
+	if !ok {
+		reply.Done = true
+		return nil
+	}
+
+	reply.Events, reply.Done = call.wait()
+	if reply.Done {
+		r.calls.remove(id)
+	}
+	return nil
+}
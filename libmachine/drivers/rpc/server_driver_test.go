@@ -1,10 +1,13 @@
 package rpcdriver
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/drivers"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -73,3 +76,90 @@ func TestRPCServerDriverCreate(t *testing.T) {
 		assert.Equal(t, tc.expectedErr, tc.serverDriver.Create(nil, nil))
 	}
 }
+
+func TestRPCServerDriverSnapshotNotSupported(t *testing.T) {
+	serverDriver := NewRPCServerDriver(&fakedriver.Driver{})
+
+	assert.Equal(t, drivers.ErrNotSupported, serverDriver.SaveSnapshot("snap", nil))
+	assert.Equal(t, drivers.ErrNotSupported, serverDriver.RestoreSnapshot("snap", nil))
+	assert.Equal(t, drivers.ErrNotSupported, serverDriver.ListSnapshots(nil, &[]drivers.Snapshot{}))
+	assert.Equal(t, drivers.ErrNotSupported, serverDriver.RemoveSnapshot("snap", nil))
+}
+
+func TestRPCServerDriverStartContextDeadline(t *testing.T) {
+	d := &contextDriver{Driver: &fakedriver.Driver{}, cancelled: make(chan error, 1)}
+	serverDriver := NewRPCServerDriver(d)
+
+	err := serverDriver.StartContext(&ContextArgs{ID: 1, Timeout: 10 * time.Millisecond}, nil)
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	var reply ProgressReply
+	assert.NoError(t, serverDriver.WatchProgress(1, &reply))
+	assert.True(t, reply.Done)
+	assert.Empty(t, serverDriver.calls.calls)
+}
+
+func TestRPCServerDriverCancelBeforeCall(t *testing.T) {
+	d := &contextDriver{Driver: &fakedriver.Driver{}, cancelled: make(chan error, 1)}
+	serverDriver := NewRPCServerDriver(d)
+
+	assert.NoError(t, serverDriver.Cancel(2, nil))
+	assert.Equal(t, context.Canceled, serverDriver.StartContext(&ContextArgs{ID: 2}, nil))
+}
+
+func TestRPCServerDriverCallsAfterFinish(t *testing.T) {
+	serverDriver := NewRPCServerDriver(&contextDriver{Driver: &fakedriver.Driver{}})
+
+	assert.NoError(t, serverDriver.CreateContext(&ContextArgs{ID: 3}, nil))
+
+	var reply ProgressReply
+	assert.NoError(t, serverDriver.WatchProgress(3, &reply))
+	assert.True(t, reply.Done)
+	assert.Len(t, reply.Events, 2)
+
+	// Late calls neither block nor track the call again
+	assert.NoError(t, serverDriver.Cancel(3, nil))
+	reply = ProgressReply{}
+	assert.NoError(t, serverDriver.WatchProgress(3, &reply))
+	assert.True(t, reply.Done)
+	assert.Empty(t, reply.Events)
+	assert.Empty(t, serverDriver.calls.calls)
+}
+
+func TestRPCServerDriverCancelledCallIsDropped(t *testing.T) {
+	d := &contextDriver{Driver: &fakedriver.Driver{}, cancelled: make(chan error, 1)}
+	serverDriver := NewRPCServerDriver(d)
+
+	go serverDriver.Cancel(4, nil)
+	assert.Equal(t, context.Canceled, serverDriver.StartContext(&ContextArgs{ID: 4}, nil))
+
+	// The client stopped watching the cancelled call
+	assert.NoError(t, serverDriver.Cancel(4, nil))
+	assert.Empty(t, serverDriver.calls.calls)
+}
+
+type panicContextDriver struct {
+	*fakedriver.Driver
+}
+
+func (p *panicContextDriver) CreateContext(ctx context.Context) error {
+	return nil
+}
+
+func (p *panicContextDriver) StartContext(ctx context.Context) error {
+	panic(errors.New("nil map"))
+}
+
+func TestRPCServerDriverStartContextPanic(t *testing.T) {
+	stdStacker = &FakeStacker{trace: []byte("STACK TRACE")}
+	serverDriver := NewRPCServerDriver(&panicContextDriver{Driver: &fakedriver.Driver{}})
+
+	err := serverDriver.StartContext(&ContextArgs{ID: 5}, nil)
+
+	assert.EqualError(t, err, "Panic in the driver: nil map\nSTACK TRACE")
+
+	var reply ProgressReply
+	assert.NoError(t, serverDriver.WatchProgress(5, &reply))
+	assert.True(t, reply.Done)
+}
@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"sync"
 
 	"encoding/json"
@@ -167,6 +168,160 @@ func (d *SerialDriver) Stop() error {
 	return d.Driver.Stop()
 }
 
+// SaveSnapshot saves the current state of the machine under name
+func (d *SerialDriver) SaveSnapshot(name string) error {
+	d.Lock()
+	defer d.Unlock()
+	if s, ok := d.Driver.(Snapshotter); ok {
+		return s.SaveSnapshot(name)
+	}
+	return ErrNotSupported
+}
+
+// RestoreSnapshot reverts the machine to the named snapshot
+func (d *SerialDriver) RestoreSnapshot(name string) error {
+	d.Lock()
+	defer d.Unlock()
+	if s, ok := d.Driver.(Snapshotter); ok {
+		return s.RestoreSnapshot(name)
+	}
+	return ErrNotSupported
+}
+
+// ListSnapshots returns the snapshots of the machine
+func (d *SerialDriver) ListSnapshots() ([]Snapshot, error) {
+	d.Lock()
+	defer d.Unlock()
+	if s, ok := d.Driver.(Snapshotter); ok {
+		return s.ListSnapshots()
+	}
+	return nil, ErrNotSupported
+}
+
+// RemoveSnapshot deletes the named snapshot
+func (d *SerialDriver) RemoveSnapshot(name string) error {
+	d.Lock()
+	defer d.Unlock()
+	if s, ok := d.Driver.(Snapshotter); ok {
+		return s.RemoveSnapshot(name)
+	}
+	return ErrNotSupported
+}
+
+// Pause suspends the execution of a running machine
+func (d *SerialDriver) Pause() error {
+	d.Lock()
+	defer d.Unlock()
+	if p, ok := d.Driver.(Pauser); ok {
+		return p.Pause()
+	}
+	return ErrNotSupported
+}
+
+// Resume continues the execution of a paused machine
+func (d *SerialDriver) Resume() error {
+	d.Lock()
+	defer d.Unlock()
+	if p, ok := d.Driver.(Pauser); ok {
+		return p.Resume()
+	}
+	return ErrNotSupported
+}
+
+// SaveState writes the state of a running machine to disk and stops it
+func (d *SerialDriver) SaveState() error {
+	d.Lock()
+	defer d.Unlock()
+	if s, ok := d.Driver.(Saver); ok {
+		return s.SaveState()
+	}
+	return ErrNotSupported
+}
+
+// AddPortForward adds a forward
+func (d *SerialDriver) AddPortForward(f PortForward) error {
+	d.Lock()
+	defer d.Unlock()
+	if p, ok := d.Driver.(PortForwarder); ok {
+		return p.AddPortForward(f)
+	}
+	return ErrNotSupported
+}
+
+// RemovePortForward removes the forward for the host port of f
+func (d *SerialDriver) RemovePortForward(f PortForward) error {
+	d.Lock()
+	defer d.Unlock()
+	if p, ok := d.Driver.(PortForwarder); ok {
+		return p.RemovePortForward(f)
+	}
+	return ErrNotSupported
+}
+
+// ListPortForwards returns the configured forwards
+func (d *SerialDriver) ListPortForwards() ([]PortForward, error) {
+	d.Lock()
+	defer d.Unlock()
+	if p, ok := d.Driver.(PortForwarder); ok {
+		return p.ListPortForwards()
+	}
+	return nil, ErrNotSupported
+}
+
+// Reconfigure changes the resources of the machine
+func (d *SerialDriver) Reconfigure(r Resources) error {
+	d.Lock()
+	defer d.Unlock()
+	if c, ok := d.Driver.(Reconfigurer); ok {
+		return c.Reconfigure(r)
+	}
+	return ErrNotSupported
+}
+
+// CreateClone creates a host as a clone of another one
+func (d *SerialDriver) CreateClone(source []byte) error {
+	d.Lock()
+	defer d.Unlock()
+	if c, ok := d.Driver.(Cloner); ok {
+		return c.CreateClone(source)
+	}
+	return ErrNotSupported
+}
+
+// ExportFiles returns the files of a host out of its directory
+func (d *SerialDriver) ExportFiles() (map[string]string, error) {
+	d.Lock()
+	defer d.Unlock()
+	if r, ok := d.Driver.(Relocator); ok {
+		return r.ExportFiles()
+	}
+	return nil, ErrNotSupported
+}
+
+// Relocate prepares a host moved to another store
+func (d *SerialDriver) Relocate() error {
+	d.Lock()
+	defer d.Unlock()
+	if r, ok := d.Driver.(Relocator); ok {
+		return r.Relocate()
+	}
+	return ErrNotSupported
+}
+
+// CreateContext creates a host, cancelled with ctx if the driver supports it
+func (d *SerialDriver) CreateContext(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+	return CreateContext(ctx, d.Driver)
+}
+
+// StartContext starts a host, cancelled with ctx if the driver supports it
+func (d *SerialDriver) StartContext(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+	return StartContext(ctx, d.Driver)
+}
+
 func (d *SerialDriver) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.Driver)
 }
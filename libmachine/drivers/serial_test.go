@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"testing"
 
 	"github.com/boot2podman/machine/libmachine/mcnflag"
@@ -294,3 +295,181 @@ func TestSerialDriverStop(t *testing.T) {
 
 	assert.Equal(t, []string{"Lock", "Stop", "Unlock"}, callRecorder.calls)
 }
+
+type MockSnapshotDriver struct {
+	*MockDriver
+}
+
+func (d *MockSnapshotDriver) SaveSnapshot(name string) error {
+	d.calls.record("SaveSnapshot " + name)
+	return nil
+}
+
+func (d *MockSnapshotDriver) RestoreSnapshot(name string) error {
+	d.calls.record("RestoreSnapshot " + name)
+	return nil
+}
+
+func (d *MockSnapshotDriver) ListSnapshots() ([]Snapshot, error) {
+	d.calls.record("ListSnapshots")
+	return []Snapshot{{Name: "snap"}}, nil
+}
+
+func (d *MockSnapshotDriver) RemoveSnapshot(name string) error {
+	d.calls.record("RemoveSnapshot " + name)
+	return nil
+}
+
+func TestSerialDriverSaveSnapshot(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockSnapshotDriver{&MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+	err := driver.(Snapshotter).SaveSnapshot("snap")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lock", "SaveSnapshot snap", "Unlock"}, callRecorder.calls)
+}
+
+func TestSerialDriverListSnapshots(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockSnapshotDriver{&MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+	snapshots, err := driver.(Snapshotter).ListSnapshots()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Snapshot{{Name: "snap"}}, snapshots)
+	assert.Equal(t, []string{"Lock", "ListSnapshots", "Unlock"}, callRecorder.calls)
+}
+
+func TestSerialDriverSnapshotNotSupported(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockDriver{calls: callRecorder}, &MockLocker{calls: callRecorder})
+	err := driver.(Snapshotter).RestoreSnapshot("snap")
+
+	assert.Equal(t, ErrNotSupported, err)
+	assert.Equal(t, []string{"Lock", "Unlock"}, callRecorder.calls)
+}
+
+type MockReconfigureDriver struct {
+	*MockDriver
+}
+
+func (d *MockReconfigureDriver) Reconfigure(r Resources) error {
+	d.calls.record("Reconfigure " + r.String())
+	return nil
+}
+
+func TestSerialDriverReconfigure(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockReconfigureDriver{&MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+	err := driver.(Reconfigurer).Reconfigure(Resources{CPUs: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lock", "Reconfigure cpus=2 memory=0MB disk-size=0MB", "Unlock"}, callRecorder.calls)
+}
+
+type MockContextDriver struct {
+	*MockDriver
+}
+
+func (d *MockContextDriver) CreateContext(ctx context.Context) error {
+	d.calls.record("CreateContext")
+	ReportProgress(ctx, Progress{Phase: "create", Percent: 100, Message: "done"})
+	return nil
+}
+
+func (d *MockContextDriver) StartContext(ctx context.Context) error {
+	d.calls.record("StartContext")
+	return ctx.Err()
+}
+
+func TestSerialDriverCreateContext(t *testing.T) {
+	callRecorder := &CallRecorder{}
+	progress := []Progress{}
+	ctx := WithProgress(context.Background(), func(p Progress) {
+		progress = append(progress, p)
+	})
+
+	driver := newSerialDriverWithLock(&MockContextDriver{&MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+	err := driver.(ContextDriver).CreateContext(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lock", "CreateContext", "Unlock"}, callRecorder.calls)
+	assert.Equal(t, []Progress{{Phase: "create", Percent: 100, Message: "done"}}, progress)
+}
+
+func TestSerialDriverStartContext(t *testing.T) {
+	callRecorder := &CallRecorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	driver := newSerialDriverWithLock(&MockContextDriver{&MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+
+	assert.Equal(t, context.Canceled, driver.(ContextDriver).StartContext(ctx))
+	assert.Equal(t, []string{"Lock", "StartContext", "Unlock"}, callRecorder.calls)
+}
+
+func TestSerialDriverStartContextNotSupported(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockDriver{calls: callRecorder}, &MockLocker{calls: callRecorder})
+	err := driver.(ContextDriver).StartContext(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lock", "Start", "Unlock"}, callRecorder.calls)
+}
+
+type MockClonerDriver struct {
+	*MockDriver
+}
+
+func (d *MockClonerDriver) CreateClone(source []byte) error {
+	d.calls.record("CreateClone " + string(source))
+	return nil
+}
+
+type MockRelocatorDriver struct {
+	*MockDriver
+}
+
+func (d *MockRelocatorDriver) ExportFiles() (map[string]string, error) {
+	d.calls.record("ExportFiles")
+	return nil, nil
+}
+
+func (d *MockRelocatorDriver) Relocate() error {
+	d.calls.record("Relocate")
+	return nil
+}
+
+func TestSerialDriverRelocate(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockRelocatorDriver{&MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+	err := driver.(Relocator).Relocate()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lock", "Relocate", "Unlock"}, callRecorder.calls)
+}
+
+func TestSerialDriverExportFiles(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockRelocatorDriver{&MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+	_, err := driver.(Relocator).ExportFiles()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lock", "ExportFiles", "Unlock"}, callRecorder.calls)
+}
+
+func TestSerialDriverCreateClone(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockClonerDriver{&MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+	err := driver.(Cloner).CreateClone([]byte("{}"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lock", "CreateClone {}", "Unlock"}, callRecorder.calls)
+}
@@ -0,0 +1,62 @@
+package drivers
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/ssh"
+)
+
+// SharedFolder is a directory of the host exported to the machine over
+// virtio-9p, and mounted in the guest from its mount tag.
+type SharedFolder struct {
+	HostPath  string
+	GuestPath string
+	Tag       string
+}
+
+// String returns the folder in the host:guest form accepted by
+// ParseSharedFolder.
+func (f SharedFolder) String() string {
+	return fmt.Sprintf("%s:%s", f.HostPath, f.GuestPath)
+}
+
+// ParseSharedFolder parses a hostdir[:guestdir] specification. The guest
+// directory defaults to the host directory. Host paths may contain colons,
+// as on Windows, so the last one separates the guest directory.
+func ParseSharedFolder(spec string) (SharedFolder, error) {
+	f := SharedFolder{HostPath: spec, GuestPath: spec}
+
+	if i := strings.LastIndex(spec, ":"); i >= 0 && strings.HasPrefix(spec[i+1:], "/") {
+		f.HostPath, f.GuestPath = spec[:i], spec[i+1:]
+	}
+	if f.HostPath == "" {
+		return f, fmt.Errorf("Invalid shared folder %q, expected hostdir[:guestdir]", spec)
+	}
+	if !path.IsAbs(f.GuestPath) {
+		return f, fmt.Errorf("Invalid shared folder %q, the guest directory must be absolute", spec)
+	}
+
+	hostPath, err := filepath.Abs(f.HostPath)
+	if err != nil {
+		return f, err
+	}
+	f.HostPath = hostPath
+	f.GuestPath = path.Clean(f.GuestPath)
+
+	return f, nil
+}
+
+// MountSharedFoldersCommand returns a shell command mounting the folders
+// in the guest. Folders that are already mounted are left alone.
+func MountSharedFoldersCommand(folders []SharedFolder) string {
+	commands := []string{}
+	for _, f := range folders {
+		commands = append(commands, fmt.Sprintf(
+			"sudo mkdir -p %[2]s && { mountpoint -q %[2]s || sudo mount -t 9p -o trans=virtio,version=9p2000.L %[1]s %[2]s; }",
+			f.Tag, ssh.QuoteArg(f.GuestPath)))
+	}
+	return strings.Join(commands, " && ")
+}
@@ -0,0 +1,58 @@
+package drivers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSharedFolder(t *testing.T) {
+	cwd, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		spec     string
+		expected SharedFolder
+	}{
+		{"/home/user/src:/src", SharedFolder{HostPath: "/home/user/src", GuestPath: "/src"}},
+		{"/home/user/src", SharedFolder{HostPath: "/home/user/src", GuestPath: "/home/user/src"}},
+		{"/home/user/src:/src/", SharedFolder{HostPath: "/home/user/src", GuestPath: "/src"}},
+		{"src:/src", SharedFolder{HostPath: filepath.Join(cwd, "src"), GuestPath: "/src"}},
+	}
+
+	for _, test := range tests {
+		f, err := ParseSharedFolder(test.spec)
+
+		assert.NoError(t, err, test.spec)
+		assert.Equal(t, test.expected, f, test.spec)
+	}
+}
+
+func TestParseSharedFolderInvalid(t *testing.T) {
+	for _, spec := range []string{"", ":/src", "src", "src:relative"} {
+		_, err := ParseSharedFolder(spec)
+
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestMountSharedFoldersCommand(t *testing.T) {
+	command := MountSharedFoldersCommand([]SharedFolder{
+		{HostPath: "/home/user/src", GuestPath: "/src", Tag: "share0"},
+		{HostPath: "/data", GuestPath: "/data", Tag: "share1"},
+	})
+
+	assert.Equal(t, "sudo mkdir -p '/src' && { mountpoint -q '/src' || sudo mount -t 9p -o trans=virtio,version=9p2000.L share0 '/src'; } && "+
+		"sudo mkdir -p '/data' && { mountpoint -q '/data' || sudo mount -t 9p -o trans=virtio,version=9p2000.L share1 '/data'; }", command)
+}
+
+func TestMountSharedFoldersCommandQuotes(t *testing.T) {
+	command := MountSharedFoldersCommand([]SharedFolder{
+		{HostPath: "/src", GuestPath: "/it's; reboot", Tag: "share0"},
+	})
+
+	assert.Equal(t, `sudo mkdir -p '/it'\''s; reboot' && { mountpoint -q '/it'\''s; reboot' || sudo mount -t 9p -o trans=virtio,version=9p2000.L share0 '/it'\''s; reboot'; }`, command)
+}
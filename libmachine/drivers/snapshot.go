@@ -0,0 +1,24 @@
+package drivers
+
+// Snapshot describes a saved state of a machine's disk (and possibly its
+// memory) that the machine can later be restored to.
+type Snapshot struct {
+	Name    string
+	Created string
+}
+
+// Snapshotter is implemented by drivers that can save and restore the state
+// of a machine.
+type Snapshotter interface {
+	// SaveSnapshot saves the current state of the machine under name
+	SaveSnapshot(name string) error
+
+	// RestoreSnapshot reverts the machine to the named snapshot
+	RestoreSnapshot(name string) error
+
+	// ListSnapshots returns the snapshots of the machine
+	ListSnapshots() ([]Snapshot, error)
+
+	// RemoveSnapshot deletes the named snapshot
+	RemoveSnapshot(name string) error
+}
@@ -0,0 +1,46 @@
+package host
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/provision"
+)
+
+// DryRunProvision prints what provisioning the machine would do, without
+// changing it. The provisioner is still detected on the machine.
+func (h *Host) DryRunProvision() error {
+	provisioner, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	return h.PrintProvisioning(provisioner)
+}
+
+// PrintProvisioning prints the commands and file uploads provisioner would
+// run on the machine, followed by its provision scripts.
+func (h *Host) PrintProvisioning(provisioner provision.Provisioner) error {
+	commands, err := provision.DryRun(provisioner, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	if err != nil {
+		return err
+	}
+	for _, script := range h.HostOptions.ProvisionScripts {
+		commands = append(commands, "run provision script "+script)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Provisioning %q with %s would run:", h.Name, provisioner)
+	for _, command := range commands {
+		// Commands spanning lines are indented further
+		fmt.Fprintf(&b, "\n  %s", strings.Replace(strings.TrimSpace(command), "\n", "\n    ", -1))
+	}
+
+	// The machines are provisioned concurrently, so their plans are
+	// printed at once
+	log.Info(b.String())
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package host
 
 import (
+	"context"
+	"fmt"
 	"os/exec"
 	"regexp"
 
@@ -26,6 +28,7 @@ type SSHClientCreator interface {
 	CreateSSHClient(d drivers.Driver) (ssh.Client, error)
 	CreateExternalSSHClient(d drivers.Driver) (*ssh.ExternalClient, error)
 	CreateExternalRootSSHClient(d drivers.Driver) (*ssh.ExternalClient, error)
+	CreateNativeSSHClient(d drivers.Driver) (*ssh.NativeClient, error)
 }
 
 type StandardSSHClientCreator struct {
@@ -51,6 +54,16 @@ type Options struct {
 	Disk          int
 	EngineOptions *engine.Options
 	AuthOptions   *auth.Options
+	// DriverOptions records the driver create flags that were set
+	// explicitly, so that the machine can be described again.
+	DriverOptions map[string]interface{} `json:",omitempty"`
+	// ProvisionScripts and StartScripts are local scripts run on the
+	// machine after it is provisioned, and after it is started.
+	ProvisionScripts []string `json:",omitempty"`
+	StartScripts     []string `json:",omitempty"`
+	// DryRun makes create print the provisioning of the machine instead
+	// of running it. It isn't saved.
+	DryRun bool `json:"-"`
 }
 
 type Metadata struct {
@@ -146,6 +159,38 @@ func (creator *StandardSSHClientCreator) CreateExternalRootSSHClient(d drivers.D
 	return ssh.NewExternalClient(sshBinaryPath, "root", addr, port, auth)
 }
 
+func (h *Host) CreateNativeSSHClient() (*ssh.NativeClient, error) {
+	return stdSSHClientCreator.CreateNativeSSHClient(h.Driver)
+}
+
+func (creator *StandardSSHClientCreator) CreateNativeSSHClient(d drivers.Driver) (*ssh.NativeClient, error) {
+	addr, err := d.GetSSHHostname()
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := d.GetSSHPort()
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &ssh.Auth{}
+	if d.GetSSHKeyPath() != "" {
+		auth.Keys = []string{d.GetSSHKeyPath()}
+	}
+
+	config, err := ssh.NewNativeConfig(d.GetSSHUsername(), auth)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting config for native Go SSH: %s", err)
+	}
+
+	return &ssh.NativeClient{
+		Config:   config,
+		Hostname: addr,
+		Port:     port,
+	}, nil
+}
+
 func (h *Host) runActionForState(action func() error, desiredState state.State) error {
 	if drivers.MachineInState(h.Driver, desiredState)() {
 		return mcnerror.ErrHostAlreadyInState{
@@ -171,14 +216,42 @@ func (h *Host) WaitForPodman() error {
 }
 
 func (h *Host) Start() error {
+	return h.StartContext(context.Background())
+}
+
+// StartContext starts the machine, which is interrupted when ctx is done if
+// its driver is a drivers.ContextDriver.
+func (h *Host) StartContext(ctx context.Context) error {
 	log.Infof("Starting %q...", h.Name)
-	if err := h.runActionForState(h.Driver.Start, state.Running); err != nil {
+
+	ctx = drivers.LogProgress(ctx, h.Name)
+	start := func() error {
+		return drivers.StartContext(ctx, h.Driver)
+	}
+	if drivers.MachineInState(h.Driver, state.Paused)() {
+		if p, ok := h.Driver.(drivers.Pauser); ok {
+			start = func() error {
+				if err := p.Resume(); err != drivers.ErrNotSupported {
+					return err
+				}
+				return drivers.StartContext(ctx, h.Driver)
+			}
+		}
+	} else if drivers.MachineInState(h.Driver, state.Saved)() {
+		log.Infof("Restoring %q from its saved state...", h.Name)
+	}
+
+	if err := h.runActionForState(start, state.Running); err != nil {
 		return err
 	}
 
 	log.Infof("Machine %q was started.", h.Name)
 
-	return h.WaitForPodman()
+	if err := h.WaitForPodman(); err != nil {
+		return err
+	}
+
+	return h.runStartScripts()
 }
 
 func (h *Host) Stop() error {
@@ -201,6 +274,97 @@ func (h *Host) Kill() error {
 	return nil
 }
 
+func (h *Host) Pause() error {
+	p, ok := h.Driver.(drivers.Pauser)
+	if !ok {
+		return h.errNotSupported("pause")
+	}
+
+	log.Infof("Pausing %q...", h.Name)
+	if err := h.runActionForState(p.Pause, state.Paused); err != nil {
+		if err == drivers.ErrNotSupported {
+			return h.errNotSupported("pause")
+		}
+		return err
+	}
+
+	log.Infof("Machine %q was paused.", h.Name)
+	return nil
+}
+
+func (h *Host) Resume() error {
+	if drivers.MachineInState(h.Driver, state.Saved)() {
+		return h.Start()
+	}
+
+	p, ok := h.Driver.(drivers.Pauser)
+	if !ok {
+		return h.errNotSupported("resume")
+	}
+
+	log.Infof("Resuming %q...", h.Name)
+	if err := h.runActionForState(p.Resume, state.Running); err != nil {
+		if err == drivers.ErrNotSupported {
+			return h.errNotSupported("resume")
+		}
+		return err
+	}
+
+	log.Infof("Machine %q was resumed.", h.Name)
+	return nil
+}
+
+func (h *Host) Save() error {
+	s, ok := h.Driver.(drivers.Saver)
+	if !ok {
+		return h.errNotSupported("save")
+	}
+
+	log.Infof("Saving %q...", h.Name)
+	if err := h.runActionForState(s.SaveState, state.Saved); err != nil {
+		if err == drivers.ErrNotSupported {
+			return h.errNotSupported("save")
+		}
+		return err
+	}
+
+	log.Infof("Machine %q was saved.", h.Name)
+	return nil
+}
+
+// Reconfigure changes the resources of the machine. When the driver cannot
+// apply them while the machine runs, the machine is restarted if restart is
+// set, otherwise drivers.ErrRequiresStop is returned.
+func (h *Host) Reconfigure(r drivers.Resources, restart bool) error {
+	c, ok := h.Driver.(drivers.Reconfigurer)
+	if !ok {
+		return h.errNotSupported("changing resources")
+	}
+
+	log.Infof("Changing the resources of %q...", h.Name)
+	err := c.Reconfigure(r)
+	if err == drivers.ErrRequiresStop && restart {
+		if err := h.Stop(); err != nil {
+			return err
+		}
+		if err := c.Reconfigure(r); err != nil {
+			return err
+		}
+		return h.Start()
+	}
+	if err == drivers.ErrNotSupported {
+		return h.errNotSupported("changing resources")
+	}
+	return err
+}
+
+func (h *Host) errNotSupported(operation string) error {
+	return mcnerror.ErrOperationNotSupported{
+		DriverName: h.DriverName,
+		Operation:  operation,
+	}
+}
+
 func (h *Host) Restart() error {
 	log.Infof("Restarting %q...", h.Name)
 	if drivers.MachineInState(h.Driver, state.Stopped)() {
@@ -284,5 +448,9 @@ func (h *Host) Provision() error {
 		return err
 	}
 
-	return provisioner.Provision(*h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	if err := provisioner.Provision(*h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+		return err
+	}
+
+	return h.RunProvisionScripts()
 }
@@ -5,6 +5,8 @@ import (
 
 	"github.com/boot2podman/machine/drivers/fakedriver"
 	_ "github.com/boot2podman/machine/drivers/none"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/mcnerror"
 	"github.com/boot2podman/machine/libmachine/provision"
 	"github.com/boot2podman/machine/libmachine/state"
 )
@@ -55,3 +57,188 @@ func TestStart(t *testing.T) {
 		t.Fatalf("Expected no error but got one: %s", err)
 	}
 }
+
+type pausableDriver struct {
+	*fakedriver.Driver
+	resumed bool
+}
+
+func (d *pausableDriver) Pause() error {
+	d.MockState = state.Paused
+	return nil
+}
+
+func (d *pausableDriver) Resume() error {
+	d.resumed = true
+	d.MockState = state.Running
+	return nil
+}
+
+func (d *pausableDriver) SaveState() error {
+	d.MockState = state.Saved
+	return nil
+}
+
+func TestPauseResume(t *testing.T) {
+	driver := &pausableDriver{
+		Driver: &fakedriver.Driver{
+			MockState: state.Running,
+		},
+	}
+	host := &Host{
+		Driver: driver,
+	}
+
+	if err := host.Pause(); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+	if driver.MockState != state.Paused {
+		t.Fatalf("Expected machine to be paused but was %s", driver.MockState)
+	}
+
+	if err := host.Resume(); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+	if driver.MockState != state.Running {
+		t.Fatalf("Expected machine to be running but was %s", driver.MockState)
+	}
+}
+
+func TestStartResumesPausedMachine(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: provision.NewNetstatProvisioner(),
+	})
+
+	driver := &pausableDriver{
+		Driver: &fakedriver.Driver{
+			MockState: state.Paused,
+		},
+	}
+	host := &Host{
+		Driver: driver,
+	}
+
+	if err := host.Start(); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+	if !driver.resumed {
+		t.Fatal("Expected paused machine to be resumed")
+	}
+}
+
+func TestSave(t *testing.T) {
+	driver := &pausableDriver{
+		Driver: &fakedriver.Driver{
+			MockState: state.Running,
+		},
+	}
+	host := &Host{
+		Driver: driver,
+	}
+
+	if err := host.Save(); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+	if driver.MockState != state.Saved {
+		t.Fatalf("Expected machine to be saved but was %s", driver.MockState)
+	}
+}
+
+func TestPauseNotSupported(t *testing.T) {
+	host := &Host{
+		DriverName: "fake",
+		Driver: &fakedriver.Driver{
+			MockState: state.Running,
+		},
+	}
+
+	err := host.Pause()
+
+	if _, ok := err.(mcnerror.ErrOperationNotSupported); !ok {
+		t.Fatalf("Expected ErrOperationNotSupported but got: %v", err)
+	}
+}
+
+type reconfigurableDriver struct {
+	*fakedriver.Driver
+	resources drivers.Resources
+}
+
+func (d *reconfigurableDriver) Reconfigure(r drivers.Resources) error {
+	if d.MockState != state.Stopped {
+		return drivers.ErrRequiresStop
+	}
+	d.resources = r
+	return nil
+}
+
+func TestReconfigure(t *testing.T) {
+	driver := &reconfigurableDriver{
+		Driver: &fakedriver.Driver{
+			MockState: state.Running,
+		},
+	}
+	host := &Host{
+		Driver: driver,
+	}
+	r := drivers.Resources{CPUs: 2, Memory: 4096}
+
+	if err := host.Reconfigure(r, false); err != drivers.ErrRequiresStop {
+		t.Fatalf("Expected ErrRequiresStop but got: %v", err)
+	}
+	if driver.resources != (drivers.Resources{}) {
+		t.Fatalf("Expected no change but got %s", driver.resources)
+	}
+
+	driver.MockState = state.Stopped
+
+	if err := host.Reconfigure(r, false); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+	if driver.resources != r {
+		t.Fatalf("Expected %s but got %s", r, driver.resources)
+	}
+}
+
+func TestReconfigureRestarts(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: provision.NewNetstatProvisioner(),
+	})
+
+	driver := &reconfigurableDriver{
+		Driver: &fakedriver.Driver{
+			MockState: state.Running,
+		},
+	}
+	host := &Host{
+		Driver: driver,
+	}
+	r := drivers.Resources{DiskSize: 40000}
+
+	if err := host.Reconfigure(r, true); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+	if driver.resources != r {
+		t.Fatalf("Expected %s but got %s", r, driver.resources)
+	}
+	if driver.MockState != state.Running {
+		t.Fatalf("Expected machine to be running but was %s", driver.MockState)
+	}
+}
+
+func TestReconfigureNotSupported(t *testing.T) {
+	host := &Host{
+		DriverName: "fake",
+		Driver: &fakedriver.Driver{
+			MockState: state.Stopped,
+		},
+	}
+
+	err := host.Reconfigure(drivers.Resources{CPUs: 2}, false)
+
+	if _, ok := err.(mcnerror.ErrOperationNotSupported); !ok {
+		t.Fatalf("Expected ErrOperationNotSupported but got: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package host
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/ssh"
+)
+
+// uploadScriptCommand writes its standard input to an executable temporary
+// file of the home directory of the machine, and prints its path. Home is
+// used rather than /tmp, which may be mounted noexec.
+const uploadScriptCommand = `f=$(mktemp ~/.podman-machine-script.XXXXXX) && cat > "$f" && chmod +x "$f" && echo "$f"`
+
+// runScriptCommandFmt runs the uploaded script, and removes it, keeping its
+// exit status.
+const runScriptCommandFmt = `f=%s; "$f"; s=$?; rm -f "$f"; exit $s`
+
+// scriptCommandFmt writes a script given on the command line to a temporary
+// file, runs it, and removes it, for the clients which can't pass input.
+const scriptCommandFmt = `f=$(mktemp ~/.podman-machine-script.XXXXXX) && printf '%%s' %s > "$f" && chmod +x "$f" && "$f"; s=$?; rm -f "$f"; exit $s`
+
+// RunProvisionScripts runs the provision scripts of the machine, after it was
+// provisioned.
+func (h *Host) RunProvisionScripts() error {
+	if h.HostOptions == nil {
+		return nil
+	}
+	return h.runScripts("provision", h.HostOptions.ProvisionScripts)
+}
+
+func (h *Host) runStartScripts() error {
+	if h.HostOptions == nil {
+		return nil
+	}
+	return h.runScripts("start", h.HostOptions.StartScripts)
+}
+
+// runScripts runs the local scripts on the machine, in order, and stops at
+// the first failing one.
+func (h *Host) runScripts(kind string, scripts []string) error {
+	for _, script := range scripts {
+		if err := h.runScript(script); err != nil {
+			return fmt.Errorf("Error running %s script %s: %s", kind, script, err)
+		}
+	}
+	return nil
+}
+
+// runScript copies the local script to the machine and runs it there,
+// logging its output as it comes. The script is streamed on the standard
+// input of SSH when the client can, so that its content, which may hold
+// credentials, doesn't show in the process list nor hit the limits of the
+// command line.
+func (h *Host) runScript(script string) error {
+	content, err := ioutil.ReadFile(script)
+	if err != nil {
+		return err
+	}
+
+	client, err := h.CreateSSHClient()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Running %s...", script)
+
+	command, err := scriptCommand(client, content)
+	if err != nil {
+		return err
+	}
+	stdout, stderr, err := client.Start(command)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go logLines(&wg, stdout)
+	go logLines(&wg, stderr)
+	wg.Wait()
+
+	return client.Wait()
+}
+
+// scriptCommand returns the command running the script on the machine. The
+// script is uploaded first when client is an ssh.InputClient, and given on
+// the command line otherwise.
+func scriptCommand(client ssh.Client, content []byte) (string, error) {
+	inputClient, ok := client.(ssh.InputClient)
+	if !ok {
+		return fmt.Sprintf(scriptCommandFmt, ssh.QuoteArg(string(content))), nil
+	}
+
+	output, err := inputClient.OutputWithInput(uploadScriptCommand, bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("Error uploading script: %s: %s", err, output)
+	}
+
+	// The path is the last line, after what the login shell may print
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	path := lines[len(lines)-1]
+
+	return fmt.Sprintf(runScriptCommandFmt, ssh.QuoteArg(path)), nil
+}
+
+func logLines(wg *sync.WaitGroup, r io.ReadCloser) {
+	defer wg.Done()
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		log.Info(scanner.Text())
+	}
+}
@@ -0,0 +1,198 @@
+package host
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/provision"
+	"github.com/boot2podman/machine/libmachine/ssh"
+	"github.com/boot2podman/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptSSHClient answers the commands it starts with fixed output, and
+// fails on the scripts containing "exit 1". It can't pass input, so scripts
+// are given on the command line.
+type scriptSSHClient struct {
+	ssh.Client
+	commands []string
+	err      error
+}
+
+// inputScriptSSHClient uploads the scripts it's given as input, and runs
+// them with the scriptSSHClient.
+type inputScriptSSHClient struct {
+	scriptSSHClient
+	uploaded map[string]string
+}
+
+func (c *inputScriptSSHClient) OutputWithInput(command string, input io.Reader) (string, error) {
+	content, err := ioutil.ReadAll(input)
+	if err != nil {
+		return "", err
+	}
+	path := fmt.Sprintf("/home/user/.podman-machine-script.%d", len(c.uploaded))
+	c.uploaded[path] = string(content)
+	return "Welcome!\n" + path + "\n", nil
+}
+
+func (c *inputScriptSSHClient) Start(command string) (io.ReadCloser, io.ReadCloser, error) {
+	for path, content := range c.uploaded {
+		if strings.HasPrefix(command, "f='"+path+"';") {
+			return c.scriptSSHClient.Start(command + "\n" + content)
+		}
+	}
+	return nil, nil, errors.New("script not uploaded")
+}
+
+func (c *scriptSSHClient) Start(command string) (io.ReadCloser, io.ReadCloser, error) {
+	c.commands = append(c.commands, command)
+	c.err = nil
+	if strings.Contains(command, "exit 1") {
+		c.err = errors.New("exit status 1")
+	}
+	return ioutil.NopCloser(strings.NewReader("out\n")), ioutil.NopCloser(strings.NewReader("err\n")), nil
+}
+
+func (c *scriptSSHClient) Wait() error {
+	return c.err
+}
+
+type scriptSSHClientCreator struct {
+	SSHClientCreator
+	client ssh.Client
+}
+
+func (c *scriptSSHClientCreator) CreateSSHClient(d drivers.Driver) (ssh.Client, error) {
+	return c.client, nil
+}
+
+func writeScripts(t *testing.T, scripts map[string]string) string {
+	dir, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunProvisionScripts(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"login.sh": "#!/bin/sh\npodman login -p secret registry.local\n",
+		"pull.sh":  "#!/bin/sh\npodman pull alpine\n",
+	})
+	defer os.RemoveAll(dir)
+
+	client := &inputScriptSSHClient{uploaded: map[string]string{}}
+	defer SetSSHClientCreator(&StandardSSHClientCreator{})
+	SetSSHClientCreator(&scriptSSHClientCreator{client: client})
+
+	h := &Host{
+		Driver: &fakedriver.Driver{},
+		HostOptions: &Options{
+			ProvisionScripts: []string{filepath.Join(dir, "login.sh"), filepath.Join(dir, "pull.sh")},
+		},
+	}
+
+	assert.NoError(t, h.RunProvisionScripts())
+	assert.Equal(t, map[string]string{
+		"/home/user/.podman-machine-script.0": "#!/bin/sh\npodman login -p secret registry.local\n",
+		"/home/user/.podman-machine-script.1": "#!/bin/sh\npodman pull alpine\n",
+	}, client.uploaded)
+	assert.Len(t, client.commands, 2)
+	assert.True(t, strings.HasPrefix(client.commands[0], `f='/home/user/.podman-machine-script.0'; "$f"; s=$?; rm -f "$f"; exit $s`))
+	assert.Contains(t, client.commands[1], "podman pull alpine")
+	assert.Contains(t, log.History(), "out")
+	assert.Contains(t, log.History(), "err")
+}
+
+func TestRunProvisionScriptsWithoutInput(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"login.sh": "#!/bin/sh\necho 'logging in'\n",
+	})
+	defer os.RemoveAll(dir)
+
+	client := &scriptSSHClient{}
+	defer SetSSHClientCreator(&StandardSSHClientCreator{})
+	SetSSHClientCreator(&scriptSSHClientCreator{client: client})
+
+	h := &Host{
+		Driver: &fakedriver.Driver{},
+		HostOptions: &Options{
+			ProvisionScripts: []string{filepath.Join(dir, "login.sh")},
+		},
+	}
+
+	assert.NoError(t, h.RunProvisionScripts())
+	assert.Len(t, client.commands, 1)
+	assert.Contains(t, client.commands[0], `printf '%s' '#!/bin/sh
+echo '\''logging in'\''
+' > "$f"`)
+}
+
+func TestRunProvisionScriptsFailure(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"fail.sh": "#!/bin/sh\nexit 1\n",
+		"next.sh": "#!/bin/sh\ntrue\n",
+	})
+	defer os.RemoveAll(dir)
+
+	client := &inputScriptSSHClient{uploaded: map[string]string{}}
+	defer SetSSHClientCreator(&StandardSSHClientCreator{})
+	SetSSHClientCreator(&scriptSSHClientCreator{client: client})
+
+	failing := filepath.Join(dir, "fail.sh")
+	h := &Host{
+		Driver: &fakedriver.Driver{},
+		HostOptions: &Options{
+			ProvisionScripts: []string{failing, filepath.Join(dir, "next.sh")},
+		},
+	}
+
+	err := h.RunProvisionScripts()
+
+	assert.EqualError(t, err, "Error running provision script "+failing+": exit status 1")
+	assert.Len(t, client.commands, 1)
+}
+
+func TestStartRunsStartScripts(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"up.sh": "#!/bin/sh\npodman ps\n",
+	})
+	defer os.RemoveAll(dir)
+
+	client := &scriptSSHClient{}
+	defer SetSSHClientCreator(&StandardSSHClientCreator{})
+	SetSSHClientCreator(&scriptSSHClientCreator{client: client})
+
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: provision.NewNetstatProvisioner(),
+	})
+
+	h := &Host{
+		Driver: &fakedriver.Driver{
+			MockState: state.Stopped,
+		},
+		HostOptions: &Options{
+			StartScripts: []string{filepath.Join(dir, "up.sh")},
+		},
+	}
+
+	assert.NoError(t, h.Start())
+	assert.Len(t, client.commands, 1)
+	assert.Contains(t, client.commands[0], "podman ps")
+}
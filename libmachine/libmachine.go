@@ -1,7 +1,10 @@
 package libmachine
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"io"
@@ -28,29 +31,55 @@ type API interface {
 	io.Closer
 	NewHost(driverName string, rawDriver []byte) (*host.Host, error)
 	Create(h *host.Host) error
+	CreateContext(ctx context.Context, h *host.Host) error
+	Clone(src *host.Host, name string) (*host.Host, error)
+	Export(h *host.Host, w io.Writer) error
+	Import(r io.Reader, name string) (*host.Host, error)
 	persist.Store
 	GetMachinesDir() string
 }
 
 type Client struct {
 	certsDir       string
+	storePath      string
 	IsDebug        bool
 	SSHClientType  ssh.ClientType
 	GithubAPIToken string
-	*persist.Filestore
+	persist.Store
 	clientDriverFactory rpcdriver.RPCClientDriverFactory
+
+	locking     bool
+	lockCommand string
+	lockWait    bool
+	locks       map[string]*persist.Lock
 }
 
 func NewClient(storePath, certsDir string) *Client {
+	return NewClientWithStore(storePath, certsDir, persist.NewFilestore(storePath, certsDir, certsDir))
+}
+
+// NewClientWithStore returns a client keeping the configs of the machines
+// in store. Their files are in the machines directory of storePath.
+func NewClientWithStore(storePath, certsDir string, store persist.Store) *Client {
 	return &Client{
 		certsDir:            certsDir,
+		storePath:           storePath,
 		IsDebug:             false,
 		SSHClientType:       ssh.External,
-		Filestore:           persist.NewFilestore(storePath, certsDir, certsDir),
+		Store:               store,
 		clientDriverFactory: rpcdriver.NewRPCClientDriverFactory(),
 	}
 }
 
+// StorePath returns the directory of the store.
+func (api *Client) StorePath() string {
+	return api.storePath
+}
+
+func (api *Client) GetMachinesDir() string {
+	return filepath.Join(api.storePath, "machines")
+}
+
 func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, error) {
 	driver, err := api.clientDriverFactory.NewRPCClientDriver(driverName, rawDriver)
 	if err != nil {
@@ -81,14 +110,63 @@ func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, err
 	}, nil
 }
 
-func (api *Client) Load(name string) (*host.Host, error) {
-	h, err := api.Filestore.Load(name)
+// EnableLocking makes the client take the lock of the machines it loads or
+// creates, until it is closed, so that other processes don't change them
+// meanwhile. command describes the holder of the locks. With wait, the
+// client waits for the locks held by other processes instead of failing.
+func (api *Client) EnableLocking(command string, wait bool) {
+	api.locking = true
+	api.lockCommand = command
+	api.lockWait = wait
+}
+
+// LockStore takes the lock of the store until the client is closed.
+func (api *Client) LockStore() error {
+	if _, ok := api.locks[""]; ok {
+		return nil
+	}
+
+	lock, err := persist.LockStore(api.storePath, api.lockCommand, api.lockWait)
+	if err != nil {
+		return err
+	}
+	api.addLock("", lock)
+	return nil
+}
+
+// lockMachine takes the lock of the machine name until the client is
+// closed, if locking is enabled.
+func (api *Client) lockMachine(name string) error {
+	if _, ok := api.locks[name]; ok || !api.locking {
+		return nil
+	}
+
+	lock, err := persist.LockMachine(api.storePath, name, api.lockCommand, api.lockWait)
 	if err != nil {
+		return err
+	}
+	api.addLock(name, lock)
+	return nil
+}
+
+func (api *Client) addLock(name string, lock *persist.Lock) {
+	if api.locks == nil {
+		api.locks = map[string]*persist.Lock{}
+	}
+	api.locks[name] = lock
+}
+
+func (api *Client) Load(name string) (*host.Host, error) {
+	if err := api.lockMachine(name); err != nil {
 		return nil, err
 	}
 
-	d, err := api.clientDriverFactory.NewRPCClientDriver(h.DriverName, h.RawDriver)
+	h, err := api.Store.Load(name)
 	if err != nil {
+		return nil, err
+	}
+
+	if _, err := localbinary.BinaryPath(h.DriverName); err != nil {
 		// Not being able to find a driver binary is a "known error"
 		if _, ok := err.(localbinary.ErrPluginBinaryNotFound); ok {
 			h.Driver = errdriver.NewDriver(h.DriverName)
@@ -97,6 +175,9 @@ func (api *Client) Load(name string) (*host.Host, error) {
 		return nil, err
 	}
 
+	// The plugin is only started when the machine is actually used.
+	d := rpcdriver.NewLazyRPCClientDriver(api.clientDriverFactory, h.DriverName, h.RawDriver)
+
 	if h.DriverName == "virtualbox" {
 		h.Driver = drivers.NewSerialDriver(d)
 	} else {
@@ -109,6 +190,16 @@ func (api *Client) Load(name string) (*host.Host, error) {
 // Create is the wrapper method which covers all of the boilerplate around
 // actually creating, provisioning, and persisting an instance in the store.
 func (api *Client) Create(h *host.Host) error {
+	return api.CreateContext(context.Background(), h)
+}
+
+// CreateContext creates the machine like Create, and interrupts its driver
+// when ctx is done if it is a drivers.ContextDriver.
+func (api *Client) CreateContext(ctx context.Context, h *host.Host) error {
+	if err := api.lockMachine(h.Name); err != nil {
+		return err
+	}
+
 	if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
 		return fmt.Errorf("Error generating certificates: %s", err)
 	}
@@ -127,7 +218,10 @@ func (api *Client) Create(h *host.Host) error {
 
 	log.Info("Creating machine...")
 
-	if err := api.performCreate(h); err != nil {
+	create := func() error {
+		return drivers.CreateContext(drivers.LogProgress(ctx, h.Name), h.Driver)
+	}
+	if err := api.performCreate(h, create); err != nil {
 		return fmt.Errorf("Error creating machine: %s", err)
 	}
 
@@ -136,8 +230,186 @@ func (api *Client) Create(h *host.Host) error {
 	return nil
 }
 
-func (api *Client) performCreate(h *host.Host) error {
-	if err := h.Driver.Create(); err != nil {
+// Clone creates the machine name as a clone of the stopped machine src,
+// sharing its disk where the driver allows. The clone gets its own SSH key,
+// certificates and hostname, and is persisted in the store.
+func (api *Client) Clone(src *host.Host, name string) (*host.Host, error) {
+	if err := api.lockMachine(name); err != nil {
+		return nil, err
+	}
+
+	if exists, err := api.Exists(name); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, mcnerror.ErrHostAlreadyExists{Name: name}
+	}
+
+	s, err := src.Driver.GetState()
+	if err != nil {
+		return nil, err
+	}
+	if s != state.Stopped {
+		return nil, fmt.Errorf("Machine %q must be stopped to be cloned", src.Name)
+	}
+
+	source, err := json.Marshal(src.Driver)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the driver config of %q: %s", src.Name, err)
+	}
+	rawDriver, err := drivers.CloneConfig(source, name)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the driver config of %q: %s", src.Name, err)
+	}
+
+	h, err := api.NewHost(src.DriverName, rawDriver)
+	if err != nil {
+		return nil, err
+	}
+	cloner, ok := h.Driver.(drivers.Cloner)
+	if !ok {
+		return nil, mcnerror.ErrOperationNotSupported{DriverName: src.DriverName, Operation: "clone"}
+	}
+
+	if src.HostOptions != nil {
+		authOptions := *src.HostOptions.AuthOptions
+		authOptions.ServerCertPath = filepath.Join(api.GetMachinesDir(), name, "server.pem")
+		authOptions.ServerKeyPath = filepath.Join(api.GetMachinesDir(), name, "server-key.pem")
+		authOptions.StorePath = filepath.Join(api.GetMachinesDir(), name)
+		engineOptions := *src.HostOptions.EngineOptions
+		h.HostOptions = &host.Options{
+			Driver:        src.HostOptions.Driver,
+			Memory:        src.HostOptions.Memory,
+			Disk:          src.HostOptions.Disk,
+			AuthOptions:   &authOptions,
+			EngineOptions: &engineOptions,
+			DriverOptions: src.HostOptions.DriverOptions,
+		}
+	}
+
+	if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
+		return nil, fmt.Errorf("Error generating certificates: %s", err)
+	}
+
+	if err := api.Save(h); err != nil {
+		return nil, fmt.Errorf("Error saving host to store before attempting to clone: %s", err)
+	}
+
+	log.Infof("Cloning %q...", src.Name)
+
+	notSupported := false
+	create := func() error {
+		if err := cloner.CreateClone(source); err != nil {
+			notSupported = err == drivers.ErrNotSupported
+			return err
+		}
+
+		log.Info("Replacing the SSH key of the source machine...")
+		if err := drivers.WaitForSSH(h.Driver); err != nil {
+			return err
+		}
+		return drivers.ReplaceSSHKey(h.Driver)
+	}
+	if err := api.performCreate(h, create); err != nil {
+		if notSupported {
+			// Nothing was created yet
+			api.Remove(name)
+			return nil, mcnerror.ErrOperationNotSupported{DriverName: src.DriverName, Operation: "clone"}
+		}
+		return nil, fmt.Errorf("Error cloning machine: %s", err)
+	}
+
+	return h, nil
+}
+
+// Export writes the stopped machine h to w, as an archive to be imported
+// in another store.
+func (api *Client) Export(h *host.Host, w io.Writer) error {
+	// Machines which can't be imported aren't exported either
+	relocator, ok := h.Driver.(drivers.Relocator)
+	if !ok {
+		return mcnerror.ErrOperationNotSupported{DriverName: h.DriverName, Operation: "export"}
+	}
+
+	s, err := h.Driver.GetState()
+	if err != nil {
+		return err
+	}
+	if s != state.Stopped {
+		return fmt.Errorf("Machine %q must be stopped to be exported", h.Name)
+	}
+
+	files, err := relocator.ExportFiles()
+	if err == drivers.ErrNotSupported {
+		return mcnerror.ErrOperationNotSupported{DriverName: h.DriverName, Operation: "export"}
+	}
+	if err != nil {
+		return fmt.Errorf("Error exporting machine: %s", err)
+	}
+
+	return persist.Export(api.Store, api.storePath, h.Name, w, files)
+}
+
+// Import adds the machine of an archive written by Export to the store, as
+// name, or under its name in the archive if name is empty. The driver
+// prepares the machine for this store, e.g. with new host ports, and its
+// server certificate is issued again by the CA of this store.
+func (api *Client) Import(r io.Reader, name string) (*host.Host, error) {
+	if name != "" {
+		if err := api.lockMachine(name); err != nil {
+			return nil, err
+		}
+	}
+
+	name, err := persist.Import(api.Store, api.storePath, r, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.lockMachine(name); err != nil {
+		return nil, err
+	}
+
+	h, err := api.Load(name)
+	if err != nil {
+		api.Remove(name)
+		return nil, err
+	}
+
+	relocator, ok := h.Driver.(drivers.Relocator)
+	if !ok {
+		api.Remove(name)
+		return nil, mcnerror.ErrOperationNotSupported{DriverName: h.DriverName, Operation: "import"}
+	}
+	if err := relocator.Relocate(); err != nil {
+		api.Remove(name)
+		if err == drivers.ErrNotSupported {
+			return nil, mcnerror.ErrOperationNotSupported{DriverName: h.DriverName, Operation: "import"}
+		}
+		return nil, fmt.Errorf("Error importing machine: %s", err)
+	}
+
+	if authOptions := h.AuthOptions(); authOptions != nil {
+		_, err := os.Stat(authOptions.ServerCertPath)
+		provisioned := err == nil
+		if err := cert.ReissueServerCert(authOptions); err != nil {
+			api.Remove(name)
+			return nil, fmt.Errorf("Error generating certificates: %s", err)
+		}
+		if provisioned {
+			log.Infof("The certificates of %q were issued again, run regenerate-certs once it is started to install them in the machine", h.Name)
+		}
+	}
+
+	if err := api.Save(h); err != nil {
+		return nil, fmt.Errorf("Error saving imported machine: %s", err)
+	}
+
+	return h, nil
+}
+
+// performCreate runs create, which creates and starts the machine in the
+// driver, and provisions the machine.
+func (api *Client) performCreate(h *host.Host, create func() error) error {
+	if err := create(); err != nil {
 		return fmt.Errorf("Error in driver during machine creation: %s", err)
 	}
 
@@ -161,6 +433,10 @@ func (api *Client) performCreate(h *host.Host) error {
 		return fmt.Errorf("Error detecting OS: %s", err)
 	}
 
+	if h.HostOptions.DryRun {
+		return h.PrintProvisioning(provisioner)
+	}
+
 	log.Infof("Provisioning with %s...", provisioner.String())
 	if err := provisioner.Provision(*h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
 		return fmt.Errorf("Error running provisioning: %s", err)
@@ -179,9 +455,19 @@ func (api *Client) performCreate(h *host.Host) error {
 	log.Debugf("%s", version)
 
 	log.Info("Podman is up and running!")
-	return nil
+
+	return h.RunProvisionScripts()
 }
 
 func (api *Client) Close() error {
-	return api.clientDriverFactory.Close()
+	err := api.clientDriverFactory.Close()
+
+	for name, lock := range api.locks {
+		if unlockErr := lock.Unlock(); unlockErr != nil {
+			log.Debugf("Error releasing the lock of %q: %s", name, unlockErr)
+		}
+	}
+	api.locks = nil
+
+	return err
 }
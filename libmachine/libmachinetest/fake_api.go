@@ -1,6 +1,10 @@
 package libmachinetest
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
+
 	"github.com/boot2podman/machine/libmachine"
 	"github.com/boot2podman/machine/libmachine/drivers"
 	"github.com/boot2podman/machine/libmachine/host"
@@ -9,7 +13,8 @@ import (
 )
 
 type FakeAPI struct {
-	Hosts []*host.Host
+	Hosts       []*host.Host
+	MachinesDir string
 }
 
 func (api *FakeAPI) NewPluginDriver(string, []byte) (drivers.Driver, error) {
@@ -28,6 +33,43 @@ func (api *FakeAPI) Create(h *host.Host) error {
 	return nil
 }
 
+func (api *FakeAPI) CreateContext(ctx context.Context, h *host.Host) error {
+	return nil
+}
+
+func (api *FakeAPI) Clone(src *host.Host, name string) (*host.Host, error) {
+	h := &host.Host{
+		Name:        name,
+		DriverName:  src.DriverName,
+		Driver:      src.Driver,
+		HostOptions: src.HostOptions,
+	}
+	api.Hosts = append(api.Hosts, h)
+	return h, nil
+}
+
+// Export writes the name of the machine h to w
+func (api *FakeAPI) Export(h *host.Host, w io.Writer) error {
+	_, err := io.WriteString(w, h.Name)
+	return err
+}
+
+// Import adds a machine named name, or named after the content of r
+func (api *FakeAPI) Import(r io.Reader, name string) (*host.Host, error) {
+	if name == "" {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		name = string(data)
+	}
+	h := &host.Host{
+		Name: name,
+	}
+	api.Hosts = append(api.Hosts, h)
+	return h, nil
+}
+
 func (api *FakeAPI) Exists(name string) (bool, error) {
 	for _, host := range api.Hosts {
 		if name == host.Name {
@@ -39,7 +81,11 @@ func (api *FakeAPI) Exists(name string) (bool, error) {
 }
 
 func (api *FakeAPI) List() ([]string, error) {
-	return []string{}, nil
+	names := []string{}
+	for _, host := range api.Hosts {
+		names = append(names, host.Name)
+	}
+	return names, nil
 }
 
 func (api *FakeAPI) Load(name string) (*host.Host, error) {
@@ -73,7 +119,7 @@ func (api *FakeAPI) Save(host *host.Host) error {
 }
 
 func (api FakeAPI) GetMachinesDir() string {
-	return ""
+	return api.MachinesDir
 }
 
 func State(api libmachine.API, name string) state.State {
@@ -0,0 +1,340 @@
+// Package machinefile reads and writes declarative machine descriptions, to
+// be given to `create -f` instead of, or in addition to, command line flags:
+//
+//	name: box
+//	driver: qemu
+//	driver-options:
+//	  qemu-memory: 2048
+//	engine:
+//	  labels: [env=dev]
+//	  insecure-registries:
+//	    - registry.local:5000
+//	tls-san: [box.local]
+//	provision-scripts: [login.sh]
+//
+// The driver options are the create flags of the driver, without the
+// leading dashes. Relative script paths are relative to the directory of the
+// machine file.
+package machinefile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/boot2podman/machine/libmachine/mcnflag"
+)
+
+// MachineFile is the description of a machine.
+type MachineFile struct {
+	Name          string
+	Driver        string
+	DriverOptions map[string]interface{}
+	Engine        Engine
+	TLSSANs       []string
+
+	ProvisionScripts []string
+	StartScripts     []string
+}
+
+// Engine holds the engine options of a machine.
+type Engine struct {
+	Options            []string
+	Env                []string
+	InsecureRegistries []string
+	Labels             []string
+	RegistryMirrors    []string
+	StorageDriver      string
+	InstallURL         string
+}
+
+// Load reads the machine file at path.
+func Load(path string) (*MachineFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading machine file %s: %s", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, scripts := range [][]string{m.ProvisionScripts, m.StartScripts} {
+		for i, script := range scripts {
+			if !filepath.IsAbs(script) {
+				scripts[i] = filepath.Join(dir, script)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// Parse parses the content of a machine file.
+func Parse(data []byte) (*MachineFile, error) {
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MachineFile{
+		DriverOptions: map[string]interface{}{},
+	}
+
+	for key, value := range doc {
+		switch key {
+		case "name":
+			m.Name, err = stringValue(key, value)
+		case "driver":
+			m.Driver, err = stringValue(key, value)
+		case "driver-options":
+			m.DriverOptions, err = mapValue(key, value)
+		case "engine":
+			err = m.parseEngine(value)
+		case "tls-san":
+			m.TLSSANs, err = listValue(key, value)
+		case "provision-scripts":
+			m.ProvisionScripts, err = listValue(key, value)
+		case "start-scripts":
+			m.StartScripts, err = listValue(key, value)
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *MachineFile) parseEngine(value interface{}) error {
+	engine, err := mapValue("engine", value)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range engine {
+		name := "engine." + key
+		switch key {
+		case "options":
+			m.Engine.Options, err = listValue(name, value)
+		case "env":
+			m.Engine.Env, err = listValue(name, value)
+		case "insecure-registries":
+			m.Engine.InsecureRegistries, err = listValue(name, value)
+		case "labels":
+			m.Engine.Labels, err = listValue(name, value)
+		case "registry-mirrors":
+			m.Engine.RegistryMirrors, err = listValue(name, value)
+		case "storage-driver":
+			m.Engine.StorageDriver, err = stringValue(name, value)
+		case "install-url":
+			m.Engine.InstallURL, err = stringValue(name, value)
+		default:
+			err = fmt.Errorf("unknown key %q", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func stringValue(key string, value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", key)
+	}
+	return s, nil
+}
+
+func listValue(key string, value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []string:
+		return v, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return []string{v}, nil
+	}
+	return nil, fmt.Errorf("%s must be a list", key)
+}
+
+func mapValue(key string, value interface{}) (map[string]interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, nil
+	case string:
+		if v == "" {
+			return map[string]interface{}{}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s must be a mapping", key)
+}
+
+// Flags returns the values of the create flags set by the file, keyed by
+// flag name. Driver options are checked against the create flags of the
+// driver, and converted to the type of their flag.
+func (m *MachineFile) Flags(driverFlags []mcnflag.Flag) (map[string]interface{}, error) {
+	flags := map[string]interface{}{}
+
+	setList := func(name string, values []string) {
+		if len(values) != 0 {
+			flags[name] = values
+		}
+	}
+	setString := func(name, value string) {
+		if value != "" {
+			flags[name] = value
+		}
+	}
+
+	setString("driver", m.Driver)
+	setList("engine-opt", m.Engine.Options)
+	setList("engine-env", m.Engine.Env)
+	setList("engine-insecure-registry", m.Engine.InsecureRegistries)
+	setList("engine-label", m.Engine.Labels)
+	setList("engine-registry-mirror", m.Engine.RegistryMirrors)
+	setString("engine-storage-driver", m.Engine.StorageDriver)
+	setString("engine-install-url", m.Engine.InstallURL)
+	setList("tls-san", m.TLSSANs)
+	setList("provision-script", m.ProvisionScripts)
+	setList("start-script", m.StartScripts)
+
+	known := map[string]mcnflag.Flag{}
+	for _, f := range driverFlags {
+		known[f.String()] = f
+	}
+
+	for name, value := range m.DriverOptions {
+		f, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown option %q for driver %q", name, m.Driver)
+		}
+
+		typed, err := convertValue(f, value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for option %q: %s", name, err)
+		}
+		flags[name] = typed
+	}
+
+	return flags, nil
+}
+
+func convertValue(f mcnflag.Flag, value interface{}) (interface{}, error) {
+	if _, ok := f.(*mcnflag.StringSliceFlag); ok {
+		return listValue(f.String(), value)
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a single value")
+	}
+
+	switch f.(type) {
+	case *mcnflag.IntFlag:
+		return strconv.Atoi(s)
+	case *mcnflag.BoolFlag:
+		return strconv.ParseBool(s)
+	case *mcnflag.StringFlag:
+		return s, nil
+	}
+	return nil, fmt.Errorf("unsupported flag type %T", f)
+}
+
+// Marshal returns the machine file in the format read by Parse.
+func (m *MachineFile) Marshal() []byte {
+	var b bytes.Buffer
+
+	if m.Name != "" {
+		fmt.Fprintf(&b, "name: %s\n", formatScalar(m.Name))
+	}
+	fmt.Fprintf(&b, "driver: %s\n", formatScalar(m.Driver))
+
+	if len(m.DriverOptions) != 0 {
+		b.WriteString("driver-options:\n")
+		for _, name := range sortedKeys(m.DriverOptions) {
+			writeValue(&b, "  ", name, m.DriverOptions[name])
+		}
+	}
+
+	engine := map[string]interface{}{
+		"options":             m.Engine.Options,
+		"env":                 m.Engine.Env,
+		"insecure-registries": m.Engine.InsecureRegistries,
+		"labels":              m.Engine.Labels,
+		"registry-mirrors":    m.Engine.RegistryMirrors,
+		"storage-driver":      m.Engine.StorageDriver,
+		"install-url":         m.Engine.InstallURL,
+	}
+	var engineLines bytes.Buffer
+	for _, key := range sortedKeys(engine) {
+		writeValue(&engineLines, "  ", key, engine[key])
+	}
+	if engineLines.Len() != 0 {
+		b.WriteString("engine:\n")
+		b.Write(engineLines.Bytes())
+	}
+
+	writeValue(&b, "", "tls-san", m.TLSSANs)
+	writeValue(&b, "", "provision-scripts", m.ProvisionScripts)
+	writeValue(&b, "", "start-scripts", m.StartScripts)
+
+	return b.Bytes()
+}
+
+// writeValue writes key with a scalar or list value, unless it is empty.
+// Values read back from JSON may be float64 or []interface{}.
+func writeValue(b *bytes.Buffer, indent, key string, value interface{}) {
+	switch v := value.(type) {
+	case []interface{}:
+		list := []string{}
+		for _, item := range v {
+			list = append(list, valueString(item))
+		}
+		writeValue(b, indent, key, list)
+	case []string:
+		if len(v) == 0 {
+			return
+		}
+		fmt.Fprintf(b, "%s%s:\n", indent, key)
+		for _, item := range v {
+			fmt.Fprintf(b, "%s  - %s\n", indent, formatScalar(item))
+		}
+	case string:
+		if v == "" {
+			return
+		}
+		fmt.Fprintf(b, "%s%s: %s\n", indent, key, formatScalar(v))
+	default:
+		fmt.Fprintf(b, "%s%s: %s\n", indent, key, formatScalar(valueString(v)))
+	}
+}
+
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,180 @@
+package machinefile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/mcnflag"
+	"github.com/stretchr/testify/assert"
+)
+
+var testDriverFlags = []mcnflag.Flag{
+	mcnflag.IntFlag{Name: "qemu-memory", Value: 1024},
+	mcnflag.BoolFlag{Name: "qemu-display"},
+	mcnflag.StringFlag{Name: "qemu-network", Value: "user"},
+	mcnflag.StringSliceFlag{Name: "qemu-share-folder"},
+}
+
+const testMachineFile = `name: box
+driver: qemu
+driver-options:
+  qemu-memory: 2048
+  qemu-display: true
+  qemu-network: user
+  qemu-share-folder:
+    - /home:/hosthome
+engine:
+  env: [HTTP_PROXY=http://proxy:3128]
+  insecure-registries:
+    - registry.local:5000
+  labels:
+    - env=dev
+    - team=web
+tls-san: [box.local]
+provision-scripts:
+  - /opt/login.sh
+`
+
+func TestParse(t *testing.T) {
+	m, err := Parse([]byte(testMachineFile))
+
+	assert.NoError(t, err)
+	assert.Equal(t, &MachineFile{
+		Name:   "box",
+		Driver: "qemu",
+		DriverOptions: map[string]interface{}{
+			"qemu-memory":       "2048",
+			"qemu-display":      "true",
+			"qemu-network":      "user",
+			"qemu-share-folder": []string{"/home:/hosthome"},
+		},
+		Engine: Engine{
+			Env:                []string{"HTTP_PROXY=http://proxy:3128"},
+			InsecureRegistries: []string{"registry.local:5000"},
+			Labels:             []string{"env=dev", "team=web"},
+		},
+		TLSSANs:          []string{"box.local"},
+		ProvisionScripts: []string{"/opt/login.sh"},
+	}, m)
+}
+
+func TestLoadRelativeScripts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machinefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "box.yaml")
+	if err := ioutil.WriteFile(path, []byte("driver: qemu\nprovision-scripts: [login.sh, /opt/pull.sh]\nstart-scripts: [scripts/up.sh]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Load(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "login.sh"), "/opt/pull.sh"}, m.ProvisionScripts)
+	assert.Equal(t, []string{filepath.Join(dir, "scripts", "up.sh")}, m.StartScripts)
+}
+
+func TestParseUnknownKey(t *testing.T) {
+	_, err := Parse([]byte("driver: qemu\nengine:\n  lables: [a=b]\n"))
+
+	assert.EqualError(t, err, `unknown key "engine.lables"`)
+}
+
+func TestFlags(t *testing.T) {
+	m, err := Parse([]byte(testMachineFile))
+	assert.NoError(t, err)
+
+	flags, err := m.Flags(toPointers(testDriverFlags))
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"driver":                   "qemu",
+		"engine-env":               []string{"HTTP_PROXY=http://proxy:3128"},
+		"engine-insecure-registry": []string{"registry.local:5000"},
+		"engine-label":             []string{"env=dev", "team=web"},
+		"tls-san":                  []string{"box.local"},
+		"provision-script":         []string{"/opt/login.sh"},
+		"qemu-memory":              2048,
+		"qemu-display":             true,
+		"qemu-network":             "user",
+		"qemu-share-folder":        []string{"/home:/hosthome"},
+	}, flags)
+}
+
+func TestFlagsValidation(t *testing.T) {
+	var tests = []struct {
+		options string
+		err     string
+	}{
+		{"qemu-memroy: 2048", `Unknown option "qemu-memroy" for driver "qemu"`},
+		{"qemu-memory: lots", `Invalid value for option "qemu-memory": strconv.Atoi: parsing "lots": invalid syntax`},
+		{"qemu-display: maybe", `Invalid value for option "qemu-display": strconv.ParseBool: parsing "maybe": invalid syntax`},
+		{"qemu-network: [user, tap]", `Invalid value for option "qemu-network": expected a single value`},
+	}
+
+	for _, test := range tests {
+		m, err := Parse([]byte("driver: qemu\ndriver-options:\n  " + test.options + "\n"))
+		assert.NoError(t, err)
+
+		_, err = m.Flags(toPointers(testDriverFlags))
+
+		assert.EqualError(t, err, test.err)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	m, err := Parse([]byte(testMachineFile))
+	assert.NoError(t, err)
+
+	again, err := Parse(m.Marshal())
+
+	assert.NoError(t, err)
+	assert.Equal(t, m, again)
+}
+
+func TestMarshalJSONValues(t *testing.T) {
+	m := &MachineFile{
+		Driver: "qemu",
+		DriverOptions: map[string]interface{}{
+			"qemu-memory":       float64(2048),
+			"qemu-display":      true,
+			"qemu-share-folder": []interface{}{"/home:/hosthome"},
+		},
+		Engine: Engine{
+			StorageDriver: "overlay",
+		},
+	}
+
+	assert.Equal(t, `driver: qemu
+driver-options:
+  qemu-display: true
+  qemu-memory: 2048
+  qemu-share-folder:
+    - "/home:/hosthome"
+engine:
+  storage-driver: overlay
+`, string(m.Marshal()))
+}
+
+// toPointers returns the flags as the RPC driver returns them.
+func toPointers(flags []mcnflag.Flag) []mcnflag.Flag {
+	pointers := []mcnflag.Flag{}
+	for _, f := range flags {
+		switch f := f.(type) {
+		case mcnflag.IntFlag:
+			pointers = append(pointers, &f)
+		case mcnflag.BoolFlag:
+			pointers = append(pointers, &f)
+		case mcnflag.StringFlag:
+			pointers = append(pointers, &f)
+		case mcnflag.StringSliceFlag:
+			pointers = append(pointers, &f)
+		}
+	}
+	return pointers
+}
@@ -0,0 +1,267 @@
+package machinefile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The machine files only need a small subset of YAML: block mappings,
+// sequences of scalars (block or flow style), plain and quoted scalars, and
+// comments. Scalars are kept as strings, and typed against the create flags
+// they are given for.
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func parseYAML(data string) (map[string]interface{}, error) {
+	lines, err := splitYAMLLines(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &yamlParser{lines: lines}
+	m, err := p.parseMap(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+
+	return m, nil
+}
+
+func splitYAMLLines(data string) ([]yamlLine, error) {
+	lines := []yamlLine{}
+
+	for i, raw := range strings.Split(data, "\n") {
+		text := strings.TrimRight(stripComment(raw), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot be used for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{
+			num:    i + 1,
+			indent: len(text) - len(trimmed),
+			text:   trimmed,
+		})
+	}
+
+	return lines, nil
+}
+
+// stripComment removes a comment, which starts with a # at the beginning of
+// the line or after a space, outside of quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseMap(indent int) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		if isListItem(line.text) {
+			return nil, fmt.Errorf("line %d: unexpected list item", line.num)
+		}
+
+		key, rest, ok := splitKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.num)
+		}
+		if _, exists := m[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			value, err := parseInlineValue(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line.num, err)
+			}
+			m[key] = value
+			continue
+		}
+
+		m[key] = ""
+		if p.pos == len(p.lines) {
+			continue
+		}
+
+		next := p.lines[p.pos]
+		switch {
+		case isListItem(next.text) && next.indent >= indent:
+			list, err := p.parseList(next.indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = list
+		case next.indent > indent:
+			sub, err := p.parseMap(next.indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = sub
+		}
+	}
+
+	return m, nil
+}
+
+func (p *yamlParser) parseList(indent int) ([]string, error) {
+	list := []string{}
+
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: only lists of scalars are supported", line.num)
+		}
+		if line.indent < indent || !isListItem(line.text) {
+			break
+		}
+
+		item, err := parseScalar(strings.TrimSpace(line.text[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line.num, err)
+		}
+		list = append(list, item)
+		p.pos++
+	}
+
+	return list, nil
+}
+
+// splitKey splits "key: value" and "key:" lines.
+func splitKey(text string) (string, string, bool) {
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false
+		}
+		i = len(text) - 1
+	}
+
+	key := strings.TrimSpace(text[:i])
+	if key == "" || strings.ContainsAny(key, "\"'[]{}") {
+		return "", "", false
+	}
+
+	return key, strings.TrimSpace(text[i+1:]), true
+}
+
+func parseInlineValue(text string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		return parseFlowList(text)
+	case strings.HasPrefix(text, "{"):
+		return nil, fmt.Errorf("flow mappings are not supported")
+	}
+	return parseScalar(text)
+}
+
+func parseFlowList(text string) ([]string, error) {
+	if !strings.HasSuffix(text, "]") {
+		return nil, fmt.Errorf("unterminated list %s", text)
+	}
+
+	list := []string{}
+	inner := strings.TrimSpace(text[1 : len(text)-1])
+	if inner == "" {
+		return list, nil
+	}
+
+	var (
+		quote rune
+		start int
+		items []string
+	)
+	for i, c := range inner {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, inner[start:i])
+			start = i + 1
+		}
+	}
+	items = append(items, inner[start:])
+
+	for _, item := range items {
+		value, err := parseScalar(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+
+	return list, nil
+}
+
+func parseScalar(text string) (string, error) {
+	switch {
+	case strings.HasPrefix(text, `"`):
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted string %s", text)
+		}
+		return value, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return "", fmt.Errorf("invalid quoted string %s", text)
+		}
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+	case strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{"):
+		return "", fmt.Errorf("nested collections are not supported")
+	case text == "~" || text == "null":
+		return "", nil
+	}
+	return text, nil
+}
+
+// formatScalar quotes s when it would not be read back as the same string.
+func formatScalar(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s, "#:\"'[]{},&*!|>%@`") ||
+		strings.HasPrefix(s, "-") || s == "~" || s == "null" {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package machinefile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseYAML(t *testing.T) {
+	doc := `---
+# A comment
+name: box   # trailing comment
+quoted: "a # not a comment"
+single: 'it''s'
+url: http://example.com/a#b
+empty:
+nested:
+  key: value
+  deeper:
+    list:
+      - one
+      - "two, three"
+flow: [a, "b c", 'd']
+same-indent-list:
+- x
+- y
+last: ~
+`
+
+	m, err := parseYAML(doc)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"name":   "box",
+		"quoted": "a # not a comment",
+		"single": "it's",
+		"url":    "http://example.com/a#b",
+		"empty":  "",
+		"nested": map[string]interface{}{
+			"key": "value",
+			"deeper": map[string]interface{}{
+				"list": []string{"one", "two, three"},
+			},
+		},
+		"flow":             []string{"a", "b c", "d"},
+		"same-indent-list": []string{"x", "y"},
+		"last":             "",
+	}, m)
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	var tests = []struct {
+		doc string
+		err string
+	}{
+		{"a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"a: 1\na: 2\n", `line 2: duplicate key "a"`},
+		{"- a\n", "line 1: unexpected list item"},
+		{"just text\n", `line 1: expected "key: value"`},
+		{"a:\n\t- b\n", "line 2: tabs cannot be used for indentation"},
+		{"a: {b: c}\n", "line 1: flow mappings are not supported"},
+		{"a: [b, c\n", "line 1: unterminated list [b, c"},
+		{"a:\n  - b\n    - c\n", "line 3: only lists of scalars are supported"},
+		{"a: \"b\n", `line 1: invalid quoted string "b`},
+	}
+
+	for _, test := range tests {
+		_, err := parseYAML(test.doc)
+
+		assert.EqualError(t, err, test.err, test.doc)
+	}
+}
+
+func TestFormatScalar(t *testing.T) {
+	for _, s := range []string{"plain", "", " padded", "a: b", "#x", "-x", "null", "http://x", `say "hi"`} {
+		value, err := parseScalar(formatScalar(s))
+
+		assert.NoError(t, err)
+		assert.Equal(t, s, value)
+	}
+}
@@ -44,3 +44,31 @@ type ErrHostAlreadyInState struct {
 func (e ErrHostAlreadyInState) Error() string {
 	return fmt.Sprintf("Machine %q is already %s.", e.Name, strings.ToLower(e.State.String()))
 }
+
+type ErrOperationNotSupported struct {
+	DriverName string
+	Operation  string
+}
+
+func (e ErrOperationNotSupported) Error() string {
+	return fmt.Sprintf("Driver %q does not support %s", e.DriverName, e.Operation)
+}
+
+type ErrHostBusy struct {
+	Name    string
+	Pid     int
+	Command string
+}
+
+func (e ErrHostBusy) Error() string {
+	return fmt.Sprintf("Machine %q is busy (pid %d, command %q)", e.Name, e.Pid, e.Command)
+}
+
+type ErrStoreBusy struct {
+	Pid     int
+	Command string
+}
+
+func (e ErrStoreBusy) Error() string {
+	return fmt.Sprintf("Machine store is busy (pid %d, command %q)", e.Pid, e.Command)
+}
@@ -0,0 +1,346 @@
+package persist
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/mcnerror"
+)
+
+var errNotMachineArchive = errors.New("Not a machine archive")
+
+// configFiles are the files of the file backend in the machine
+// directories. The config of a machine is archived as loaded from the store,
+// whatever the backend.
+var configFiles = map[string]bool{
+	"config.json":     true,
+	"config.json.bak": true,
+}
+
+// Export writes the machine name of the store s, in storePath, to w as a gzipped
+// tar archive: its config, and the files of its directory, such as its
+// disk, ISO, SSH keys and certificates. The files are under a directory
+// named after the machine, with the files used by the machine out of its
+// directory, given by their path in the archive.
+func Export(s Store, storePath, name string, w io.Writer, files map[string]string) error {
+	h, err := s.Load(name)
+	if err != nil {
+		return err
+	}
+	config, err := json.MarshalIndent(h, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name + "/config.json",
+		Typeflag: tar.TypeReg,
+		Mode:     0600,
+		Size:     int64(len(config)),
+		ModTime:  time.Now(),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(config); err != nil {
+		return err
+	}
+
+	hostPath := filepath.Join(machinesDir(storePath), name)
+	err = filepath.Walk(hostPath, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Sockets and pipes only make sense for a running machine
+		if !fi.Mode().IsRegular() && !fi.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(hostPath, file)
+		if err != nil {
+			return err
+		}
+		if configFiles[rel] {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(name, filepath.ToSlash(rel))
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		return archiveFile(tw, file)
+	})
+	if err != nil {
+		return err
+	}
+
+	rels := []string{}
+	for rel := range files {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		fi, err := os.Stat(files[rel])
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(name, filepath.ToSlash(rel))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if err := archiveFile(tw, files[rel]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func archiveFile(tw *tar.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Import adds the machine of an archive written by Export to the store s,
+// in storePath, as the machine name, or under its name in the archive if name is
+// empty. The absolute paths of the config that pointed to the store of the
+// archive are rewritten to point to this store. It returns the name of the
+// machine.
+func Import(s Store, storePath string, r io.Reader, name string) (string, error) {
+	if err := os.MkdirAll(machinesDir(storePath), 0700); err != nil {
+		return "", err
+	}
+
+	// The machine is extracted in a hidden directory, ignored by List,
+	// until it is complete.
+	tmpDir, err := ioutil.TempDir(machinesDir(storePath), ".import-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archiveName, err := extractArchive(r, tmpDir)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		name = archiveName
+	}
+	if !host.ValidateHostName(name) {
+		return "", mcnerror.ErrInvalidHostname
+	}
+
+	if exists, err := s.Exists(name); err != nil {
+		return "", err
+	} else if exists {
+		return "", mcnerror.ErrHostAlreadyExists{
+			Name: name,
+		}
+	}
+
+	configPath := filepath.Join(tmpDir, "config.json")
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return "", errNotMachineArchive
+	}
+	os.Remove(configPath)
+
+	hostPath := filepath.Join(machinesDir(storePath), name)
+	data, err = relocateConfig(data, name, storePath, hostPath)
+	if err != nil {
+		return "", fmt.Errorf("Error reading the config of the archive: %s", err)
+	}
+	h, _, err := host.MigrateHost(&host.Host{Name: name}, data)
+	if err != nil {
+		return "", fmt.Errorf("Error reading the config of the archive: %s", err)
+	}
+	h.Name = name
+
+	if err := os.Rename(tmpDir, hostPath); err != nil {
+		return "", err
+	}
+	if err := s.Save(h); err != nil {
+		os.RemoveAll(hostPath)
+		return "", err
+	}
+
+	return name, nil
+}
+
+// extractArchive extracts the files of the machine directory of a gzipped
+// tar archive to dir, and returns the name of the machine.
+func extractArchive(r io.Reader, dir string) (string, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return "", err
+	}
+	defer gr.Close()
+
+	name := ""
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		parts := strings.SplitN(path.Clean(hdr.Name), "/", 2)
+		if name == "" {
+			name = parts[0]
+		}
+		if parts[0] != name || parts[0] == ".." || path.IsAbs(hdr.Name) {
+			return "", errNotMachineArchive
+		}
+		if len(parts) == 1 {
+			continue
+		}
+		if parts[1] == ".." || strings.HasPrefix(parts[1], "../") {
+			return "", errNotMachineArchive
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(parts[1]))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(file, 0700); err != nil {
+				return "", err
+			}
+		case tar.TypeReg:
+			if err := extractFile(tr, file, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if name == "" {
+		return "", errNotMachineArchive
+	}
+	return name, nil
+}
+
+func extractFile(r io.Reader, file string, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// relocateConfig renames the machine of the config data to name, and
+// rewrites its paths from the store and the machine directory it was
+// exported from, to storePath and hostPath. The old directories are read
+// from the store paths of the driver and of the auth options.
+func relocateConfig(data []byte, name, storePath, hostPath string) ([]byte, error) {
+	config := map[string]interface{}{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	driver, _ := config["Driver"].(map[string]interface{})
+	if driver == nil {
+		return nil, errNotMachineArchive
+	}
+	oldStorePath, _ := driver["StorePath"].(string)
+	oldHostPath := ""
+	if hostOptions, ok := config["HostOptions"].(map[string]interface{}); ok {
+		if authOptions, ok := hostOptions["AuthOptions"].(map[string]interface{}); ok {
+			oldHostPath, _ = authOptions["StorePath"].(string)
+		}
+	}
+
+	relocated := relocatePaths(config, func(p string) string {
+		if rest, ok := trimPathPrefix(p, oldHostPath); ok {
+			return hostPath + rest
+		}
+		if rest, ok := trimPathPrefix(p, oldStorePath); ok {
+			return storePath + rest
+		}
+		return p
+	}).(map[string]interface{})
+
+	relocated["Name"] = name
+	relocated["Driver"].(map[string]interface{})["MachineName"] = name
+
+	return json.MarshalIndent(relocated, "", "    ")
+}
+
+// relocatePaths returns v with relocate applied to all its strings.
+func relocatePaths(v interface{}, relocate func(string) string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return relocate(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = relocatePaths(v[i], relocate)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = relocatePaths(v[k], relocate)
+		}
+	}
+	return v
+}
+
+// trimPathPrefix returns the rest of p after the directory dir, if p is dir
+// or a path in dir.
+func trimPathPrefix(p, dir string) (string, bool) {
+	if dir == "" || !strings.HasPrefix(p, dir) {
+		return "", false
+	}
+	rest := p[len(dir):]
+	if rest != "" && rest[0] != '/' && rest[0] != '\\' {
+		return "", false
+	}
+	return rest, true
+}
@@ -0,0 +1,202 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeExportedMachine(t *testing.T, store Filestore, name string) {
+	hostPath := filepath.Join(store.GetMachinesDir(), name)
+	config := `{
+    "ConfigVersion": 3,
+    "Driver": {
+        "MachineName": "` + name + `",
+        "SSHPort": 2222,
+        "SSHKeyPath": "` + filepath.Join(hostPath, "id_rsa") + `",
+        "StorePath": "` + store.Path + `"
+    },
+    "DriverName": "qemu",
+    "HostOptions": {
+        "AuthOptions": {
+            "CaCertPath": "` + filepath.Join(store.Path, "certs", "ca.pem") + `",
+            "ServerCertPath": "` + filepath.Join(hostPath, "server.pem") + `",
+            "ServerCertSANs": ["` + filepath.Join(hostPath, "not-a-san") + `"],
+            "StorePath": "` + hostPath + `"
+        }
+    },
+    "Name": "` + name + `"
+}`
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(hostPath, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(hostPath, "config.json"), []byte(config), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(hostPath, "id_rsa"), []byte("key"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(hostPath, "sub", "disk"), []byte("disk"), 0644))
+}
+
+func TestExportImport(t *testing.T) {
+	defer cleanup()
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+	writeExportedMachine(t, store, "source")
+
+	archive := &bytes.Buffer{}
+	assert.NoError(t, Export(store, store.Path, "source", archive, nil))
+
+	other := getTestStore()
+	defer os.RemoveAll(other.Path)
+
+	name, err := Import(other, other.Path, archive, "copy")
+	assert.NoError(t, err)
+	assert.Equal(t, "copy", name)
+
+	hostPath := filepath.Join(other.GetMachinesDir(), "copy")
+	key, err := ioutil.ReadFile(filepath.Join(hostPath, "id_rsa"))
+	assert.NoError(t, err)
+	assert.Equal(t, "key", string(key))
+	disk, err := ioutil.ReadFile(filepath.Join(hostPath, "sub", "disk"))
+	assert.NoError(t, err)
+	assert.Equal(t, "disk", string(disk))
+
+	data, err := ioutil.ReadFile(filepath.Join(hostPath, "config.json"))
+	assert.NoError(t, err)
+	var config struct {
+		Name   string
+		Driver struct {
+			MachineName string
+			SSHPort     int
+			SSHKeyPath  string
+			StorePath   string
+		}
+		HostOptions struct {
+			AuthOptions struct {
+				CaCertPath     string
+				ServerCertPath string
+				ServerCertSANs []string
+				StorePath      string
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(data, &config))
+
+	assert.Equal(t, "copy", config.Name)
+	assert.Equal(t, "copy", config.Driver.MachineName)
+	assert.Equal(t, 2222, config.Driver.SSHPort)
+	assert.Equal(t, filepath.Join(hostPath, "id_rsa"), config.Driver.SSHKeyPath)
+	assert.Equal(t, other.Path, config.Driver.StorePath)
+	assert.Equal(t, filepath.Join(other.Path, "certs", "ca.pem"), config.HostOptions.AuthOptions.CaCertPath)
+	assert.Equal(t, filepath.Join(hostPath, "server.pem"), config.HostOptions.AuthOptions.ServerCertPath)
+	assert.Equal(t, []string{filepath.Join(hostPath, "not-a-san")}, config.HostOptions.AuthOptions.ServerCertSANs)
+	assert.Equal(t, hostPath, config.HostOptions.AuthOptions.StorePath)
+
+	names, err := other.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"copy"}, names)
+}
+
+func TestExportImportFilesOutOfMachine(t *testing.T) {
+	defer cleanup()
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+	writeExportedMachine(t, store, "source")
+
+	base := filepath.Join(store.Path, "bases", "box.qcow2")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(base), 0755))
+	assert.NoError(t, ioutil.WriteFile(base, []byte("base"), 0444))
+
+	archive := &bytes.Buffer{}
+	assert.NoError(t, Export(store, store.Path, "source", archive, map[string]string{"base.qcow2": base}))
+
+	other := getTestStore()
+	defer os.RemoveAll(other.Path)
+
+	_, err := Import(other, other.Path, archive, "copy")
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadFile(filepath.Join(other.GetMachinesDir(), "copy", "base.qcow2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "base", string(data))
+}
+
+func TestExportImportKVStore(t *testing.T) {
+	defer cleanup()
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+	writeExportedMachine(t, store, "source")
+
+	archive := &bytes.Buffer{}
+	assert.NoError(t, Export(store, store.Path, "source", archive, nil))
+
+	other := NewKVStore(getTestStore().Path)
+	defer os.RemoveAll(other.Path)
+
+	name, err := Import(other, other.Path, archive, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "source", name)
+
+	hostPath := filepath.Join(other.GetMachinesDir(), "source")
+	disk, err := ioutil.ReadFile(filepath.Join(hostPath, "sub", "disk"))
+	assert.NoError(t, err)
+	assert.Equal(t, "disk", string(disk))
+	_, err = os.Stat(filepath.Join(hostPath, "config.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	h, err := other.Load("source")
+	assert.NoError(t, err)
+	assert.Equal(t, "qemu", h.DriverName)
+	assert.Equal(t, hostPath, h.HostOptions.AuthOptions.StorePath)
+	assert.Equal(t, filepath.Join(hostPath, "server.pem"), h.HostOptions.AuthOptions.ServerCertPath)
+
+	var driver struct {
+		SSHKeyPath string
+		StorePath  string
+	}
+	assert.NoError(t, json.Unmarshal(h.RawDriver, &driver))
+	assert.Equal(t, filepath.Join(hostPath, "id_rsa"), driver.SSHKeyPath)
+	assert.Equal(t, other.Path, driver.StorePath)
+}
+
+func TestImportExistingMachine(t *testing.T) {
+	defer cleanup()
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+	writeExportedMachine(t, store, "source")
+
+	archive := &bytes.Buffer{}
+	assert.NoError(t, Export(store, store.Path, "source", archive, nil))
+
+	_, err := Import(store, store.Path, archive, "")
+
+	assert.Equal(t, mcnerror.ErrHostAlreadyExists{Name: "source"}, err)
+	names, err := store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"source"}, names)
+}
+
+func TestExportMissingMachine(t *testing.T) {
+	defer cleanup()
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+
+	err := Export(store, store.Path, "missing", &bytes.Buffer{}, nil)
+
+	assert.Equal(t, mcnerror.ErrHostDoesNotExist{Name: "missing"}, err)
+}
+
+func TestTrimPathPrefix(t *testing.T) {
+	rest, ok := trimPathPrefix("/store/machines/box/id_rsa", "/store/machines/box")
+	assert.True(t, ok)
+	assert.Equal(t, "/id_rsa", rest)
+
+	_, ok = trimPathPrefix("/store/machines/box2/id_rsa", "/store/machines/box")
+	assert.False(t, ok)
+
+	_, ok = trimPathPrefix("/store", "")
+	assert.False(t, ok)
+}
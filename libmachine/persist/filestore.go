@@ -27,7 +27,7 @@ func NewFilestore(path, caCertPath, caPrivateKeyPath string) *Filestore {
 }
 
 func (s Filestore) GetMachinesDir() string {
-	return filepath.Join(s.Path, "machines")
+	return machinesDir(s.Path)
 }
 
 func (s Filestore) saveToFile(data []byte, file string) error {
@@ -0,0 +1,230 @@
+package persist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/mcnerror"
+)
+
+const kvDatabaseVersion = 1
+
+// KVStore keeps the configs of all the machines in a single database file of
+// the store directory. Changes are made in transactions, serialized by a
+// lock of the file, and written to a new file replacing the previous one
+// atomically, so that readers never see a partial change.
+type KVStore struct {
+	Path string
+}
+
+func NewKVStore(path string) *KVStore {
+	return &KVStore{
+		Path: path,
+	}
+}
+
+// kvDatabase is the content of the database file.
+type kvDatabase struct {
+	Version  int
+	Machines map[string]json.RawMessage
+}
+
+func (s KVStore) GetMachinesDir() string {
+	return machinesDir(s.Path)
+}
+
+func (s KVStore) dbPath() string {
+	return filepath.Join(s.Path, "machines.db")
+}
+
+// view passes the current content of the database to fn, which must not
+// change it.
+func (s KVStore) view(fn func(db *kvDatabase) error) error {
+	db, err := s.read()
+	if err != nil {
+		return err
+	}
+	return fn(db)
+}
+
+// update runs fn in a transaction: the database is written with the
+// changes of fn, unless it returns an error.
+func (s KVStore) update(fn func(db *kvDatabase) error) error {
+	if err := os.MkdirAll(s.Path, 0700); err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(s.dbPath()+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+
+	db, err := s.read()
+	if err != nil {
+		return err
+	}
+	if err := fn(db); err != nil {
+		return err
+	}
+
+	return s.write(db)
+}
+
+func (s KVStore) read() (*kvDatabase, error) {
+	db := &kvDatabase{
+		Version:  kvDatabaseVersion,
+		Machines: map[string]json.RawMessage{},
+	}
+
+	data, err := ioutil.ReadFile(s.dbPath())
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, fmt.Errorf("Error reading the machine database %s: %s", s.dbPath(), err)
+	}
+	if db.Version > kvDatabaseVersion {
+		return nil, fmt.Errorf("Machine database %s is from the future -- you should upgrade your Podman Machine client", s.dbPath())
+	}
+	if db.Machines == nil {
+		db.Machines = map[string]json.RawMessage{}
+	}
+
+	return db, nil
+}
+
+func (s KVStore) write(db *kvDatabase) error {
+	data, err := json.Marshal(db)
+	if err != nil {
+		return err
+	}
+
+	tmpfi, err := ioutil.TempFile(s.Path, "machines.db.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpfi.Name())
+
+	if _, err := tmpfi.Write(data); err != nil {
+		tmpfi.Close()
+		return err
+	}
+	if err := tmpfi.Sync(); err != nil {
+		tmpfi.Close()
+		return err
+	}
+	if err := tmpfi.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpfi.Name(), s.dbPath())
+}
+
+func (s KVStore) Save(host *host.Host) error {
+	data, err := json.Marshal(host)
+	if err != nil {
+		return err
+	}
+
+	// The drivers keep the files of the machine in its directory.
+	if err := os.MkdirAll(filepath.Join(s.GetMachinesDir(), host.Name), 0700); err != nil {
+		return err
+	}
+
+	return s.update(func(db *kvDatabase) error {
+		db.Machines[host.Name] = data
+		return nil
+	})
+}
+
+func (s KVStore) Remove(name string) error {
+	err := s.update(func(db *kvDatabase) error {
+		delete(db.Machines, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(filepath.Join(s.GetMachinesDir(), name))
+}
+
+func (s KVStore) List() ([]string, error) {
+	hostNames := []string{}
+
+	err := s.view(func(db *kvDatabase) error {
+		for name := range db.Machines {
+			hostNames = append(hostNames, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(hostNames)
+	return hostNames, nil
+}
+
+func (s KVStore) Exists(name string) (bool, error) {
+	exists := false
+
+	err := s.view(func(db *kvDatabase) error {
+		_, exists = db.Machines[name]
+		return nil
+	})
+
+	return exists, err
+}
+
+func (s KVStore) Load(name string) (*host.Host, error) {
+	var data []byte
+
+	err := s.view(func(db *kvDatabase) error {
+		data = db.Machines[name]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, mcnerror.ErrHostDoesNotExist{
+			Name: name,
+		}
+	}
+
+	h := &host.Host{
+		Name: name,
+	}
+
+	migratedHost, migrationPerformed, err := host.MigrateHost(h, data)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting migrated host: %s", err)
+	}
+
+	*h = *migratedHost
+
+	h.Name = name
+
+	if migrationPerformed {
+		if err := s.Save(h); err != nil {
+			return nil, fmt.Errorf("Error saving config after migration was performed: %s", err)
+		}
+	}
+
+	return h, nil
+}
@@ -0,0 +1,68 @@
+package persist
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/hosttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKVStoreConcurrentSaves(t *testing.T) {
+	defer cleanup()
+	store := NewKVStore(getTestStore().Path)
+	defer os.RemoveAll(store.Path)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			h, err := hosttest.GetDefaultTestHost()
+			assert.NoError(t, err)
+			h.Name = fmt.Sprintf("host-%d", i)
+			assert.NoError(t, store.Save(h))
+		}(i)
+	}
+	wg.Wait()
+
+	names, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, names, 10)
+}
+
+func TestKVStoreMachineDir(t *testing.T) {
+	defer cleanup()
+	store := NewKVStore(getTestStore().Path)
+	defer os.RemoveAll(store.Path)
+
+	h, err := hosttest.GetDefaultTestHost()
+	assert.NoError(t, err)
+	assert.NoError(t, store.Save(h))
+
+	hostPath := filepath.Join(store.GetMachinesDir(), h.Name)
+	_, err = os.Stat(hostPath)
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(hostPath, "config.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, store.Remove(h.Name))
+	_, err = os.Stat(hostPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestKVStoreCorrupted(t *testing.T) {
+	defer cleanup()
+	store := NewKVStore(getTestStore().Path)
+	defer os.RemoveAll(store.Path)
+
+	assert.NoError(t, ioutil.WriteFile(store.dbPath(), []byte("{"), 0600))
+
+	_, err := store.List()
+	assert.Error(t, err)
+}
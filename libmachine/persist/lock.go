@@ -0,0 +1,107 @@
+package persist
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/mcnerror"
+)
+
+// errLocked is returned by tryLockFile when another process holds the lock.
+var errLocked = errors.New("Lock is held by another process")
+
+// Lock is an advisory lock of the store, or of a machine, held by this
+// process. The files of the locks are in the locks directory of the store,
+// and are never removed, as processes may be waiting on them.
+type Lock struct {
+	f *os.File
+}
+
+// LockStore takes the lock of the store in path, for commands adding or
+// removing machines, whatever its backend. command describes the holder of the lock, for the processes
+// finding it busy. With wait, LockStore waits for another process holding
+// the lock to release it, instead of returning mcnerror.ErrStoreBusy.
+func LockStore(path, command string, wait bool) (*Lock, error) {
+	return takeLock(filepath.Join(path, "locks", "store.lock"), command, wait, func(pid int, holder string) error {
+		return mcnerror.ErrStoreBusy{Pid: pid, Command: holder}
+	})
+}
+
+// LockMachine takes the lock of the machine name of the store in path,
+// which needn't exist yet, as LockStore does. A busy machine is reported as mcnerror.ErrHostBusy.
+func LockMachine(path, name, command string, wait bool) (*Lock, error) {
+	return takeLock(filepath.Join(path, "locks", "machines", name+".lock"), command, wait, func(pid int, holder string) error {
+		return mcnerror.ErrHostBusy{Name: name, Pid: pid, Command: holder}
+	})
+}
+
+func takeLock(path, command string, wait bool, busy func(pid int, holder string) error) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tryLockFile(f); err == errLocked {
+		busyErr := busy(readHolder(f))
+		if !wait {
+			f.Close()
+			return nil, busyErr
+		}
+
+		log.Infof("%s, waiting...", busyErr)
+		if err := lockFile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+	} else if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// The holder is recorded after the lock was taken, so it may be
+	// missing for a short while.
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(fmt.Sprintf("%d\n%s\n", os.Getpid(), command)), 0)
+	}
+
+	return &Lock{f: f}, nil
+}
+
+// readHolder returns the process ID and the command of the holder of the
+// lock file f.
+func readHolder(f *os.File) (int, string) {
+	if _, err := f.Seek(0, 0); err != nil {
+		return 0, ""
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return 0, ""
+	}
+
+	lines := strings.SplitN(string(data), "\n", 3)
+	if len(lines) < 2 {
+		return 0, ""
+	}
+	pid, _ := strconv.Atoi(lines[0])
+	return pid, lines[1]
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	l.f.Truncate(0)
+	if err := unlockFile(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
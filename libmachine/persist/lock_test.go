@@ -0,0 +1,72 @@
+package persist
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boot2podman/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockMachineBusy(t *testing.T) {
+	defer cleanup()
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+
+	lock, err := LockMachine(store.Path, "box", "podman-machine start box", false)
+	assert.NoError(t, err)
+
+	_, err = LockMachine(store.Path, "box", "podman-machine stop box", false)
+	assert.Equal(t, mcnerror.ErrHostBusy{Name: "box", Pid: os.Getpid(), Command: "podman-machine start box"}, err)
+
+	other, err := LockMachine(store.Path, "other", "podman-machine stop other", false)
+	assert.NoError(t, err)
+	assert.NoError(t, other.Unlock())
+
+	assert.NoError(t, lock.Unlock())
+
+	lock, err = LockMachine(store.Path, "box", "podman-machine stop box", false)
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Unlock())
+}
+
+func TestLockStoreBusy(t *testing.T) {
+	defer cleanup()
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+
+	lock, err := LockStore(store.Path, "podman-machine create box", false)
+	assert.NoError(t, err)
+	defer lock.Unlock()
+
+	_, err = LockStore(store.Path, "podman-machine rm box", false)
+	assert.Equal(t, mcnerror.ErrStoreBusy{Pid: os.Getpid(), Command: "podman-machine create box"}, err)
+}
+
+func TestLockMachineWait(t *testing.T) {
+	defer cleanup()
+	store := getTestStore()
+	defer os.RemoveAll(store.Path)
+
+	lock, err := LockMachine(store.Path, "box", "podman-machine start box", false)
+	assert.NoError(t, err)
+
+	locked := make(chan error)
+	go func() {
+		lock, err := LockMachine(store.Path, "box", "podman-machine stop box", true)
+		if err == nil {
+			err = lock.Unlock()
+		}
+		locked <- err
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("Lock taken while busy")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(t, lock.Unlock())
+	assert.NoError(t, <-locked)
+}
@@ -0,0 +1,54 @@
+package persist
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modkernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	errorLockViolation syscall.Errno = 33
+
+	// The locked byte is far past the holder written at the start of the
+	// file, which other processes must be able to read.
+	lockOffsetHigh = 0x7fffffff
+)
+
+func lockFileEx(f *os.File, flags uint32) error {
+	ol := &syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+	r, _, err := procLockFileEx.Call(f.Fd(), uintptr(flags), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func tryLockFile(f *os.File) error {
+	err := lockFileEx(f, lockfileExclusiveLock|lockfileFailImmediately)
+	if err == errorLockViolation {
+		return errLocked
+	}
+	return err
+}
+
+func lockFile(f *os.File) error {
+	return lockFileEx(f, lockfileExclusiveLock)
+}
+
+func unlockFile(f *os.File) error {
+	ol := &syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
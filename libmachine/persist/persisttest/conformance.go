@@ -0,0 +1,130 @@
+package persisttest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/host"
+	"github.com/boot2podman/machine/libmachine/hosttest"
+	"github.com/boot2podman/machine/libmachine/mcnerror"
+	"github.com/boot2podman/machine/libmachine/persist"
+	"github.com/stretchr/testify/assert"
+)
+
+// NewStoreFunc returns an empty store, and a function removing it.
+type NewStoreFunc func(t *testing.T) (persist.Store, func())
+
+// RunConformance runs the tests every persist.Store backend must pass, each
+// on a new store.
+func RunConformance(t *testing.T, newStore NewStoreFunc) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s persist.Store)
+	}{
+		{"ListEmpty", testListEmpty},
+		{"SaveLoad", testSaveLoad},
+		{"LoadMissing", testLoadMissing},
+		{"SaveOverwrites", testSaveOverwrites},
+		{"ListSorted", testListSorted},
+		{"Remove", testRemove},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, cleanup := newStore(t)
+			defer cleanup()
+
+			test.test(t, s)
+		})
+	}
+}
+
+func newTestHost(t *testing.T, name string) *host.Host {
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Name = name
+	return h
+}
+
+func testListEmpty(t *testing.T, s persist.Store) {
+	names, err := s.List()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, names)
+}
+
+func testSaveLoad(t *testing.T, s persist.Store) {
+	h := newTestHost(t, "box")
+	h.HostOptions.Memory = 2048
+	h.HostOptions.DriverOptions = map[string]interface{}{"qemu-cpu-count": "2"}
+
+	assert.NoError(t, s.Save(h))
+
+	exists, err := s.Exists("box")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	loaded, err := s.Load("box")
+	assert.NoError(t, err)
+	assert.Equal(t, "box", loaded.Name)
+	assert.Equal(t, h.ConfigVersion, loaded.ConfigVersion)
+	assert.Equal(t, h.DriverName, loaded.DriverName)
+	assert.Equal(t, h.HostOptions, loaded.HostOptions)
+
+	driver, err := json.Marshal(h.Driver)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(driver), string(loaded.RawDriver))
+}
+
+func testLoadMissing(t *testing.T, s persist.Store) {
+	exists, err := s.Exists("missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = s.Load("missing")
+	assert.Equal(t, mcnerror.ErrHostDoesNotExist{Name: "missing"}, err)
+}
+
+func testSaveOverwrites(t *testing.T, s persist.Store) {
+	h := newTestHost(t, "box")
+	assert.NoError(t, s.Save(h))
+
+	h.HostOptions.Memory = 4096
+	assert.NoError(t, s.Save(h))
+
+	loaded, err := s.Load("box")
+	assert.NoError(t, err)
+	assert.Equal(t, 4096, loaded.HostOptions.Memory)
+
+	names, err := s.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"box"}, names)
+}
+
+func testListSorted(t *testing.T, s persist.Store) {
+	for _, name := range []string{"c", "a", "b"} {
+		assert.NoError(t, s.Save(newTestHost(t, name)))
+	}
+
+	names, err := s.List()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+}
+
+func testRemove(t *testing.T, s persist.Store) {
+	assert.NoError(t, s.Save(newTestHost(t, "box")))
+	assert.NoError(t, s.Save(newTestHost(t, "other")))
+
+	assert.NoError(t, s.Remove("box"))
+
+	exists, err := s.Exists("box")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	names, err := s.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other"}, names)
+}
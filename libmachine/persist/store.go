@@ -1,9 +1,20 @@
 package persist
 
 import (
+	"fmt"
+	"path/filepath"
+
 	"github.com/boot2podman/machine/libmachine/host"
 )
 
+const (
+	// FileBackend keeps the config of each machine in its directory
+	FileBackend = "file"
+	// KVBackend keeps the configs of all the machines in a single database
+	// file
+	KVBackend = "kv"
+)
+
 type Store interface {
 	// Exists returns whether a machine exists or not
 	Exists(name string) (bool, error)
@@ -21,6 +32,25 @@ type Store interface {
 	Save(host *host.Host) error
 }
 
+// NewStore returns a store of the backend named backend, in the directory
+// path. Whatever the backend, the files of the machines are in their
+// directories, in the machines directory of path.
+func NewStore(backend, path string) (Store, error) {
+	switch backend {
+	case FileBackend, "":
+		return NewFilestore(path, "", ""), nil
+	case KVBackend:
+		return NewKVStore(path), nil
+	}
+	return nil, fmt.Errorf("Unknown storage backend %q, expected %q or %q", backend, FileBackend, KVBackend)
+}
+
+// machinesDir returns the directory of the machine directories of the
+// store in path.
+func machinesDir(path string) string {
+	return filepath.Join(path, "machines")
+}
+
 func LoadHosts(s Store, hostNames []string) ([]*host.Host, map[string]error) {
 	loadedHosts := []*host.Host{}
 	errors := map[string]error{}
@@ -0,0 +1,49 @@
+package persist_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boot2podman/machine/libmachine/persist"
+	"github.com/boot2podman/machine/libmachine/persist/persisttest"
+)
+
+func newTempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "machine-store-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestFilestoreConformance(t *testing.T) {
+	persisttest.RunConformance(t, func(t *testing.T) (persist.Store, func()) {
+		dir, cleanup := newTempDir(t)
+		return persist.NewFilestore(dir, "", ""), cleanup
+	})
+}
+
+func TestKVStoreConformance(t *testing.T) {
+	persisttest.RunConformance(t, func(t *testing.T) (persist.Store, func()) {
+		dir, cleanup := newTempDir(t)
+		return persist.NewKVStore(dir), cleanup
+	})
+}
+
+func TestNewStore(t *testing.T) {
+	dir, cleanup := newTempDir(t)
+	defer cleanup()
+
+	s, err := persist.NewStore(persist.KVBackend, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*persist.KVStore); !ok {
+		t.Fatalf("Expected a KVStore, got %T", s)
+	}
+
+	if _, err := persist.NewStore("bolt", dir); err == nil {
+		t.Fatal("Expected an error for an unknown backend")
+	}
+}
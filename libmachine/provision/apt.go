@@ -0,0 +1,88 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/boot2podman/machine/libmachine/auth"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/engine"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/provision/pkgaction"
+)
+
+// aptPackages are the packages Podman and its varlink bridge come in.
+var aptPackages = []string{"podman", "varlink"}
+
+func NewAptProvisioner(osReleaseID string, d drivers.Driver) *AptProvisioner {
+	systemdProvisioner := NewSystemdProvisioner(osReleaseID, d)
+	systemdProvisioner.Packages = aptPackages
+	return &AptProvisioner{
+		systemdProvisioner,
+	}
+}
+
+// AptProvisioner installs Podman from the repositories of distributions
+// using apt, such as Debian and Ubuntu.
+type AptProvisioner struct {
+	SystemdProvisioner
+}
+
+func (provisioner *AptProvisioner) String() string {
+	return "apt"
+}
+
+func (provisioner *AptProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	var packageAction string
+
+	switch action {
+	case pkgaction.Install:
+		packageAction = "install"
+	case pkgaction.Remove:
+		packageAction = "remove"
+	case pkgaction.Purge:
+		packageAction = "purge"
+	case pkgaction.Upgrade:
+		packageAction = "install --only-upgrade"
+	}
+
+	command := fmt.Sprintf("sudo DEBIAN_FRONTEND=noninteractive apt-get %s -y %s", packageAction, name)
+
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (provisioner *AptProvisioner) Provision(authOptions auth.Options, engineOptions engine.Options) error {
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+
+	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+		return err
+	}
+
+	log.Info("Updating the package lists...")
+	if _, err := provisioner.SSHCommand("sudo DEBIAN_FRONTEND=noninteractive apt-get update"); err != nil {
+		return err
+	}
+
+	for _, pkg := range provisioner.Packages {
+		log.Debugf("installing base package: name=%s", pkg)
+		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	if err := provisioner.enablePodmanSocket(); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	return ConfigureContainers(provisioner, engineOptions)
+}
@@ -0,0 +1,83 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/provision/pkgaction"
+	"github.com/boot2podman/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	ubuntuFocal = []byte(`NAME="Ubuntu"
+VERSION="20.04.1 LTS (Focal Fossa)"
+ID=ubuntu
+ID_LIKE=debian
+PRETTY_NAME="Ubuntu 20.04.1 LTS"
+VERSION_ID="20.04"
+HOME_URL="https://www.ubuntu.com/"
+SUPPORT_URL="https://help.ubuntu.com/"
+BUG_REPORT_URL="https://bugs.launchpad.net/ubuntu/"
+PRIVACY_POLICY_URL="https://www.ubuntu.com/legal/terms-and-policies/privacy-policy"
+VERSION_CODENAME=focal
+UBUNTU_CODENAME=focal
+`)
+	debianBuster = []byte(`PRETTY_NAME="Debian GNU/Linux 10 (buster)"
+NAME="Debian GNU/Linux"
+VERSION_ID="10"
+VERSION="10 (buster)"
+VERSION_CODENAME=buster
+ID=debian
+HOME_URL="https://www.debian.org/"
+SUPPORT_URL="https://www.debian.org/support"
+BUG_REPORT_URL="https://bugs.debian.org/"
+`)
+)
+
+// compatibleProvisioners returns the names of the registered provisioners
+// compatible with the os-release file osr.
+func compatibleProvisioners(t *testing.T, osr []byte) []string {
+	info, err := NewOsRelease(osr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for name, p := range provisioners {
+		provisioner := p.New(&fakedriver.Driver{})
+		provisioner.SetOsReleaseInfo(info)
+		if provisioner.CompatibleWithHost() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestAptCompatibleWithHost(t *testing.T) {
+	assert.Equal(t, []string{"Ubuntu"}, compatibleProvisioners(t, ubuntuFocal))
+	assert.Equal(t, []string{"Debian"}, compatibleProvisioners(t, debianBuster))
+}
+
+func TestAptPackage(t *testing.T) {
+	var tests = []struct {
+		action  pkgaction.PackageAction
+		command string
+	}{
+		{pkgaction.Install, "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y podman"},
+		{pkgaction.Remove, "sudo DEBIAN_FRONTEND=noninteractive apt-get remove -y podman"},
+		{pkgaction.Purge, "sudo DEBIAN_FRONTEND=noninteractive apt-get purge -y podman"},
+		{pkgaction.Upgrade, "sudo DEBIAN_FRONTEND=noninteractive apt-get install --only-upgrade -y podman"},
+	}
+
+	for _, test := range tests {
+		p := NewUbuntuProvisioner(&fakedriver.Driver{}).(*UbuntuProvisioner)
+		p.SSHCommander = &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				test.command: "",
+			},
+		}
+
+		assert.NoError(t, p.Package("podman", test.action))
+	}
+}
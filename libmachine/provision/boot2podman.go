@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	//"net"
+	"os"
 	"path"
 	"text/template"
 	//"time"
@@ -37,6 +38,8 @@ type Boot2PodmanProvisioner struct {
 	Driver        drivers.Driver
 	AuthOptions   auth.Options
 	EngineOptions engine.Options
+	// sshCommander replaces the SSH commands of the driver, for dry runs
+	sshCommander SSHCommander
 }
 
 func (provisioner *Boot2PodmanProvisioner) String() string {
@@ -202,13 +205,38 @@ func (provisioner *Boot2PodmanProvisioner) Provision(authOptions auth.Options, e
 		return err
 	}
 
+	// Like the hostname, a copy is kept on the persistent disk, for the
+	// next boot.
+	if err = ConfigureContainers(provisioner, engineOptions, containersConfigDir, "/var/lib/boot2podman/etc/containers"); err != nil {
+		return err
+	}
+
 	return err
 }
 
 func (provisioner *Boot2PodmanProvisioner) SSHCommand(args string) (string, error) {
+	if provisioner.sshCommander != nil {
+		return provisioner.sshCommander.SSHCommand(args)
+	}
 	return drivers.RunSSHCommandFromDriver(provisioner.Driver, args)
 }
 
+// TransferFile streams the file with the SSH commander replacing the one of
+// the driver, if any.
+func (provisioner *Boot2PodmanProvisioner) TransferFile(content []byte, remotePath string, mode os.FileMode, owner string) error {
+	if provisioner.sshCommander != nil {
+		if transferer, ok := provisioner.sshCommander.(FileTransferer); ok {
+			return transferer.TransferFile(content, remotePath, mode, owner)
+		}
+		return ErrFileTransferNotSupported
+	}
+	return GenericSSHCommander{Driver: provisioner.Driver}.TransferFile(content, remotePath, mode, owner)
+}
+
+func (provisioner *Boot2PodmanProvisioner) setSSHCommander(sshCommander SSHCommander) {
+	provisioner.sshCommander = sshCommander
+}
+
 func (provisioner *Boot2PodmanProvisioner) GetDriver() drivers.Driver {
 	return provisioner.Driver
 }
@@ -0,0 +1,297 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/boot2podman/machine/libmachine/engine"
+	"github.com/boot2podman/machine/libmachine/log"
+)
+
+// containersConfigDir is where Podman reads its system-wide configuration.
+const containersConfigDir = "/etc/containers"
+
+// defaultRegistry is the registry short image names are pulled from, and
+// the one the registry mirrors stand in for, as with Docker.
+const defaultRegistry = "docker.io"
+
+var containersTemplateFuncs = template.FuncMap{
+	"quote": tomlQuote,
+	"list":  tomlList,
+}
+
+const registriesConfTemplate = `# Generated by podman-machine from the engine options of the machine.
+unqualified-search-registries = [{{quote .DefaultRegistry}}]
+{{range .Registries}}
+[[registry]]
+location = {{quote .Location}}
+{{- if .Insecure}}
+insecure = true
+{{- end}}
+{{- range .Mirrors}}
+
+[[registry.mirror]]
+location = {{quote .Location}}
+{{- if .Insecure}}
+insecure = true
+{{- end}}
+{{- end}}
+{{end}}`
+
+const storageConfTemplate = `# Generated by podman-machine from the engine options of the machine.
+[storage]
+driver = {{quote .Driver}}
+runroot = "/var/run/containers/storage"
+graphroot = {{quote .GraphRoot}}
+`
+
+const containersConfTemplate = `# Generated by podman-machine from the engine options of the machine.
+[containers]
+{{- if .DNS}}
+dns_servers = {{list .DNS}}
+{{- end}}
+{{- if .Env}}
+env = {{list .Env}}
+{{- end}}
+`
+
+type registryConfig struct {
+	Location string
+	Insecure bool
+	Mirrors  []registryConfig
+}
+
+type registriesConfContext struct {
+	DefaultRegistry string
+	Registries      []*registryConfig
+}
+
+type storageConfContext struct {
+	Driver    string
+	GraphRoot string
+}
+
+type containersConfContext struct {
+	DNS []string
+	Env []string
+}
+
+// ContainersConfig is the content of the configuration files of Podman
+// rendered from the engine options, by file name. Files for which there are
+// no options are left out, so that the defaults of the distribution apply.
+type ContainersConfig map[string]string
+
+// GenerateContainersConfig renders the engine options into the
+// registries.conf, storage.conf and containers.conf files read by Podman.
+func GenerateContainersConfig(engineOptions engine.Options) (ContainersConfig, error) {
+	config := ContainersConfig{}
+
+	if len(engineOptions.Labels) > 0 {
+		log.Warnf("Podman has no engine labels, ignoring: %s", strings.Join(engineOptions.Labels, ", "))
+	}
+
+	if len(engineOptions.RegistryMirror) > 0 || len(engineOptions.InsecureRegistry) > 0 {
+		content, err := renderContainersConfig(registriesConfTemplate, newRegistriesConfContext(engineOptions))
+		if err != nil {
+			return nil, err
+		}
+		config["registries.conf"] = content
+	}
+
+	if engineOptions.StorageDriver != "" || engineOptions.GraphDir != "" {
+		content, err := renderContainersConfig(storageConfTemplate, newStorageConfContext(engineOptions))
+		if err != nil {
+			return nil, err
+		}
+		config["storage.conf"] = content
+	}
+
+	if len(engineOptions.DNS) > 0 || len(engineOptions.Env) > 0 {
+		content, err := renderContainersConfig(containersConfTemplate, containersConfContext{
+			DNS: engineOptions.DNS,
+			Env: engineOptions.Env,
+		})
+		if err != nil {
+			return nil, err
+		}
+		config["containers.conf"] = content
+	}
+
+	return config, nil
+}
+
+func newRegistriesConfContext(engineOptions engine.Options) registriesConfContext {
+	context := registriesConfContext{
+		DefaultRegistry: defaultRegistry,
+	}
+
+	registries := map[string]*registryConfig{}
+	registry := func(location string) *registryConfig {
+		if r, ok := registries[location]; ok {
+			return r
+		}
+		r := &registryConfig{
+			Location: location,
+		}
+		registries[location] = r
+		context.Registries = append(context.Registries, r)
+		return r
+	}
+
+	for _, mirror := range engineOptions.RegistryMirror {
+		location, insecure := parseRegistryMirror(mirror)
+		r := registry(defaultRegistry)
+		r.Mirrors = append(r.Mirrors, registryConfig{
+			Location: location,
+			Insecure: insecure,
+		})
+	}
+
+	for _, insecure := range engineOptions.InsecureRegistry {
+		// Docker takes networks of insecure registries, Podman doesn't
+		if _, _, err := net.ParseCIDR(insecure); err == nil {
+			log.Warnf("Podman doesn't support insecure registry networks, ignoring: %s", insecure)
+			continue
+		}
+		registry(insecure).Insecure = true
+	}
+
+	return context
+}
+
+// parseRegistryMirror returns the location of a Docker registry mirror URL,
+// and whether it is served over plain HTTP.
+func parseRegistryMirror(mirror string) (string, bool) {
+	u, err := url.Parse(mirror)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(mirror, "/"), false
+	}
+	return strings.TrimSuffix(u.Host+u.Path, "/"), u.Scheme == "http"
+}
+
+func newStorageConfContext(engineOptions engine.Options) storageConfContext {
+	context := storageConfContext{
+		Driver:    engineOptions.StorageDriver,
+		GraphRoot: engineOptions.GraphDir,
+	}
+
+	// Podman names the overlay2 driver of Docker overlay
+	if context.Driver == "" || context.Driver == "overlay2" {
+		context.Driver = "overlay"
+	}
+	if context.GraphRoot == "" {
+		context.GraphRoot = "/var/lib/containers/storage"
+	}
+
+	return context
+}
+
+func renderContainersConfig(tmpl string, context interface{}) (string, error) {
+	t, err := template.New("containersConfig").Funcs(containersTemplateFuncs).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, context); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// tomlQuote returns s as a TOML basic string. TOML has fewer escapes than
+// Go, so the other control characters are written as \uXXXX.
+func tomlQuote(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&buf, `\u%04X`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// tomlList returns values as a TOML array of basic strings.
+func tomlList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = tomlQuote(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// removeGeneratedCommandFmt removes the files given as arguments which were
+// generated by podman-machine, leaving those written by hand alone.
+const removeGeneratedCommandFmt = `sudo sh -c 'for f; do if head -n 1 "$f" 2>/dev/null | grep -q "^%s"; then rm -f "$f"; fi; done' -%s`
+
+// generatedHeader starts the files rendered by GenerateContainersConfig.
+const generatedHeader = "# Generated by podman-machine"
+
+// ConfigureContainers writes the configuration files of Podman rendered from
+// the engine options to dirs on the machine, /etc/containers unless given.
+// The files are written whole, so provisioning again gives the same result,
+// and the generated files whose options are gone are removed.
+func ConfigureContainers(p Provisioner, engineOptions engine.Options, dirs ...string) error {
+	config, err := GenerateContainersConfig(engineOptions)
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		dirs = []string{containersConfigDir}
+	}
+
+	log.Info("Configuring containers...")
+
+	for _, dir := range dirs {
+		if len(config) > 0 {
+			if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", dir)); err != nil {
+				return err
+			}
+		}
+		stale := ""
+		for _, name := range []string{"registries.conf", "storage.conf", "containers.conf"} {
+			content, ok := config[name]
+			if !ok {
+				stale += " " + path.Join(dir, name)
+				continue
+			}
+			if err := transferFile(p, []byte(content), path.Join(dir, name), 0644, "root:root"); err != nil {
+				return fmt.Errorf("Error writing %s: %s", name, err)
+			}
+		}
+		if stale != "" {
+			if _, err := p.SSHCommand(fmt.Sprintf(removeGeneratedCommandFmt, generatedHeader, stale)); err != nil {
+				return fmt.Errorf("Error removing stale configuration: %s", err)
+			}
+		}
+	}
+
+	return nil
+}
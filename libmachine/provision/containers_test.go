@@ -0,0 +1,142 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/engine"
+	"github.com/boot2podman/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateContainersConfigEmpty(t *testing.T) {
+	config, err := GenerateContainersConfig(engine.Options{
+		Labels: []string{"foo=bar"},
+	})
+
+	assert.NoError(t, err)
+	assert.Empty(t, config)
+}
+
+func TestGenerateContainersConfigRegistries(t *testing.T) {
+	config, err := GenerateContainersConfig(engine.Options{
+		RegistryMirror:   []string{"https://mirror.gcr.io/", "http://10.0.0.2:5000"},
+		InsecureRegistry: []string{"registry.local:5000", "10.0.0.0/8", "docker.io"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `# Generated by podman-machine from the engine options of the machine.
+unqualified-search-registries = ["docker.io"]
+
+[[registry]]
+location = "docker.io"
+insecure = true
+
+[[registry.mirror]]
+location = "mirror.gcr.io"
+
+[[registry.mirror]]
+location = "10.0.0.2:5000"
+insecure = true
+
+[[registry]]
+location = "registry.local:5000"
+insecure = true
+`, config["registries.conf"])
+	assert.NotContains(t, config, "storage.conf")
+	assert.NotContains(t, config, "containers.conf")
+}
+
+func TestGenerateContainersConfigStorage(t *testing.T) {
+	var tests = []struct {
+		options  engine.Options
+		expected string
+	}{
+		{engine.Options{StorageDriver: "overlay2"}, `# Generated by podman-machine from the engine options of the machine.
+[storage]
+driver = "overlay"
+runroot = "/var/run/containers/storage"
+graphroot = "/var/lib/containers/storage"
+`},
+		{engine.Options{StorageDriver: "vfs", GraphDir: "/mnt/sda1/containers"}, `# Generated by podman-machine from the engine options of the machine.
+[storage]
+driver = "vfs"
+runroot = "/var/run/containers/storage"
+graphroot = "/mnt/sda1/containers"
+`},
+	}
+
+	for _, test := range tests {
+		config, err := GenerateContainersConfig(test.options)
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, config["storage.conf"])
+	}
+}
+
+func TestGenerateContainersConfigContainers(t *testing.T) {
+	config, err := GenerateContainersConfig(engine.Options{
+		DNS: []string{"8.8.8.8"},
+		Env: []string{"HTTP_PROXY=http://proxy:3128", `GREETING="hi"`},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `# Generated by podman-machine from the engine options of the machine.
+[containers]
+dns_servers = ["8.8.8.8"]
+env = ["HTTP_PROXY=http://proxy:3128", "GREETING=\"hi\""]
+`, config["containers.conf"])
+}
+
+func TestTomlQuote(t *testing.T) {
+	var tests = []struct {
+		value    string
+		expected string
+	}{
+		{"registry.local:5000", `"registry.local:5000"`},
+		{`C:\images "new"`, `"C:\\images \"new\""`},
+		{"a\bb\tc\nd\fe\rf", `"a\bb\tc\nd\fe\rf"`},
+		{"bell\a tab\v nul\x00 del\x7f esc\x1b", `"bell\u0007 tab\u000B nul\u0000 del\u007F esc\u001B"`},
+		{"grüße", `"grüße"`},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, tomlQuote(test.value))
+	}
+}
+
+func TestConfigureContainers(t *testing.T) {
+	transferer := &fakeFileTransferer{files: map[string]string{}}
+	transferer.Responses = map[string]string{
+		"sudo mkdir -p /etc/containers": "",
+		`sudo sh -c 'for f; do if head -n 1 "$f" 2>/dev/null | grep -q "^# Generated by podman-machine"; then rm -f "$f"; fi; done' - /etc/containers/registries.conf`: "",
+	}
+	p := &fakeProvisioner{GenericProvisioner{
+		SSHCommander: transferer,
+		Driver:       &fakedriver.Driver{},
+	}}
+
+	err := ConfigureContainers(p, engine.Options{
+		StorageDriver: "overlay",
+		Env:           []string{"MOTD=it's up"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"/etc/containers/storage.conf":    "# Generated by podman-machine from the engine options of the machine.\n[storage]\ndriver = \"overlay\"\nrunroot = \"/var/run/containers/storage\"\ngraphroot = \"/var/lib/containers/storage\"\n",
+		"/etc/containers/containers.conf": "# Generated by podman-machine from the engine options of the machine.\n[containers]\nenv = [\"MOTD=it's up\"]\n",
+	}, transferer.files)
+}
+
+func TestConfigureContainersRemovesStale(t *testing.T) {
+	p := &fakeProvisioner{GenericProvisioner{
+		Driver: &fakedriver.Driver{},
+	}}
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			`sudo sh -c 'for f; do if head -n 1 "$f" 2>/dev/null | grep -q "^# Generated by podman-machine"; then rm -f "$f"; fi; done' - /etc/containers/registries.conf /etc/containers/storage.conf /etc/containers/containers.conf`: "",
+		},
+	}
+
+	assert.NoError(t, ConfigureContainers(p, engine.Options{}))
+}
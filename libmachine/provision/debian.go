@@ -0,0 +1,25 @@
+package provision
+
+import (
+	"github.com/boot2podman/machine/libmachine/drivers"
+)
+
+func init() {
+	Register("Debian", &RegisteredProvisioner{
+		New: NewDebianProvisioner,
+	})
+}
+
+func NewDebianProvisioner(d drivers.Driver) Provisioner {
+	return &DebianProvisioner{
+		NewAptProvisioner("debian", d),
+	}
+}
+
+type DebianProvisioner struct {
+	*AptProvisioner
+}
+
+func (provisioner *DebianProvisioner) String() string {
+	return "debian"
+}
@@ -0,0 +1,67 @@
+package provision
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/boot2podman/machine/libmachine/auth"
+	"github.com/boot2podman/machine/libmachine/engine"
+)
+
+// sshCommanderSetter is implemented by the provisioners whose SSH commands
+// can be replaced.
+type sshCommanderSetter interface {
+	setSSHCommander(sshCommander SSHCommander)
+}
+
+// RecordingSSHCommander records the commands it is given instead of running
+// them, and answers them with an empty output.
+type RecordingSSHCommander struct {
+	Commands []string
+}
+
+// SSHCommand records the command args.
+func (sshCmder *RecordingSSHCommander) SSHCommand(args string) (string, error) {
+	sshCmder.Commands = append(sshCmder.Commands, args)
+	return "", nil
+}
+
+// TransferFile records the path, size, mode and owner of the file, so that
+// keys aren't shown.
+func (sshCmder *RecordingSSHCommander) TransferFile(content []byte, remotePath string, mode os.FileMode, owner string) error {
+	args := fmt.Sprintf("upload %s (%d bytes, mode %04o, owner %s)", remotePath, len(content), mode.Perm(), owner)
+	sshCmder.Commands = append(sshCmder.Commands, args)
+	return nil
+}
+
+// DryRun runs the provisioning of p with a RecordingSSHCommander, and
+// returns the commands and file uploads it would run on the machine. The
+// server certificate is generated in a temporary directory, so that the one
+// of the machine is left alone.
+func DryRun(p Provisioner, authOptions auth.Options, engineOptions engine.Options) ([]string, error) {
+	setter, ok := p.(sshCommanderSetter)
+	if !ok {
+		return nil, fmt.Errorf("The %s provisioner doesn't support dry runs", p)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "podman-machine-dry-run")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	authOptions.StorePath = tmpDir
+	authOptions.ServerCertPath = filepath.Join(tmpDir, "server.pem")
+	authOptions.ServerKeyPath = filepath.Join(tmpDir, "server-key.pem")
+
+	recorder := &RecordingSSHCommander{}
+	setter.setSSHCommander(recorder)
+
+	if err := p.Provision(authOptions, engineOptions); err != nil {
+		return nil, err
+	}
+
+	return recorder.Commands, nil
+}
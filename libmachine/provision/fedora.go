@@ -23,3 +23,9 @@ type FedoraProvisioner struct {
 func (provisioner *FedoraProvisioner) String() string {
 	return "fedora"
 }
+
+// CompatibleWithHost leaves Fedora CoreOS, which has the same ID, to its own
+// provisioner.
+func (provisioner *FedoraProvisioner) CompatibleWithHost() bool {
+	return provisioner.RedHatProvisioner.CompatibleWithHost() && provisioner.OsReleaseInfo.VariantID != "coreos"
+}
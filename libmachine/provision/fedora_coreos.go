@@ -0,0 +1,146 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/auth"
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/engine"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/mcnutils"
+	"github.com/boot2podman/machine/libmachine/provision/pkgaction"
+)
+
+// rpmOstreeChanged is printed when an rpm-ostree command staged a new
+// deployment, which is only booted into by a reboot.
+const rpmOstreeChanged = "rpm-ostree: changed"
+
+func init() {
+	Register("FedoraCoreOS", &RegisteredProvisioner{
+		New: NewFedoraCoreOSProvisioner,
+	})
+}
+
+func NewFedoraCoreOSProvisioner(d drivers.Driver) Provisioner {
+	systemdProvisioner := NewSystemdProvisioner("fedora", d)
+	systemdProvisioner.Packages = []string{"podman", "libvarlink-util"}
+	return &FedoraCoreOSProvisioner{
+		systemdProvisioner,
+	}
+}
+
+// FedoraCoreOSProvisioner layers packages on the read-only system of Fedora
+// CoreOS with rpm-ostree, and reboots the machine into the new deployment.
+type FedoraCoreOSProvisioner struct {
+	SystemdProvisioner
+}
+
+func (provisioner *FedoraCoreOSProvisioner) String() string {
+	return "fedora-coreos"
+}
+
+func (provisioner *FedoraCoreOSProvisioner) CompatibleWithHost() bool {
+	return provisioner.OsReleaseInfo.ID == provisioner.OsReleaseID && provisioner.OsReleaseInfo.VariantID == "coreos"
+}
+
+// rpmOstree runs the rpm-ostree command args, and tells whether it staged a
+// new deployment.
+func (provisioner *FedoraCoreOSProvisioner) rpmOstree(args string) (bool, error) {
+	// Exit status 77 means nothing changed
+	command := fmt.Sprintf("sudo rpm-ostree %s --unchanged-exit-77 && echo '%s' || [ $? -eq 77 ]", args, rpmOstreeChanged)
+
+	output, err := provisioner.SSHCommand(command)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.Contains(output, rpmOstreeChanged), nil
+}
+
+// reboot reboots the machine, and waits for it to be back.
+func (provisioner *FedoraCoreOSProvisioner) reboot() error {
+	bootIDCommand := "cat /proc/sys/kernel/random/boot_id"
+
+	bootID, err := provisioner.SSHCommand(bootIDCommand)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Rebooting the machine into the new deployment...")
+
+	// The connection is closed by the reboot, so its error is expected
+	if _, err := provisioner.SSHCommand("sudo systemctl reboot"); err != nil {
+		log.Debugf("Error rebooting: %s", err)
+	}
+
+	return mcnutils.WaitFor(func() bool {
+		newBootID, err := provisioner.SSHCommand(bootIDCommand)
+		return err == nil && newBootID != bootID
+	})
+}
+
+// install layers the packages which are not part of the system yet, and
+// reboots once if any was.
+func (provisioner *FedoraCoreOSProvisioner) install(packages ...string) error {
+	changed, err := provisioner.rpmOstree("install --idempotent --allow-inactive " + strings.Join(packages, " "))
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		return nil
+	}
+	return provisioner.reboot()
+}
+
+func (provisioner *FedoraCoreOSProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	var (
+		changed bool
+		err     error
+	)
+
+	switch action {
+	case pkgaction.Install:
+		return provisioner.install(name)
+	case pkgaction.Remove, pkgaction.Purge:
+		changed, err = provisioner.rpmOstree("uninstall " + name)
+	case pkgaction.Upgrade:
+		// The packages are upgraded with the whole system
+		changed, err = provisioner.rpmOstree("upgrade")
+	}
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		return nil
+	}
+	return provisioner.reboot()
+}
+
+func (provisioner *FedoraCoreOSProvisioner) Provision(authOptions auth.Options, engineOptions engine.Options) error {
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+
+	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+		return err
+	}
+
+	log.Debugf("installing base packages: names=%s", strings.Join(provisioner.Packages, " "))
+	if err := provisioner.install(provisioner.Packages...); err != nil {
+		return err
+	}
+
+	if err := provisioner.enablePodmanSocket(); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	return ConfigureContainers(provisioner, engineOptions)
+}
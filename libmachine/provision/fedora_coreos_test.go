@@ -0,0 +1,117 @@
+package provision
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/provision/pkgaction"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	fedoraCoreOS = []byte(`NAME=Fedora
+VERSION="32.20200907.3.0 (CoreOS)"
+ID=fedora
+VERSION_ID=32
+VERSION_CODENAME=""
+PLATFORM_ID="platform:f32"
+PRETTY_NAME="Fedora CoreOS 32.20200907.3.0"
+ANSI_COLOR="0;34"
+LOGO=fedora-logo-icon
+CPE_NAME="cpe:/o:fedoraproject:fedora:32"
+HOME_URL="https://getfedora.org/coreos/"
+DOCUMENTATION_URL="https://docs.fedoraproject.org/en-US/fedora-coreos/"
+SUPPORT_URL="https://github.com/coreos/fedora-coreos-tracker/"
+BUG_REPORT_URL="https://github.com/coreos/fedora-coreos-tracker/"
+REDHAT_BUGZILLA_PRODUCT="Fedora"
+REDHAT_BUGZILLA_PRODUCT_VERSION=32
+REDHAT_SUPPORT_PRODUCT="Fedora"
+REDHAT_SUPPORT_PRODUCT_VERSION=32
+PRIVACY_POLICY_URL="https://fedoraproject.org/wiki/Legal:PrivacyPolicy"
+VARIANT="CoreOS"
+VARIANT_ID=coreos
+OSTREE_VERSION='32.20200907.3.0'
+`)
+	fedoraServer = []byte(`NAME=Fedora
+VERSION="32 (Server Edition)"
+ID=fedora
+VERSION_ID=32
+PRETTY_NAME="Fedora 32 (Server Edition)"
+ANSI_COLOR="0;34"
+HOME_URL="https://fedoraproject.org/"
+BUG_REPORT_URL="https://bugzilla.redhat.com/"
+VARIANT="Server Edition"
+VARIANT_ID=server
+`)
+)
+
+const (
+	testBootIDCommand = "cat /proc/sys/kernel/random/boot_id"
+	testRebootCommand = "sudo systemctl reboot"
+)
+
+// rebootingSSHCommander answers the registered commands, records them, and
+// changes the boot ID on reboot.
+type rebootingSSHCommander struct {
+	responses map[string]string
+	bootID    string
+	commands  []string
+}
+
+func (c *rebootingSSHCommander) SSHCommand(args string) (string, error) {
+	c.commands = append(c.commands, args)
+
+	switch args {
+	case testBootIDCommand:
+		return c.bootID, nil
+	case testRebootCommand:
+		c.bootID += "-rebooted"
+		return "", errors.New("connection closed")
+	}
+
+	response, ok := c.responses[args]
+	if !ok {
+		return "", errors.New("Command not registered in rebootingSSHCommander")
+	}
+	return response, nil
+}
+
+func TestFedoraCoreOSCompatibleWithHost(t *testing.T) {
+	assert.Equal(t, []string{"FedoraCoreOS"}, compatibleProvisioners(t, fedoraCoreOS))
+	assert.Equal(t, []string{"Fedora"}, compatibleProvisioners(t, fedoraServer))
+}
+
+func TestFedoraCoreOSPackageUnchanged(t *testing.T) {
+	commander := &rebootingSSHCommander{
+		responses: map[string]string{
+			"sudo rpm-ostree install --idempotent --allow-inactive podman --unchanged-exit-77 && echo 'rpm-ostree: changed' || [ $? -eq 77 ]": "",
+		},
+		bootID: "boot",
+	}
+	p := NewFedoraCoreOSProvisioner(&fakedriver.Driver{}).(*FedoraCoreOSProvisioner)
+	p.SSHCommander = commander
+
+	assert.NoError(t, p.Package("podman", pkgaction.Install))
+	assert.NotContains(t, commander.commands, testRebootCommand)
+}
+
+func TestFedoraCoreOSPackageReboots(t *testing.T) {
+	commander := &rebootingSSHCommander{
+		responses: map[string]string{
+			"sudo rpm-ostree upgrade --unchanged-exit-77 && echo 'rpm-ostree: changed' || [ $? -eq 77 ]": "Staging deployment...done\nrpm-ostree: changed\n",
+		},
+		bootID: "boot",
+	}
+	p := NewFedoraCoreOSProvisioner(&fakedriver.Driver{}).(*FedoraCoreOSProvisioner)
+	p.SSHCommander = commander
+
+	assert.NoError(t, p.Package("podman", pkgaction.Upgrade))
+	assert.Equal(t, []string{
+		"sudo rpm-ostree upgrade --unchanged-exit-77 && echo 'rpm-ostree: changed' || [ $? -eq 77 ]",
+		testBootIDCommand,
+		testRebootCommand,
+		testBootIDCommand,
+	}, commander.commands)
+	assert.Equal(t, "boot-rebooted", commander.bootID)
+}
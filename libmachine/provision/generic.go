@@ -30,6 +30,10 @@ func (sshCmder GenericSSHCommander) SSHCommand(args string) (string, error) {
 	return drivers.RunSSHCommandFromDriver(sshCmder.Driver, args)
 }
 
+func (provisioner *GenericProvisioner) setSSHCommander(sshCommander SSHCommander) {
+	provisioner.SSHCommander = sshCommander
+}
+
 func (provisioner *GenericProvisioner) Hostname() (string, error) {
 	return provisioner.SSHCommand("hostname")
 }
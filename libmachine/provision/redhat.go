@@ -120,6 +120,10 @@ func (provisioner *RedHatProvisioner) Provision(authOptions auth.Options, engine
 		return err
 	}
 
+	if err := ConfigureContainers(provisioner, engineOptions); err != nil {
+		return err
+	}
+
 	return err
 }
 
@@ -2,6 +2,7 @@ package provision
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/boot2podman/machine/libmachine/drivers"
 	"github.com/boot2podman/machine/libmachine/log"
@@ -43,3 +44,19 @@ output  : %s`, args, err, output)
 
 	return output, nil
 }
+
+// TransferFile streams the file to a temporary file on the machine, without a
+// terminal, and installs it with sudo, in a terminal.
+func (sshCmder RedHatSSHCommander) TransferFile(content []byte, remotePath string, mode os.FileMode, owner string) error {
+	client, err := drivers.GetSSHClientFromDriver(sshCmder.Driver)
+	if err != nil {
+		return err
+	}
+
+	inputClient, ok := client.(ssh.InputClient)
+	if !ok {
+		return ErrFileTransferNotSupported
+	}
+
+	return transferStagedFile(inputClient, sshCmder, content, remotePath, mode, owner)
+}
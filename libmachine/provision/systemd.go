@@ -82,3 +82,20 @@ func (p *SystemdProvisioner) Service(name string, action serviceaction.ServiceAc
 
 	return nil
 }
+
+// podmanSocket is the systemd unit of the Podman varlink socket.
+const podmanSocket = "io.podman.socket"
+
+// enablePodmanSocket enables and starts the Podman varlink socket, so that
+// it is also listening after a reboot.
+func (p *SystemdProvisioner) enablePodmanSocket() error {
+	if err := p.Service(podmanSocket, serviceaction.Enable); err != nil {
+		return err
+	}
+
+	if err := p.Service(podmanSocket, serviceaction.Start); err != nil {
+		return err
+	}
+
+	return WaitForPodman(p)
+}
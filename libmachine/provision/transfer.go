@@ -0,0 +1,113 @@
+package provision
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/drivers"
+	"github.com/boot2podman/machine/libmachine/log"
+	"github.com/boot2podman/machine/libmachine/ssh"
+)
+
+// ErrFileTransferNotSupported is returned by the FileTransferers which can't
+// stream files to the machine, because their SSH client can't pass input.
+var ErrFileTransferNotSupported = errors.New("file transfer not supported")
+
+// FileTransferer is implemented by the SSH commanders and provisioners which
+// can write files on the machine with their content streamed on the standard
+// input of the SSH session, so that it doesn't show on command lines.
+type FileTransferer interface {
+	// TransferFile writes content to remotePath, with mode and owner, such
+	// as "root:root".
+	TransferFile(content []byte, remotePath string, mode os.FileMode, owner string) error
+}
+
+// installFileCommand returns the command writing its standard input to
+// remotePath. The mode and owner are set on a temporary file before it is
+// moved in place, so that the content is never readable by others.
+func installFileCommand(remotePath string, mode os.FileMode, owner string) string {
+	return fmt.Sprintf(`sudo sh -c 'umask 077 && cat > "$1.tmp" && chown %s "$1.tmp" && chmod %04o "$1.tmp" && mv "$1.tmp" "$1"' - %s`,
+		owner, mode.Perm(), ssh.QuoteArg(remotePath))
+}
+
+// stageFileCommand writes its standard input to a temporary file only
+// readable by the user, and prints its path. It doesn't need sudo, so it
+// runs without a terminal.
+const stageFileCommand = `umask 077 && f=$(mktemp) && cat > "$f" && echo "$f"`
+
+// installStagedFileCommand returns the command copying the staged file to
+// remotePath like installFileCommand, and removing it. The file is copied
+// rather than moved, so that it gets the security context of its directory.
+func installStagedFileCommand(stagedPath, remotePath string, mode os.FileMode, owner string) string {
+	return fmt.Sprintf(`sudo sh -c 'umask 077 && cat "$1" > "$2.tmp" && chown %s "$2.tmp" && chmod %04o "$2.tmp" && mv "$2.tmp" "$2"' - %s %s; s=$?; rm -f %s; exit $s`,
+		owner, mode.Perm(), ssh.QuoteArg(stagedPath), ssh.QuoteArg(remotePath), ssh.QuoteArg(stagedPath))
+}
+
+// transferStagedFile streams content to a temporary file with inputClient,
+// and installs it at remotePath with sshCmder, for the guests whose sudo
+// needs a terminal, which can't be given with input.
+func transferStagedFile(inputClient ssh.InputClient, sshCmder SSHCommander, content []byte, remotePath string, mode os.FileMode, owner string) error {
+	log.Debugf("About to transfer %s over SSH", remotePath)
+
+	output, err := inputClient.OutputWithInput(stageFileCommand, bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf(`ssh file transfer error:
+file    : %s
+err     : %v
+output  : %s`, remotePath, err, output)
+	}
+
+	// The path is the last line, after what the login shell may print
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	stagedPath := lines[len(lines)-1]
+
+	_, err = sshCmder.SSHCommand(installStagedFileCommand(stagedPath, remotePath, mode, owner))
+	return err
+}
+
+func (sshCmder GenericSSHCommander) TransferFile(content []byte, remotePath string, mode os.FileMode, owner string) error {
+	client, err := drivers.GetSSHClientFromDriver(sshCmder.Driver)
+	if err != nil {
+		return err
+	}
+
+	inputClient, ok := client.(ssh.InputClient)
+	if !ok {
+		return ErrFileTransferNotSupported
+	}
+
+	log.Debugf("About to transfer %s over SSH", remotePath)
+
+	output, err := inputClient.OutputWithInput(installFileCommand(remotePath, mode, owner), bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf(`ssh file transfer error:
+file    : %s
+err     : %v
+output  : %s`, remotePath, err, output)
+	}
+
+	return nil
+}
+
+// TransferFile streams the file with the SSH commander of the provisioner,
+// if it can.
+func (provisioner *GenericProvisioner) TransferFile(content []byte, remotePath string, mode os.FileMode, owner string) error {
+	if transferer, ok := provisioner.SSHCommander.(FileTransferer); ok {
+		return transferer.TransferFile(content, remotePath, mode, owner)
+	}
+	return ErrFileTransferNotSupported
+}
+
+// transferFile writes content to remotePath on the machine of p, with mode
+// and owner. The content is only ever streamed, never given on a command
+// line, so ErrFileTransferNotSupported is returned when p can't stream it.
+func transferFile(p SSHCommander, content []byte, remotePath string, mode os.FileMode, owner string) error {
+	transferer, ok := p.(FileTransferer)
+	if !ok {
+		return ErrFileTransferNotSupported
+	}
+	return transferer.TransferFile(content, remotePath, mode, owner)
+}
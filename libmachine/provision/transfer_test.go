@@ -0,0 +1,97 @@
+package provision
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boot2podman/machine/drivers/fakedriver"
+	"github.com/boot2podman/machine/libmachine/provision/provisiontest"
+	"github.com/boot2podman/machine/libmachine/ssh"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeInputClient struct {
+	ssh.Client
+	inputs map[string]string
+}
+
+func (c *fakeInputClient) OutputWithInput(command string, input io.Reader) (string, error) {
+	content, err := ioutil.ReadAll(input)
+	if err != nil {
+		return "", err
+	}
+	c.inputs[command] = string(content)
+	return "/tmp/tmp.abc123\n", nil
+}
+
+type fakeFileTransferer struct {
+	provisiontest.FakeSSHCommander
+	files map[string]string
+	err   error
+}
+
+func (f *fakeFileTransferer) TransferFile(content []byte, remotePath string, mode os.FileMode, owner string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.files[remotePath] = string(content)
+	return nil
+}
+
+func TestTransferFileStreams(t *testing.T) {
+	transferer := &fakeFileTransferer{files: map[string]string{}}
+	p := &fakeProvisioner{GenericProvisioner{
+		SSHCommander: transferer,
+		Driver:       &fakedriver.Driver{},
+	}}
+
+	err := transferFile(p, []byte("secret"), "/etc/key.pem", 0600, "root:root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"/etc/key.pem": "secret"}, transferer.files)
+}
+
+func TestTransferFileError(t *testing.T) {
+	p := &fakeProvisioner{GenericProvisioner{
+		SSHCommander: &fakeFileTransferer{err: errors.New("connection lost")},
+		Driver:       &fakedriver.Driver{},
+	}}
+
+	err := transferFile(p, []byte("secret"), "/etc/key.pem", 0600, "root:root")
+
+	assert.EqualError(t, err, "connection lost")
+}
+
+func TestTransferFileNotSupported(t *testing.T) {
+	p := &fakeProvisioner{GenericProvisioner{
+		SSHCommander: &provisiontest.FakeSSHCommander{},
+		Driver:       &fakedriver.Driver{},
+	}}
+
+	// The content is never given on a command line instead
+	err := transferFile(p, []byte("secret"), "/etc/key.pem", 0600, "root:root")
+
+	assert.Equal(t, ErrFileTransferNotSupported, err)
+}
+
+func TestInstallFileCommand(t *testing.T) {
+	assert.Equal(t, `sudo sh -c 'umask 077 && cat > "$1.tmp" && chown root:wheel "$1.tmp" && chmod 0600 "$1.tmp" && mv "$1.tmp" "$1"' - '/etc/it'\''s key.pem'`,
+		installFileCommand("/etc/it's key.pem", 0600, "root:wheel"))
+}
+
+func TestTransferStagedFile(t *testing.T) {
+	client := &fakeInputClient{inputs: map[string]string{}}
+	sshCmder := &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			`sudo sh -c 'umask 077 && cat "$1" > "$2.tmp" && chown root:root "$2.tmp" && chmod 0600 "$2.tmp" && mv "$2.tmp" "$2"' - '/tmp/tmp.abc123' '/etc/key.pem'; s=$?; rm -f '/tmp/tmp.abc123'; exit $s`: "",
+		},
+	}
+
+	err := transferStagedFile(client, sshCmder, []byte("secret"), "/etc/key.pem", 0600, "root:root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{stageFileCommand: "secret"}, client.inputs)
+}
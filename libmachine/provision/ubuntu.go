@@ -0,0 +1,25 @@
+package provision
+
+import (
+	"github.com/boot2podman/machine/libmachine/drivers"
+)
+
+func init() {
+	Register("Ubuntu", &RegisteredProvisioner{
+		New: NewUbuntuProvisioner,
+	})
+}
+
+func NewUbuntuProvisioner(d drivers.Driver) Provisioner {
+	return &UbuntuProvisioner{
+		NewAptProvisioner("ubuntu", d),
+	}
+}
+
+type UbuntuProvisioner struct {
+	*AptProvisioner
+}
+
+func (provisioner *UbuntuProvisioner) String() string {
+	return "ubuntu"
+}
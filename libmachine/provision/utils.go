@@ -117,20 +117,16 @@ func ConfigureAuth(p Provisioner) error {
 
 	log.Info("Copying certs to the remote machine...")
 
-	// printf will choke if we don't pass a format string because of the
-	// dashes, so that's the reason for the '%%s'
-	certTransferCmdFmt := "printf '%%s' '%s' | sudo tee %s"
-
 	// These ones are for Jessie and Mike <3 <3 <3
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(caCert), authOptions.CaCertRemotePath)); err != nil {
+	if err := transferFile(p, caCert, authOptions.CaCertRemotePath, 0644, "root:root"); err != nil {
 		return err
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverCert), authOptions.ServerCertRemotePath)); err != nil {
+	if err := transferFile(p, serverCert, authOptions.ServerCertRemotePath, 0644, "root:root"); err != nil {
 		return err
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverKey), authOptions.ServerKeyRemotePath)); err != nil {
+	if err := transferFile(p, serverKey, authOptions.ServerKeyRemotePath, 0600, "root:root"); err != nil {
 		return err
 	}
 
@@ -196,7 +192,7 @@ func getFilesystemType(p Provisioner, directory string) (string, error) {
 	return fstype, nil
 }
 
-func checkDaemonUp(p Provisioner) func() bool {
+func checkDaemonUp(p SSHCommander) func() bool {
 	return func() bool {
 		// HACK: Check to see if anyone's listening on the Podman varlink API socket.
 		_, err := p.SSHCommand("sudo test -S /run/podman/io.podman")
@@ -209,7 +205,7 @@ func checkDaemonUp(p Provisioner) func() bool {
 	}
 }
 
-func WaitForPodman(p Provisioner) error {
+func WaitForPodman(p SSHCommander) error {
 	if err := mcnutils.WaitForSpecific(checkDaemonUp(p), 10, 3*time.Second); err != nil {
 		return NewErrDaemonAvailable(err)
 	}
@@ -0,0 +1,96 @@
+package sftp
+
+import (
+	"os"
+	"time"
+)
+
+// File type bits of the permissions attribute, as in stat(2).
+const (
+	modeFIFO    = 0010000
+	modeChar    = 0020000
+	modeDir     = 0040000
+	modeBlock   = 0060000
+	modeRegular = 0100000
+	modeSymlink = 0120000
+	modeSocket  = 0140000
+
+	modeSetuid = 04000
+	modeSetgid = 02000
+	modeSticky = 01000
+)
+
+type fileAttrs struct {
+	flags       uint32
+	size        uint64
+	uid         uint32
+	gid         uint32
+	permissions uint32
+	atime       uint32
+	mtime       uint32
+}
+
+// attrsFromFileInfo returns the attributes of a file. Owners are not
+// reported, as host ids mean nothing on the machine.
+func attrsFromFileInfo(fi os.FileInfo) fileAttrs {
+	mtime := uint32(fi.ModTime().Unix())
+	return fileAttrs{
+		flags:       attrSize | attrPermissions | attrACModTime,
+		size:        uint64(fi.Size()),
+		permissions: unixMode(fi.Mode()),
+		atime:       mtime,
+		mtime:       mtime,
+	}
+}
+
+func unixMode(m os.FileMode) uint32 {
+	mode := uint32(m.Perm())
+
+	switch {
+	case m&os.ModeDir != 0:
+		mode |= modeDir
+	case m&os.ModeSymlink != 0:
+		mode |= modeSymlink
+	case m&os.ModeNamedPipe != 0:
+		mode |= modeFIFO
+	case m&os.ModeSocket != 0:
+		mode |= modeSocket
+	case m&os.ModeCharDevice != 0:
+		mode |= modeChar
+	case m&os.ModeDevice != 0:
+		mode |= modeBlock
+	default:
+		mode |= modeRegular
+	}
+
+	if m&os.ModeSetuid != 0 {
+		mode |= modeSetuid
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= modeSetgid
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= modeSticky
+	}
+
+	return mode
+}
+
+// fileMode returns the permission bits of a permissions attribute.
+func fileMode(permissions uint32) os.FileMode {
+	mode := os.FileMode(permissions & 0777)
+	if permissions&modeSetuid != 0 {
+		mode |= os.ModeSetuid
+	}
+	if permissions&modeSetgid != 0 {
+		mode |= os.ModeSetgid
+	}
+	if permissions&modeSticky != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+func (a fileAttrs) modTimes() (time.Time, time.Time) {
+	return time.Unix(int64(a.atime), 0), time.Unix(int64(a.mtime), 0)
+}
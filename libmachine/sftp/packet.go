@@ -0,0 +1,219 @@
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Packet types of SFTP version 3, see draft-ietf-secsh-filexfer-02.
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpWrite    = 6
+	fxpLstat    = 7
+	fxpFstat    = 8
+	fxpSetstat  = 9
+	fxpFsetstat = 10
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpRemove   = 13
+	fxpMkdir    = 14
+	fxpRmdir    = 15
+	fxpRealpath = 16
+	fxpStat     = 17
+	fxpRename   = 18
+	fxpReadlink = 19
+	fxpSymlink  = 20
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+	fxpExtended = 200
+)
+
+// Status codes.
+const (
+	fxOK               = 0
+	fxEOF              = 1
+	fxNoSuchFile       = 2
+	fxPermissionDenied = 3
+	fxFailure          = 4
+	fxBadMessage       = 5
+	fxOpUnsupported    = 8
+)
+
+// Flags of OPEN requests.
+const (
+	fxfRead   = 0x01
+	fxfWrite  = 0x02
+	fxfAppend = 0x04
+	fxfCreat  = 0x08
+	fxfTrunc  = 0x10
+	fxfExcl   = 0x20
+)
+
+// Flags of file attributes.
+const (
+	attrSize        = 0x01
+	attrUIDGID      = 0x02
+	attrPermissions = 0x04
+	attrACModTime   = 0x08
+	attrExtended    = 0x80000000
+)
+
+// maxPacketSize bounds the packets accepted from the client, which are at
+// most a few times the size of a read or write.
+const maxPacketSize = 1 << 20
+
+var errShortPacket = errors.New("sftp: packet too short")
+
+func readPacket(r io.Reader) (byte, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 || length > maxPacketSize {
+		return 0, nil, errors.New("sftp: invalid packet length")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+
+	return data[0], data[1:], nil
+}
+
+// decoder reads the fields of a packet payload. The first error is kept,
+// and every later read returns zero values.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) uint32() uint32 {
+	if d.err != nil || len(d.data) < 4 {
+		d.err = errShortPacket
+		return 0
+	}
+	v := binary.BigEndian.Uint32(d.data)
+	d.data = d.data[4:]
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	if d.err != nil || len(d.data) < 8 {
+		d.err = errShortPacket
+		return 0
+	}
+	v := binary.BigEndian.Uint64(d.data)
+	d.data = d.data[8:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uint32()
+	if d.err != nil || uint32(len(d.data)) < n {
+		d.err = errShortPacket
+		return nil
+	}
+	v := d.data[:n]
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) attrs() fileAttrs {
+	var a fileAttrs
+	a.flags = d.uint32()
+	if a.flags&attrSize != 0 {
+		a.size = d.uint64()
+	}
+	if a.flags&attrUIDGID != 0 {
+		a.uid = d.uint32()
+		a.gid = d.uint32()
+	}
+	if a.flags&attrPermissions != 0 {
+		a.permissions = d.uint32()
+	}
+	if a.flags&attrACModTime != 0 {
+		a.atime = d.uint32()
+		a.mtime = d.uint32()
+	}
+	if a.flags&attrExtended != 0 {
+		for n := d.uint32(); n > 0 && d.err == nil; n-- {
+			d.string()
+			d.string()
+		}
+	}
+	return a
+}
+
+// encoder builds a packet, starting with room for its length.
+type encoder struct {
+	buf []byte
+}
+
+func newPacket(packetType byte, id uint32) *encoder {
+	e := &encoder{buf: make([]byte, 4, 64)}
+	e.byte(packetType)
+	e.uint32(id)
+	return e
+}
+
+func (e *encoder) byte(v byte) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.uint32(uint32(v >> 32))
+	e.uint32(uint32(v))
+}
+
+func (e *encoder) bytes(v []byte) {
+	e.uint32(uint32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) string(v string) {
+	e.uint32(uint32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) attrs(a fileAttrs) {
+	e.uint32(a.flags)
+	if a.flags&attrSize != 0 {
+		e.uint64(a.size)
+	}
+	if a.flags&attrUIDGID != 0 {
+		e.uint32(a.uid)
+		e.uint32(a.gid)
+	}
+	if a.flags&attrPermissions != 0 {
+		e.uint32(a.permissions)
+	}
+	if a.flags&attrACModTime != 0 {
+		e.uint32(a.atime)
+		e.uint32(a.mtime)
+	}
+}
+
+// packet returns the encoded packet with its length filled in.
+func (e *encoder) packet() []byte {
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+	return e.buf
+}
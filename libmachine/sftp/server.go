@@ -0,0 +1,605 @@
+// Package sftp implements a server for version 3 of the SSH File Transfer
+// Protocol, as spoken by OpenSSH and sshfs.
+//
+// The server exports a single directory of the host, which clients see as
+// their root directory. Paths cannot climb above it, symbolic links are only
+// followed when they resolve inside it, and clients can only create relative
+// links which stay below the directory they are in.
+package sftp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/boot2podman/machine/libmachine/log"
+)
+
+const (
+	protocolVersion = 3
+
+	// readdirBatch is the number of entries returned per READDIR.
+	readdirBatch = 128
+
+	// maxReadSize bounds the data returned per READ.
+	maxReadSize = 1 << 16
+)
+
+// Server serves a directory of the host over SFTP.
+type Server struct {
+	root string
+}
+
+// NewServer returns a server exporting the directory root.
+func NewServer(root string) (*Server, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	// Resolved paths are compared with the root, which must be resolved
+	// as well.
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	return &Server{root: root}, nil
+}
+
+// Root returns the directory served.
+func (s *Server) Root() string {
+	return s.root
+}
+
+// Serve runs a session with a client connected over rw, until the client
+// goes away. Requests are handled in order.
+func (s *Server) Serve(rw io.ReadWriter) error {
+	session := &session{
+		server:  s,
+		w:       rw,
+		handles: map[string]*os.File{},
+	}
+	defer session.closeHandles()
+
+	for {
+		packetType, data, err := readPacket(rw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := session.handle(packetType, data); err != nil {
+			return err
+		}
+	}
+}
+
+type session struct {
+	server     *Server
+	w          io.Writer
+	handles    map[string]*os.File
+	nextHandle uint64
+}
+
+// contains reports whether the resolved local path is the root or below it.
+func (s *Server) contains(local string) bool {
+	rel, err := filepath.Rel(s.root, local)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// localLinkPath maps a path of the client to the host, for the requests
+// acting on a symbolic link itself rather than on its target. Paths are
+// resolved against the root, and ".." does not climb above it. Links in the
+// parent directories are followed, and refused if they lead out of the root.
+func (s *session) localLinkPath(p string) (string, error) {
+	local := filepath.Join(s.server.root, filepath.FromSlash(path.Clean("/"+p)))
+	if local == s.server.root {
+		return local, nil
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(local))
+	if err != nil {
+		return "", err
+	}
+	if !s.server.contains(dir) {
+		return "", &os.PathError{Op: "resolve", Path: p, Err: os.ErrPermission}
+	}
+	return filepath.Join(dir, filepath.Base(local)), nil
+}
+
+// localPath maps a path of the client to the host, following all of its
+// symbolic links. Links leading out of the root are refused.
+func (s *session) localPath(p string) (string, error) {
+	local, err := s.localLinkPath(p)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(local)
+	if os.IsNotExist(err) {
+		// Files being created don't exist yet, but a dangling link would
+		// be followed to create its target, wherever it is.
+		if _, err := os.Lstat(local); err == nil {
+			return "", &os.PathError{Op: "resolve", Path: p, Err: os.ErrPermission}
+		}
+		return local, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !s.server.contains(resolved) {
+		return "", &os.PathError{Op: "resolve", Path: p, Err: os.ErrPermission}
+	}
+	return resolved, nil
+}
+
+// checkSymlinkTarget refuses the targets which could lead out of the root,
+// whatever directory the link is created in.
+func checkSymlinkTarget(target string) error {
+	if path.IsAbs(target) || filepath.IsAbs(target) {
+		return &os.PathError{Op: "symlink", Path: target, Err: os.ErrPermission}
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(target), "/") {
+		if elem == ".." {
+			return &os.PathError{Op: "symlink", Path: target, Err: os.ErrPermission}
+		}
+	}
+	return nil
+}
+
+func (s *session) send(e *encoder) error {
+	_, err := s.w.Write(e.packet())
+	return err
+}
+
+func (s *session) sendStatus(id uint32, code uint32, msg string) error {
+	p := newPacket(fxpStatus, id)
+	p.uint32(code)
+	p.string(msg)
+	p.string("")
+	return s.send(p)
+}
+
+// sendError reports err as a status. A nil error reports success.
+func (s *session) sendError(id uint32, err error) error {
+	switch {
+	case err == nil:
+		return s.sendStatus(id, fxOK, "Success")
+	case err == io.EOF:
+		return s.sendStatus(id, fxEOF, "End of file")
+	case os.IsNotExist(err):
+		return s.sendStatus(id, fxNoSuchFile, err.Error())
+	case os.IsPermission(err):
+		return s.sendStatus(id, fxPermissionDenied, err.Error())
+	}
+	return s.sendStatus(id, fxFailure, err.Error())
+}
+
+func (s *session) sendAttrs(id uint32, fi os.FileInfo, err error) error {
+	if err != nil {
+		return s.sendError(id, err)
+	}
+	p := newPacket(fxpAttrs, id)
+	p.attrs(attrsFromFileInfo(fi))
+	return s.send(p)
+}
+
+func (s *session) sendName(id uint32, name string, attrs fileAttrs) error {
+	p := newPacket(fxpName, id)
+	p.uint32(1)
+	p.string(name)
+	p.string(name)
+	p.attrs(attrs)
+	return s.send(p)
+}
+
+func (s *session) addHandle(f *os.File) string {
+	s.nextHandle++
+	handle := strconv.FormatUint(s.nextHandle, 10)
+	s.handles[handle] = f
+	return handle
+}
+
+func (s *session) closeHandles() {
+	for handle, f := range s.handles {
+		f.Close()
+		delete(s.handles, handle)
+	}
+}
+
+func (s *session) handle(packetType byte, data []byte) error {
+	d := &decoder{data: data}
+
+	if packetType == fxpInit {
+		// Extensions are neither offered nor accepted.
+		p := newPacket(fxpVersion, protocolVersion)
+		return s.send(p)
+	}
+
+	id := d.uint32()
+	if d.err != nil {
+		return d.err
+	}
+
+	switch packetType {
+	case fxpOpen:
+		name, pflags, attrs := d.string(), d.uint32(), d.attrs()
+		if d.err != nil {
+			break
+		}
+		return s.open(id, name, pflags, attrs)
+
+	case fxpOpendir:
+		name := d.string()
+		if d.err != nil {
+			break
+		}
+		return s.opendir(id, name)
+
+	case fxpClose:
+		handle := d.string()
+		if d.err != nil {
+			break
+		}
+		f, ok := s.handles[handle]
+		if !ok {
+			return s.sendStatus(id, fxFailure, "Invalid handle")
+		}
+		delete(s.handles, handle)
+		return s.sendError(id, f.Close())
+
+	case fxpRead:
+		handle, offset, length := d.string(), d.uint64(), d.uint32()
+		if d.err != nil {
+			break
+		}
+		return s.read(id, handle, offset, length)
+
+	case fxpWrite:
+		handle, offset, data := d.string(), d.uint64(), d.bytes()
+		if d.err != nil {
+			break
+		}
+		f, ok := s.handles[handle]
+		if !ok {
+			return s.sendStatus(id, fxFailure, "Invalid handle")
+		}
+		_, err := f.WriteAt(data, int64(offset))
+		return s.sendError(id, err)
+
+	case fxpReaddir:
+		handle := d.string()
+		if d.err != nil {
+			break
+		}
+		return s.readdir(id, handle)
+
+	case fxpStat:
+		name := d.string()
+		if d.err != nil {
+			break
+		}
+		local, err := s.localPath(name)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		fi, err := os.Stat(local)
+		return s.sendAttrs(id, fi, err)
+
+	case fxpLstat:
+		name := d.string()
+		if d.err != nil {
+			break
+		}
+		local, err := s.localLinkPath(name)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		fi, err := os.Lstat(local)
+		return s.sendAttrs(id, fi, err)
+
+	case fxpFstat:
+		handle := d.string()
+		if d.err != nil {
+			break
+		}
+		f, ok := s.handles[handle]
+		if !ok {
+			return s.sendStatus(id, fxFailure, "Invalid handle")
+		}
+		fi, err := f.Stat()
+		return s.sendAttrs(id, fi, err)
+
+	case fxpSetstat:
+		name, attrs := d.string(), d.attrs()
+		if d.err != nil {
+			break
+		}
+		local, err := s.localPath(name)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		return s.sendError(id, setAttrs(local, attrs))
+
+	case fxpFsetstat:
+		handle, attrs := d.string(), d.attrs()
+		if d.err != nil {
+			break
+		}
+		f, ok := s.handles[handle]
+		if !ok {
+			return s.sendStatus(id, fxFailure, "Invalid handle")
+		}
+		return s.sendError(id, setAttrs(f.Name(), attrs))
+
+	case fxpRemove:
+		name := d.string()
+		if d.err != nil {
+			break
+		}
+		local, err := s.localLinkPath(name)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		return s.sendError(id, removeFile(local))
+
+	case fxpMkdir:
+		name, attrs := d.string(), d.attrs()
+		if d.err != nil {
+			break
+		}
+		mode := os.FileMode(0755)
+		if attrs.flags&attrPermissions != 0 {
+			mode = fileMode(attrs.permissions)
+		}
+		local, err := s.localLinkPath(name)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		return s.sendError(id, os.Mkdir(local, mode))
+
+	case fxpRmdir:
+		name := d.string()
+		if d.err != nil {
+			break
+		}
+		local, err := s.localLinkPath(name)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		return s.sendError(id, removeDir(local))
+
+	case fxpRealpath:
+		name := d.string()
+		if d.err != nil {
+			break
+		}
+		return s.sendName(id, path.Clean("/"+name), fileAttrs{})
+
+	case fxpRename:
+		oldName, newName := d.string(), d.string()
+		if d.err != nil {
+			break
+		}
+		oldLocal, err := s.localLinkPath(oldName)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		newLocal, err := s.localLinkPath(newName)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		return s.sendError(id, os.Rename(oldLocal, newLocal))
+
+	case fxpReadlink:
+		name := d.string()
+		if d.err != nil {
+			break
+		}
+		local, err := s.localLinkPath(name)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		target, err := os.Readlink(local)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		return s.sendName(id, filepath.ToSlash(target), fileAttrs{})
+
+	case fxpSymlink:
+		// As in OpenSSH, the target comes before the path of the link.
+		target, name := d.string(), d.string()
+		if d.err != nil {
+			break
+		}
+		if err := checkSymlinkTarget(target); err != nil {
+			return s.sendError(id, err)
+		}
+		local, err := s.localLinkPath(name)
+		if err != nil {
+			return s.sendError(id, err)
+		}
+		return s.sendError(id, os.Symlink(filepath.FromSlash(target), local))
+
+	default:
+		log.Debugf("sftp: unsupported request %d", packetType)
+		return s.sendStatus(id, fxOpUnsupported, "Operation unsupported")
+	}
+
+	return s.sendStatus(id, fxBadMessage, d.err.Error())
+}
+
+func (s *session) open(id uint32, name string, pflags uint32, attrs fileAttrs) error {
+	flags := 0
+	switch {
+	case pflags&fxfRead != 0 && pflags&fxfWrite != 0:
+		flags = os.O_RDWR
+	case pflags&fxfWrite != 0:
+		flags = os.O_WRONLY
+	default:
+		flags = os.O_RDONLY
+	}
+	// Writes carry their offset, so appending is up to the client.
+	if pflags&fxfCreat != 0 {
+		flags |= os.O_CREATE
+	}
+	if pflags&fxfTrunc != 0 {
+		flags |= os.O_TRUNC
+	}
+	if pflags&fxfExcl != 0 {
+		flags |= os.O_EXCL
+	}
+
+	mode := os.FileMode(0644)
+	if attrs.flags&attrPermissions != 0 {
+		mode = fileMode(attrs.permissions)
+	}
+
+	local, err := s.localPath(name)
+	if err != nil {
+		return s.sendError(id, err)
+	}
+
+	f, err := os.OpenFile(local, flags, mode)
+	if err != nil {
+		return s.sendError(id, err)
+	}
+
+	p := newPacket(fxpHandle, id)
+	p.string(s.addHandle(f))
+	return s.send(p)
+}
+
+func (s *session) opendir(id uint32, name string) error {
+	local, err := s.localPath(name)
+	if err != nil {
+		return s.sendError(id, err)
+	}
+
+	f, err := os.Open(local)
+	if err != nil {
+		return s.sendError(id, err)
+	}
+
+	fi, err := f.Stat()
+	if err == nil && !fi.IsDir() {
+		err = fmt.Errorf("%s is not a directory", name)
+	}
+	if err != nil {
+		f.Close()
+		return s.sendError(id, err)
+	}
+
+	p := newPacket(fxpHandle, id)
+	p.string(s.addHandle(f))
+	return s.send(p)
+}
+
+func (s *session) read(id uint32, handle string, offset uint64, length uint32) error {
+	f, ok := s.handles[handle]
+	if !ok {
+		return s.sendStatus(id, fxFailure, "Invalid handle")
+	}
+
+	if length > maxReadSize {
+		length = maxReadSize
+	}
+
+	buf := make([]byte, length)
+	n, err := f.ReadAt(buf, int64(offset))
+	if n == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return s.sendError(id, err)
+	}
+
+	p := newPacket(fxpData, id)
+	p.bytes(buf[:n])
+	return s.send(p)
+}
+
+func (s *session) readdir(id uint32, handle string) error {
+	f, ok := s.handles[handle]
+	if !ok {
+		return s.sendStatus(id, fxFailure, "Invalid handle")
+	}
+
+	entries, err := f.Readdir(readdirBatch)
+	if len(entries) == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return s.sendError(id, err)
+	}
+
+	p := newPacket(fxpName, id)
+	p.uint32(uint32(len(entries)))
+	for _, fi := range entries {
+		p.string(fi.Name())
+		p.string(longName(fi))
+		p.attrs(attrsFromFileInfo(fi))
+	}
+	return s.send(p)
+}
+
+// longName formats an entry the way `ls -l` does, which clients only show.
+func longName(fi os.FileInfo) string {
+	return fmt.Sprintf("%s 1 0 0 %d %s %s", fi.Mode(), fi.Size(), fi.ModTime().Format("Jan _2 15:04"), fi.Name())
+}
+
+func setAttrs(name string, a fileAttrs) error {
+	if a.flags&attrSize != 0 {
+		if err := os.Truncate(name, int64(a.size)); err != nil {
+			return err
+		}
+	}
+	if a.flags&attrPermissions != 0 {
+		if err := os.Chmod(name, fileMode(a.permissions)); err != nil {
+			return err
+		}
+	}
+	if a.flags&attrACModTime != 0 {
+		atime, mtime := a.modTimes()
+		if err := os.Chtimes(name, atime, mtime); err != nil {
+			return err
+		}
+	}
+	// Owners are ignored, the files belong to the user running the server.
+	return nil
+}
+
+func removeFile(name string) error {
+	fi, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s is a directory", name)
+	}
+	return os.Remove(name)
+}
+
+func removeDir(name string) error {
+	fi, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", name)
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,340 @@
+package sftp
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testClient speaks just enough SFTP to exercise the server.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	nextID uint32
+}
+
+func newTestClient(t *testing.T) (*testClient, string, func()) {
+	root, err := ioutil.TempDir("", "sftp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewServer(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn, clientConn := net.Pipe()
+	go server.Serve(serverConn)
+
+	c := &testClient{t: t, conn: clientConn}
+
+	init := &encoder{buf: make([]byte, 4)}
+	init.byte(fxpInit)
+	init.uint32(protocolVersion)
+	packetType, data := c.roundTrip(init)
+	assert.Equal(t, byte(fxpVersion), packetType)
+	assert.Equal(t, uint32(protocolVersion), (&decoder{data: data}).uint32())
+
+	return c, root, func() {
+		clientConn.Close()
+		os.RemoveAll(root)
+	}
+}
+
+func (c *testClient) request(packetType byte) *encoder {
+	c.nextID++
+	return newPacket(packetType, c.nextID)
+}
+
+func (c *testClient) roundTrip(e *encoder) (byte, []byte) {
+	if _, err := c.conn.Write(e.packet()); err != nil {
+		c.t.Fatal(err)
+	}
+	packetType, data, err := readPacket(c.conn)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return packetType, data
+}
+
+// reply sends a request and decodes the id of its reply.
+func (c *testClient) reply(e *encoder) (byte, *decoder) {
+	packetType, data := c.roundTrip(e)
+	d := &decoder{data: data}
+	if packetType != fxpVersion {
+		assert.Equal(c.t, c.nextID, d.uint32())
+	}
+	return packetType, d
+}
+
+func (c *testClient) status(e *encoder) uint32 {
+	packetType, d := c.reply(e)
+	assert.Equal(c.t, byte(fxpStatus), packetType)
+	return d.uint32()
+}
+
+func (c *testClient) handle(e *encoder) string {
+	packetType, d := c.reply(e)
+	if !assert.Equal(c.t, byte(fxpHandle), packetType) {
+		c.t.FailNow()
+	}
+	return d.string()
+}
+
+func TestServerWriteAndRead(t *testing.T) {
+	c, root, cleanup := newTestClient(t)
+	defer cleanup()
+
+	open := c.request(fxpOpen)
+	open.string("/hello.txt")
+	open.uint32(fxfWrite | fxfCreat | fxfTrunc)
+	open.uint32(attrPermissions)
+	open.uint32(0600)
+	handle := c.handle(open)
+
+	write := c.request(fxpWrite)
+	write.string(handle)
+	write.uint64(0)
+	write.string("hello world")
+	assert.Equal(t, uint32(fxOK), c.status(write))
+
+	closeHandle := c.request(fxpClose)
+	closeHandle.string(handle)
+	assert.Equal(t, uint32(fxOK), c.status(closeHandle))
+
+	content, err := ioutil.ReadFile(filepath.Join(root, "hello.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(content))
+
+	open = c.request(fxpOpen)
+	open.string("hello.txt")
+	open.uint32(fxfRead)
+	open.uint32(0)
+	handle = c.handle(open)
+
+	read := c.request(fxpRead)
+	read.string(handle)
+	read.uint64(6)
+	read.uint32(100)
+	packetType, d := c.reply(read)
+	assert.Equal(t, byte(fxpData), packetType)
+	assert.Equal(t, "world", d.string())
+
+	read = c.request(fxpRead)
+	read.string(handle)
+	read.uint64(11)
+	read.uint32(100)
+	assert.Equal(t, uint32(fxEOF), c.status(read))
+
+	fstat := c.request(fxpFstat)
+	fstat.string(handle)
+	packetType, d = c.reply(fstat)
+	assert.Equal(t, byte(fxpAttrs), packetType)
+	attrs := d.attrs()
+	assert.Equal(t, uint64(11), attrs.size)
+	assert.Equal(t, uint32(modeRegular|0600), attrs.permissions)
+}
+
+func TestServerDirectories(t *testing.T) {
+	c, root, cleanup := newTestClient(t)
+	defer cleanup()
+
+	mkdir := c.request(fxpMkdir)
+	mkdir.string("/src")
+	mkdir.uint32(0)
+	assert.Equal(t, uint32(fxOK), c.status(mkdir))
+
+	for _, name := range []string{"a", "b"} {
+		if err := ioutil.WriteFile(filepath.Join(root, "src", name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opendir := c.request(fxpOpendir)
+	opendir.string("/src")
+	handle := c.handle(opendir)
+
+	readdir := c.request(fxpReaddir)
+	readdir.string(handle)
+	packetType, d := c.reply(readdir)
+	assert.Equal(t, byte(fxpName), packetType)
+
+	names := []string{}
+	for n := d.uint32(); n > 0; n-- {
+		names = append(names, d.string())
+		d.string()
+		d.attrs()
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"a", "b"}, names)
+
+	readdir = c.request(fxpReaddir)
+	readdir.string(handle)
+	assert.Equal(t, uint32(fxEOF), c.status(readdir))
+
+	rename := c.request(fxpRename)
+	rename.string("/src/a")
+	rename.string("/src/c")
+	assert.Equal(t, uint32(fxOK), c.status(rename))
+
+	remove := c.request(fxpRemove)
+	remove.string("/src/c")
+	assert.Equal(t, uint32(fxOK), c.status(remove))
+
+	rmdir := c.request(fxpRmdir)
+	rmdir.string("/src")
+	assert.Equal(t, uint32(fxFailure), c.status(rmdir))
+
+	stat := c.request(fxpStat)
+	stat.string("/src/a")
+	assert.Equal(t, uint32(fxNoSuchFile), c.status(stat))
+}
+
+func TestServerStaysInRoot(t *testing.T) {
+	c, root, cleanup := newTestClient(t)
+	defer cleanup()
+
+	realpath := c.request(fxpRealpath)
+	realpath.string("../../etc/..")
+	packetType, d := c.reply(realpath)
+	assert.Equal(t, byte(fxpName), packetType)
+	assert.Equal(t, uint32(1), d.uint32())
+	assert.Equal(t, "/", d.string())
+
+	mkdir := c.request(fxpMkdir)
+	mkdir.string("../../escaped")
+	mkdir.uint32(0)
+	assert.Equal(t, uint32(fxOK), c.status(mkdir))
+
+	_, err := os.Stat(filepath.Join(root, "escaped"))
+	assert.NoError(t, err)
+}
+
+func TestServerSymlinks(t *testing.T) {
+	c, root, cleanup := newTestClient(t)
+	defer cleanup()
+
+	symlink := c.request(fxpSymlink)
+	symlink.string("target")
+	symlink.string("/link")
+	assert.Equal(t, uint32(fxOK), c.status(symlink))
+
+	target, err := os.Readlink(filepath.Join(root, "link"))
+	assert.NoError(t, err)
+	assert.Equal(t, "target", target)
+
+	readlink := c.request(fxpReadlink)
+	readlink.string("/link")
+	packetType, d := c.reply(readlink)
+	assert.Equal(t, byte(fxpName), packetType)
+	assert.Equal(t, uint32(1), d.uint32())
+	assert.Equal(t, "target", d.string())
+
+	lstat := c.request(fxpLstat)
+	lstat.string("/link")
+	packetType, d = c.reply(lstat)
+	assert.Equal(t, byte(fxpAttrs), packetType)
+	assert.Equal(t, uint32(modeSymlink), d.attrs().permissions&0170000)
+}
+
+func TestServerSymlinksStayInRoot(t *testing.T) {
+	c, root, cleanup := newTestClient(t)
+	defer cleanup()
+
+	outside, err := ioutil.TempDir("", "sftp-outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+	if err := ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Clients cannot create links leading out of the root
+	for _, target := range []string{outside, "/etc/passwd", "../secret", "a/../../secret"} {
+		symlink := c.request(fxpSymlink)
+		symlink.string(target)
+		symlink.string("/escape")
+		assert.Equal(t, uint32(fxPermissionDenied), c.status(symlink), target)
+	}
+
+	// and the ones already in the directory are not followed out of it
+	if err := os.Symlink(outside, filepath.Join(root, "out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "created"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"/out/secret", "/out"} {
+		stat := c.request(fxpStat)
+		stat.string(name)
+		assert.Equal(t, uint32(fxPermissionDenied), c.status(stat), name)
+
+		setstat := c.request(fxpSetstat)
+		setstat.string(name)
+		setstat.uint32(attrPermissions)
+		setstat.uint32(0777)
+		assert.Equal(t, uint32(fxPermissionDenied), c.status(setstat), name)
+	}
+
+	for _, name := range []string{"/out/secret", "/dangling"} {
+		open := c.request(fxpOpen)
+		open.string(name)
+		open.uint32(fxfRead | fxfWrite | fxfCreat)
+		open.uint32(0)
+		assert.Equal(t, uint32(fxPermissionDenied), c.status(open), name)
+	}
+
+	opendir := c.request(fxpOpendir)
+	opendir.string("/out")
+	assert.Equal(t, uint32(fxPermissionDenied), c.status(opendir))
+
+	remove := c.request(fxpRemove)
+	remove.string("/out/secret")
+	assert.Equal(t, uint32(fxPermissionDenied), c.status(remove))
+
+	mkdir := c.request(fxpMkdir)
+	mkdir.string("/out/dir")
+	mkdir.uint32(0)
+	assert.Equal(t, uint32(fxPermissionDenied), c.status(mkdir))
+
+	// The links themselves can still be looked at and removed
+	lstat := c.request(fxpLstat)
+	lstat.string("/out")
+	packetType, _ := c.reply(lstat)
+	assert.Equal(t, byte(fxpAttrs), packetType)
+
+	remove = c.request(fxpRemove)
+	remove.string("/dangling")
+	assert.Equal(t, uint32(fxOK), c.status(remove))
+
+	secret, err := ioutil.ReadFile(filepath.Join(outside, "secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(secret))
+	fi, err := os.Stat(filepath.Join(outside, "secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	_, err = os.Stat(filepath.Join(outside, "created"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestServerUnsupported(t *testing.T) {
+	c, _, cleanup := newTestClient(t)
+	defer cleanup()
+
+	extended := c.request(fxpExtended)
+	extended.string("statvfs@openssh.com")
+	extended.string("/")
+	assert.Equal(t, uint32(fxOpUnsupported), c.status(extended))
+
+	closeHandle := c.request(fxpClose)
+	closeHandle.string("42")
+	assert.Equal(t, uint32(fxFailure), c.status(closeHandle))
+}
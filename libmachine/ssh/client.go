@@ -17,6 +17,12 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 )
 
+// QuoteArg single quotes s as an argument for the shell running the commands
+// on the machine.
+func QuoteArg(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
 type Client interface {
 	Output(command string) (string, error)
 	Shell(args ...string) error
@@ -34,6 +40,14 @@ type Client interface {
 	Wait() error
 }
 
+// InputClient is implemented by the clients which can pass input to the
+// commands they run, on their standard input.
+type InputClient interface {
+	// OutputWithInput runs command with input as its standard input, and
+	// returns its combined output.
+	OutputWithInput(command string, input io.Reader) (string, error)
+}
+
 type ExternalClient struct {
 	BaseArgs   []string
 	BinaryPath string
@@ -194,6 +208,20 @@ func (client *NativeClient) Output(command string) (string, error) {
 	return string(output), err
 }
 
+func (client *NativeClient) OutputWithInput(command string, input io.Reader) (string, error) {
+	conn, session, err := client.session(command)
+	if err != nil {
+		return "", err
+	}
+	defer closeConn(conn)
+	defer session.Close()
+
+	session.Stdin = input
+	output, err := session.CombinedOutput(command)
+
+	return string(output), err
+}
+
 func (client *NativeClient) OutputWithPty(command string) (string, error) {
 	conn, session, err := client.session(command)
 	if err != nil {
@@ -388,6 +416,14 @@ func (client *ExternalClient) Output(command string) (string, error) {
 	return string(output), err
 }
 
+func (client *ExternalClient) OutputWithInput(command string, input io.Reader) (string, error) {
+	args := append(client.BaseArgs, command)
+	cmd := getSSHCmd(client.BinaryPath, args...)
+	cmd.Stdin = input
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
 func (client *ExternalClient) Shell(args ...string) error {
 	args = append(client.BaseArgs, args...)
 	cmd := getSSHCmd(client.BinaryPath, args...)
@@ -0,0 +1,258 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/boot2podman/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// Forwarder keeps a single SSH connection to a host, over which it runs
+// commands and forwards local ports to addresses reachable from the host, as
+// `ssh -L` does. The connection is re-established when it breaks.
+type Forwarder struct {
+	newClient func() (*NativeClient, error)
+
+	mu        sync.Mutex
+	conn      *ssh.Client
+	listeners map[string]net.Listener
+}
+
+func NewForwarder(client *NativeClient) *Forwarder {
+	return NewReconnectingForwarder(func() (*NativeClient, error) {
+		return client, nil
+	})
+}
+
+// NewReconnectingForwarder returns a Forwarder that gets a new client every
+// time it connects, e.g. to pick up an SSH port that changed when the machine
+// was restarted.
+func NewReconnectingForwarder(newClient func() (*NativeClient, error)) *Forwarder {
+	return &Forwarder{
+		newClient: newClient,
+		listeners: map[string]net.Listener{},
+	}
+}
+
+// connection returns the SSH connection, dialing it if needed.
+func (f *Forwarder) connection() (*ssh.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.conn != nil {
+		return f.conn, nil
+	}
+
+	client, err := f.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(client.Hostname, strconv.Itoa(client.Port)), &client.Config)
+	if err != nil {
+		return nil, err
+	}
+	f.conn = conn
+
+	go func() {
+		err := conn.Wait()
+		log.Debugf("SSH connection to %s closed: %v", client.Hostname, err)
+
+		f.mu.Lock()
+		if f.conn == conn {
+			f.conn = nil
+		}
+		f.mu.Unlock()
+	}()
+
+	return conn, nil
+}
+
+// Output runs command on the host and returns its standard output.
+func (f *Forwarder) Output(command string) (string, error) {
+	conn, err := f.connection()
+	if err != nil {
+		return "", err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	output, err := session.Output(command)
+	return string(output), err
+}
+
+// Endpoint is one end of a forward: a TCP address, or a unix socket path.
+type Endpoint struct {
+	Network string
+	Address string
+}
+
+func (e Endpoint) String() string {
+	return e.Address
+}
+
+// ParseTunnel parses a forward specification with the semantics of
+// `ssh -L`:
+//
+//	[bind_address:]port:host:hostport
+//	[bind_address:]port:remote_socket
+//	local_socket:host:hostport
+//	local_socket:remote_socket
+//
+// As a shorthand, port:hostport forwards to hostport on the loopback
+// interface of the host. Sockets are absolute paths, and local ports are
+// bound to the loopback interface unless a bind address is given.
+func ParseTunnel(spec string) (Endpoint, Endpoint, error) {
+	var local, remote Endpoint
+
+	parts := strings.Split(spec, ":")
+	switch {
+	case len(parts) > 1 && isSocketPath(parts[0]):
+		local, parts = Endpoint{"unix", parts[0]}, parts[1:]
+	case len(parts) > 1 && isPort(parts[0]):
+		local, parts = Endpoint{"tcp", net.JoinHostPort("127.0.0.1", parts[0])}, parts[1:]
+	case len(parts) > 2 && isPort(parts[1]):
+		local, parts = Endpoint{"tcp", net.JoinHostPort(parts[0], parts[1])}, parts[2:]
+	default:
+		return local, remote, fmt.Errorf("Invalid local address in forward %q", spec)
+	}
+
+	switch {
+	case len(parts) == 1 && isSocketPath(parts[0]):
+		remote = Endpoint{"unix", parts[0]}
+	case len(parts) == 1 && isPort(parts[0]):
+		remote = Endpoint{"tcp", net.JoinHostPort("127.0.0.1", parts[0])}
+	case len(parts) == 2 && parts[0] != "" && isPort(parts[1]):
+		remote = Endpoint{"tcp", net.JoinHostPort(parts[0], parts[1])}
+	default:
+		return local, remote, fmt.Errorf("Invalid remote address in forward %q", spec)
+	}
+
+	return local, remote, nil
+}
+
+func isSocketPath(s string) bool {
+	return strings.HasPrefix(s, "/")
+}
+
+func isPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 0 && port <= 65535
+}
+
+// Forward listens on the local TCP address localAddr, and forwards every
+// connection to remoteAddr as seen from the host. It returns the address
+// listened on, which differs from localAddr when its port is 0.
+func (f *Forwarder) Forward(localAddr, remoteAddr string) (string, error) {
+	return f.Tunnel(Endpoint{"tcp", localAddr}, Endpoint{"tcp", remoteAddr})
+}
+
+// Tunnel listens on the local endpoint, and forwards every connection to the
+// remote endpoint as seen from the host. It returns the address listened on.
+// A local unix socket is removed when the tunnel is cancelled.
+func (f *Forwarder) Tunnel(local, remote Endpoint) (string, error) {
+	l, err := net.Listen(local.Network, local.Address)
+	if err != nil {
+		return "", err
+	}
+
+	addr := l.Addr().String()
+
+	f.mu.Lock()
+	f.listeners[addr] = l
+	f.mu.Unlock()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.forward(conn, remote)
+		}
+	}()
+
+	return addr, nil
+}
+
+func (f *Forwarder) forward(local net.Conn, remoteEndpoint Endpoint) {
+	defer local.Close()
+
+	conn, err := f.connection()
+	if err != nil {
+		log.Debugf("Error connecting to forward to %s: %s", remoteEndpoint, err)
+		return
+	}
+
+	remote, err := conn.Dial(remoteEndpoint.Network, remoteEndpoint.Address)
+	if err != nil {
+		log.Debugf("Error forwarding to %s: %s", remoteEndpoint, err)
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// Listen listens on the remote endpoint as seen from the host, as `ssh -R`
+// does, and returns a listener accepting its connections locally. The
+// listener is closed when the SSH connection breaks.
+func (f *Forwarder) Listen(remote Endpoint) (net.Listener, error) {
+	conn, err := f.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.Listen(remote.Network, remote.Address)
+}
+
+// Cancel stops listening on addr, as returned by Forward. Connections that
+// are already forwarded are not interrupted.
+func (f *Forwarder) Cancel(addr string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	l, ok := f.listeners[addr]
+	if !ok {
+		return fmt.Errorf("%s is not forwarded", addr)
+	}
+	delete(f.listeners, addr)
+
+	return l.Close()
+}
+
+// Close stops all forwards and closes the SSH connection.
+func (f *Forwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for addr, l := range f.listeners {
+		l.Close()
+		delete(f.listeners, addr)
+	}
+
+	if f.conn == nil {
+		return nil
+	}
+	err := f.conn.Close()
+	f.conn = nil
+	return err
+}
@@ -0,0 +1,391 @@
+package ssh
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestSSHServer starts an SSH server accepting any client, answering exec
+// requests with output and opening direct-tcpip channels to local addresses.
+func newTestSSHServer(t *testing.T, output string) (*NativeClient, func()) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSHConn(conn, config, output)
+		}
+	}()
+
+	port := l.Addr().(*net.TCPAddr).Port
+	client := &NativeClient{
+		Config: ssh.ClientConfig{
+			User:            "tc",
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		},
+		Hostname: "127.0.0.1",
+		Port:     port,
+	}
+
+	return client, func() { l.Close() }
+}
+
+func serveTestSSHConn(c net.Conn, config *ssh.ServerConfig, output string) {
+	serverConn, chans, reqs, err := ssh.NewServerConn(c, config)
+	if err != nil {
+		return
+	}
+	go serveTestGlobalRequests(serverConn, reqs)
+
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go func() {
+				for req := range requests {
+					if req.Type != "exec" {
+						req.Reply(false, nil)
+						continue
+					}
+					req.Reply(true, nil)
+					io.WriteString(channel, output)
+					channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+					channel.Close()
+				}
+			}()
+		case "direct-tcpip", "direct-streamlocal@openssh.com":
+			network, address, err := testChannelTarget(newChannel)
+			if err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			remote, err := net.Dial(network, address)
+			if err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				remote.Close()
+				continue
+			}
+			go ssh.DiscardRequests(requests)
+			go func() {
+				io.Copy(channel, remote)
+				channel.Close()
+			}()
+			go func() {
+				io.Copy(remote, channel)
+				remote.Close()
+			}()
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+		}
+	}
+}
+
+// serveTestGlobalRequests answers tcpip-forward requests by listening on
+// the requested local address, and opening a forwarded-tcpip channel to the
+// client for every connection.
+func serveTestGlobalRequests(serverConn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		if req.Type != "tcpip-forward" {
+			req.Reply(false, nil)
+			continue
+		}
+
+		var bind struct {
+			Addr string
+			Port uint32
+		}
+		if err := ssh.Unmarshal(req.Payload, &bind); err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort(bind.Addr, strconv.Itoa(int(bind.Port))))
+		if err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		port := uint32(l.Addr().(*net.TCPAddr).Port)
+		req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+
+		go func() {
+			defer l.Close()
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				payload := ssh.Marshal(struct {
+					Addr       string
+					Port       uint32
+					OriginAddr string
+					OriginPort uint32
+				}{bind.Addr, port, "127.0.0.1", uint32(conn.RemoteAddr().(*net.TCPAddr).Port)})
+				channel, requests, err := serverConn.OpenChannel("forwarded-tcpip", payload)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				go ssh.DiscardRequests(requests)
+				go func() {
+					io.Copy(channel, conn)
+					channel.CloseWrite()
+				}()
+				go func() {
+					io.Copy(conn, channel)
+					conn.Close()
+				}()
+			}
+		}()
+	}
+}
+
+func testChannelTarget(newChannel ssh.NewChannel) (string, string, error) {
+	if newChannel.ChannelType() == "direct-streamlocal@openssh.com" {
+		var target struct {
+			SocketPath string
+			Reserved0  string
+			Reserved1  uint32
+		}
+		err := ssh.Unmarshal(newChannel.ExtraData(), &target)
+		return "unix", target.SocketPath, err
+	}
+
+	var target struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	err := ssh.Unmarshal(newChannel.ExtraData(), &target)
+	return "tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))), err
+}
+
+// greetingService accepts connections, writes a greeting and closes them.
+func greetingService(t *testing.T, network, address string) net.Listener {
+	service, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := service.Accept()
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, "greetings")
+			conn.Close()
+		}
+	}()
+	return service
+}
+
+func readGreeting(t *testing.T, network, address string) string {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	data, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestForwarderOutput(t *testing.T) {
+	client, stop := newTestSSHServer(t, "hello\n")
+	defer stop()
+
+	f := NewForwarder(client)
+	defer f.Close()
+
+	output, err := f.Output("echo hello")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", output)
+}
+
+func TestForwarderForward(t *testing.T) {
+	client, stop := newTestSSHServer(t, "")
+	defer stop()
+
+	service := greetingService(t, "tcp", "127.0.0.1:0")
+	defer service.Close()
+
+	f := NewForwarder(client)
+	defer f.Close()
+
+	addr, err := f.Forward("127.0.0.1:0", service.Addr().String())
+	assert.NoError(t, err)
+
+	assert.Equal(t, "greetings", readGreeting(t, "tcp", addr))
+
+	assert.NoError(t, f.Cancel(addr))
+	assert.Error(t, f.Cancel(addr))
+
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err)
+}
+
+func TestForwarderTunnelUnixSockets(t *testing.T) {
+	client, stop := newTestSSHServer(t, "")
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "forward-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	service := greetingService(t, "unix", filepath.Join(dir, "remote.sock"))
+	defer service.Close()
+
+	f := NewForwarder(client)
+	defer f.Close()
+
+	localPath := filepath.Join(dir, "local.sock")
+	addr, err := f.Tunnel(Endpoint{"unix", localPath}, Endpoint{"unix", filepath.Join(dir, "remote.sock")})
+	assert.NoError(t, err)
+	assert.Equal(t, localPath, addr)
+
+	assert.Equal(t, "greetings", readGreeting(t, "unix", localPath))
+
+	assert.NoError(t, f.Close())
+	_, err = os.Stat(localPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestForwarderListen(t *testing.T) {
+	client, cleanup := newTestSSHServer(t, "")
+	defer cleanup()
+
+	f := NewForwarder(client)
+	defer f.Close()
+
+	l, err := f.Listen(Endpoint{Network: "tcp", Address: "127.0.0.1:0"})
+	assert.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, "greetings")
+		conn.Close()
+	}()
+
+	assert.Equal(t, "greetings", readGreeting(t, "tcp", l.Addr().String()))
+}
+
+func TestParseTunnel(t *testing.T) {
+	var tests = []struct {
+		spec   string
+		local  Endpoint
+		remote Endpoint
+	}{
+		{"8080:80", Endpoint{"tcp", "127.0.0.1:8080"}, Endpoint{"tcp", "127.0.0.1:80"}},
+		{"8080:localhost:80", Endpoint{"tcp", "127.0.0.1:8080"}, Endpoint{"tcp", "localhost:80"}},
+		{"0.0.0.0:8080:10.0.2.2:80", Endpoint{"tcp", "0.0.0.0:8080"}, Endpoint{"tcp", "10.0.2.2:80"}},
+		{"/tmp/podman.sock:/run/podman/io.podman", Endpoint{"unix", "/tmp/podman.sock"}, Endpoint{"unix", "/run/podman/io.podman"}},
+		{"/tmp/web.sock:localhost:80", Endpoint{"unix", "/tmp/web.sock"}, Endpoint{"tcp", "localhost:80"}},
+		{"12345:/run/podman/io.podman", Endpoint{"tcp", "127.0.0.1:12345"}, Endpoint{"unix", "/run/podman/io.podman"}},
+	}
+
+	for _, test := range tests {
+		local, remote, err := ParseTunnel(test.spec)
+
+		assert.NoError(t, err, test.spec)
+		assert.Equal(t, test.local, local, test.spec)
+		assert.Equal(t, test.remote, remote, test.spec)
+	}
+}
+
+func TestParseTunnelInvalid(t *testing.T) {
+	var tests = []string{
+		"",
+		"8080",
+		"web:80",
+		"8080:localhost",
+		"8080:localhost:http",
+		"8080::80",
+		"relative.sock:80",
+	}
+
+	for _, spec := range tests {
+		_, _, err := ParseTunnel(spec)
+
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestForwarderReconnects(t *testing.T) {
+	first, stopFirst := newTestSSHServer(t, "first\n")
+	second, stopSecond := newTestSSHServer(t, "second\n")
+	defer stopSecond()
+
+	clients := []*NativeClient{first, second}
+	f := NewReconnectingForwarder(func() (*NativeClient, error) {
+		client := clients[0]
+		clients = clients[1:]
+		return client, nil
+	})
+	defer f.Close()
+
+	output, err := f.Output("hostname")
+	assert.NoError(t, err)
+	assert.Equal(t, "first\n", output)
+
+	// Simulate a restart of the machine on another SSH port.
+	stopFirst()
+	f.mu.Lock()
+	f.conn.Close()
+	f.mu.Unlock()
+	for {
+		f.mu.Lock()
+		closed := f.conn == nil
+		f.mu.Unlock()
+		if closed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	output, err = f.Output("hostname")
+	assert.NoError(t, err)
+	assert.Equal(t, "second\n", output)
+}
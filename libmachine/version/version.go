@@ -2,7 +2,13 @@ package version
 
 var (
 	// APIVersion dictates which version of the libmachine API this is.
-	APIVersion = 1
+	APIVersion = 3
+
+	// MinAPIVersion is the oldest version of the libmachine API still
+	// spoken with driver plugins. Version 1 has no cancellation and no
+	// progress events, and plugins older than version 3 serve one machine
+	// each.
+	MinAPIVersion = 1
 
 	// ConfigVersion dictates which version of the config.json format is
 	// used. It needs to be bumped if there is a breaking change, and